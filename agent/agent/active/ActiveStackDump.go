@@ -209,6 +209,10 @@ func GetCurrentStackDetail(txId int64, threadStack string) *value.MapValue {
 			//int tm = ctx.getElapsedTime() - ctx.httpc_stime;
 			//out.put("act_elapsed", tm <0?0:tm);
 		}
+		// whatapsql 이 connection 을 얻는 중인 트랜잭션은 해당 pool 의 상태를 기록
+		if ctx.ActiveDbc != 0 && ctx.ActiveSqlhash == 0 {
+			putDBConnPool(out, ctx.ActiveDbc)
+		}
 		out.PutString("method", ctx.HttpMethod)
 
 		if ctx.Mtid != 0 {
@@ -229,3 +233,20 @@ func GetCurrentStackDetail(txId int64, threadStack string) *value.MapValue {
 	}
 	return out
 }
+
+// putDBConnPool puts the statistics of the connection pool of the dbc (url hash) which the transaction is acquiring
+// the connection from.
+func putDBConnPool(out *value.MapValue, dbc int32) {
+	for url, st := range trace.GetDBConnPoolStats() {
+		if hash.HashStr(url) != dbc {
+			continue
+		}
+		out.PutString("db_pool", url)
+		out.PutLong("db_pool_max", int64(st.MaxOpen))
+		out.PutLong("db_pool_act", int64(st.InUse))
+		out.PutLong("db_pool_idle", int64(st.Idle))
+		out.PutLong("db_pool_wait_count", st.WaitCount)
+		out.PutLong("db_pool_wait_time", st.WaitTime)
+		return
+	}
+}
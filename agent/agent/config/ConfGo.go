@@ -8,22 +8,37 @@ type ConfGo struct {
 
 	GoRecoverEnabled bool
 
+	// sql step 의 오류로 처리하지 않는 error message (redigo: nil returned, record not found)
+	GoSqlIgnoreErrors []string
+	// query 의 sql step 시간에 Rows.Close 까지의 cursor 시간을 포함
+	GoSqlProfileCursorEnabled bool
+
+	// context 에서 찾지 못한 트랜잭션을 goroutine id 로 조회 (legacy, default false)
 	GoUseGoroutineIDEnabled bool
+
+	GoShutdownTimeout int32
 }
 
 func (this *ConfGo) ApplyDefault(m map[string]string) {
 	m["go.sql_profile_enabled"] = "true"
+	m["go.sql_ignore_errors"] = "redigo: nil returned,record not found"
+	m["go.sql_profile_cursor_enabled"] = "false"
 	m["go.counter_enabled"] = "true"
 	m["go.counter_interval"] = "5000"
 	m["go.counter_timeout"] = "5000"
 	m["go.use_goroutine_id_enabled"] = "false"
+	m["go.shutdown_timeout"] = "5000"
 }
 func (this *ConfGo) Apply(conf *Config) {
 	this.GoSqlProfileEnabled = conf.Enabled && GetBoolean("go.sql_profile_enabled", true)
+	this.GoSqlIgnoreErrors = getStringArrayDef("go.sql_ignore_errors", ",", "redigo: nil returned,record not found")
+	this.GoSqlProfileCursorEnabled = GetBoolean("go.sql_profile_cursor_enabled", false)
 	this.GoCounterEnabled = conf.Enabled && GetBoolean("go.counter_enabled", true)
 	this.GoCounterInterval = GetInt("go.counter_interval", 5000)
 	this.GoCounterTimeout = GetInt("go.counter_interval", 5000)
 	this.GoRecoverEnabled = GetBoolean("go.recover_enabled", false)
 
 	this.GoUseGoroutineIDEnabled = conf.Enabled && GetBoolean("go.use_goroutine_id_enabled", false)
+
+	this.GoShutdownTimeout = GetInt("go.shutdown_timeout", 5000)
 }
@@ -8,6 +8,8 @@ type ConfGoGrpc struct {
 	GoGrpcProfileStreamMethod        []string
 	GoGrpcProfileStreamIdentify      bool
 	GoGrpcProfileStreamRate          int32
+	// 오류가 아닌 업무 결과로 처리할 grpc status code. ex) NotFound,Canceled
+	GoGrpcBizErrorCodes []string
 }
 
 func (this *ConfGoGrpc) ApplyDefault(m map[string]string) {
@@ -17,6 +19,8 @@ func (this *ConfGoGrpc) ApplyDefault(m map[string]string) {
 	m["go.grpc_profile_ignore_method"] = ""
 	m["go.grpc_profile_stream_method"] = ""
 	m["go.grpc_profile_stream_identify"] = "false"
+	m["go.grpc_profile_stream_rate"] = "100"
+	m["go.grpc_biz_error_codes"] = ""
 }
 
 func (this *ConfGoGrpc) Apply(conf *Config) {
@@ -26,4 +30,11 @@ func (this *ConfGoGrpc) Apply(conf *Config) {
 	this.GoGrpcProfileIgnoreMethod = GetStringArray("go.grpc_profile_ignore_method", ",")
 	this.GoGrpcProfileStreamMethod = GetStringArray("go.grpc_profile_stream_method", ",")
 	this.GoGrpcProfileStreamIdentify = this.GoGrpcProfileEnabled && GetBoolean("go.grpc_profile_stream_identify", false)
+	this.GoGrpcProfileStreamRate = GetInt("go.grpc_profile_stream_rate", 100)
+	if this.GoGrpcProfileStreamRate < 0 {
+		this.GoGrpcProfileStreamRate = 0
+	} else if this.GoGrpcProfileStreamRate > 100 {
+		this.GoGrpcProfileStreamRate = 100
+	}
+	this.GoGrpcBizErrorCodes = GetStringArray("go.grpc_biz_error_codes", ",")
 }
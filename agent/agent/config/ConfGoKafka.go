@@ -0,0 +1,24 @@
+package config
+
+type ConfGoKafka struct {
+	GoKafkaProfileEnabled bool
+	// consumer 에서 한 번에 가져온 message 들을 하나의 트랜잭션으로 처리
+	GoKafkaConsumeBatchEnabled bool
+	// message body 를 step 에 기록. 기본 false
+	GoKafkaProfileBodyEnabled bool
+	GoKafkaProfileBodyMaxSize int32
+}
+
+func (this *ConfGoKafka) ApplyDefault(m map[string]string) {
+	m["go.kafka_profile_enabled"] = "true"
+	m["go.kafka_consume_batch_enabled"] = "false"
+	m["go.kafka_profile_body_enabled"] = "false"
+	m["go.kafka_profile_body_max_size"] = "256"
+}
+
+func (this *ConfGoKafka) Apply(conf *Config) {
+	this.GoKafkaProfileEnabled = conf.Enabled && GetBoolean("go.kafka_profile_enabled", true)
+	this.GoKafkaConsumeBatchEnabled = GetBoolean("go.kafka_consume_batch_enabled", false)
+	this.GoKafkaProfileBodyEnabled = this.GoKafkaProfileEnabled && GetBoolean("go.kafka_profile_body_enabled", false)
+	this.GoKafkaProfileBodyMaxSize = GetInt("go.kafka_profile_body_max_size", 256)
+}
@@ -8,6 +8,8 @@ type ConfLogSink struct {
 	WatchLogBufferSize int32
 	WatchLogLineSize   int32
 	WatchLogSendCount  int32
+	// 한 줄의 최대 길이 (bytes). 넘는 부분은 버림
+	WatchLogMaxLineSize int32
 
 	LogSinkEnabled bool
 	//	public static boolean logsink_stdout_enabled = logsink_enabled;
@@ -36,6 +38,34 @@ type ConfLogSink struct {
 	LogSendThreshold int32
 
 	LogSinkStopInterval int64
+
+	// 파일별 읽은 위치를 저장하여 재시작 후 이어서 읽음
+	WatchLogCheckpointEnabled bool
+	// 기본값 {WHATAP_HOME}/logsink/checkpoint
+	WatchLogCheckpointPath string
+
+	// logsink.files 의 glob(*, **), 디렉토리 패턴으로 찾은 파일의 이름 필터
+	LogSinkFilesInclude []string
+	LogSinkFilesExclude []string
+	// 패턴에 해당하는 새 파일을 찾는 주기 (ms)
+	LogSinkDiscoveryInterval int64
+	// 추가된 내용이 없는 파일을 감시 목록에서 제외하는 시간 (ms). 0 이면 제외하지 않음
+	LogSinkIdleTimeout int64
+	// 패턴으로 찾아 동시에 감시하는 최대 파일 수
+	LogSinkMaxFiles int32
+
+	// 로그 라인 파싱 형식 json, logfmt, regex. 파일별 logsink.{category}.parser 로 지정
+	LogSinkParser string
+	// 시간, 레벨, 메시지로 사용하는 필드 이름. 앞의 필드부터 확인
+	LogSinkParserTimeFields    []string
+	LogSinkParserLevelFields   []string
+	LogSinkParserMessageFields []string
+	// 시간 필드의 go layout. 지정하지 않으면 RFC3339, epoch 숫자를 확인
+	LogSinkParserTimeFormat string
+	// 태그로 추가하는 최대 필드 수
+	LogSinkParserMaxTags int32
+	// 이 레벨보다 낮은 로그는 전송하지 않음 (trace, debug, info, warn, error, fatal)
+	LogSinkLevel string
 }
 
 func (this *ConfLogSink) Apply(conf *Config) {
@@ -51,6 +81,7 @@ func (this *ConfLogSink) Apply(conf *Config) {
 	this.WatchLogSendCount = GetInt("watchlog_send_count", 0)
 	this.WatchLogBufferSize = GetInt("watchlog_buffer_size", 128*1024)
 	this.WatchLogLineSize = GetInt("watchlog_line_size", 512)
+	this.WatchLogMaxLineSize = GetInt("watchlog_max_line_size", 64*1024)
 
 	this.LogSinkEnabled = GetBoolean("logsink_enabled", false)
 
@@ -84,4 +115,21 @@ func (this *ConfLogSink) Apply(conf *Config) {
 
 	//Interval until end time, default 30 minutes
 	this.LogSinkStopInterval = GetLong("logsink_stop_interval", 60000*30)
+
+	this.WatchLogCheckpointEnabled = GetBoolean("watchlog_checkpoint_enabled", true)
+	this.WatchLogCheckpointPath = GetValue("watchlog_checkpoint_path")
+
+	this.LogSinkFilesInclude = GetStringArray("logsink.files.include", ",")
+	this.LogSinkFilesExclude = getStringArrayDef("logsink.files.exclude", ",", "*.gz,*.zip,*.bz2")
+	this.LogSinkDiscoveryInterval = GetLong("logsink_discovery_interval", 10000)
+	this.LogSinkIdleTimeout = GetLong("logsink_idle_timeout", 60000*60)
+	this.LogSinkMaxFiles = GetInt("logsink_max_files", 100)
+
+	this.LogSinkParser = GetValue("logsink_parser")
+	this.LogSinkParserTimeFields = getStringArrayDef("logsink_parser_time_fields", ",", "time,ts,timestamp,@timestamp")
+	this.LogSinkParserLevelFields = getStringArrayDef("logsink_parser_level_fields", ",", "level,lvl,severity,log.level")
+	this.LogSinkParserMessageFields = getStringArrayDef("logsink_parser_message_fields", ",", "msg,message")
+	this.LogSinkParserTimeFormat = GetValue("logsink_parser_time_format")
+	this.LogSinkParserMaxTags = GetInt("logsink_parser_max_tags", 30)
+	this.LogSinkLevel = GetValue("logsink_level")
 }
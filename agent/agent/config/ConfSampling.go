@@ -0,0 +1,59 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+type SamplingServiceRate struct {
+	// service name. 끝이 * 이면 prefix 비교
+	Service string
+	Rate    float32
+}
+
+type ConfSampling struct {
+	SamplingEnabled bool
+	// 프로파일을 수집할 트랜잭션 비율(%)
+	SamplingRate float32
+	// service 별 비율. sampling_service_rates=/health:0,/api/order*:50
+	SamplingServiceRates []SamplingServiceRate
+
+	// tail. sampling 에서 제외되었어도 프로파일을 수집하는 조건
+	SamplingKeepErrorEnabled bool
+	SamplingKeepElapsed      int32
+	SamplingKeepSqlCount     int32
+	// 종료 시 tail 조건을 판단할 때까지 보관하는 step 수
+	SamplingKeepStepCount int32
+}
+
+func (this *ConfSampling) Apply(conf *Config) {
+	this.SamplingEnabled = conf.Enabled && GetBoolean("sampling_enabled", false)
+	this.SamplingRate = getFloat("sampling_rate", 100)
+	if this.SamplingRate > 100 {
+		this.SamplingRate = 100
+	} else if this.SamplingRate < 0 {
+		this.SamplingRate = 0
+	}
+
+	rates := make([]SamplingServiceRate, 0)
+	for _, it := range GetStringArray("sampling_service_rates", ",") {
+		pos := strings.LastIndex(it, ":")
+		if pos <= 0 {
+			continue
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(it[pos+1:]), 32)
+		if err != nil {
+			continue
+		}
+		rates = append(rates, SamplingServiceRate{strings.TrimSpace(it[:pos]), float32(rate)})
+	}
+	this.SamplingServiceRates = rates
+
+	this.SamplingKeepErrorEnabled = GetBoolean("sampling_keep_error_enabled", true)
+	this.SamplingKeepElapsed = GetInt("sampling_keep_elapsed", 0)
+	this.SamplingKeepSqlCount = GetInt("sampling_keep_sql_count", 0)
+	this.SamplingKeepStepCount = GetInt("sampling_keep_step_count", 100)
+	if this.SamplingKeepStepCount < 0 {
+		this.SamplingKeepStepCount = 0
+	}
+}
@@ -313,6 +313,8 @@ type Config struct {
 	TraceMtraceSpecKey        string
 	TraceMtraceSpecKey1       string
 	TraceMtraceTraceparentKey string
+	TraceMtraceTracestateKey  string
+	TraceMtraceBaggageKey     string
 
 	MtraceSendUrlLength int32
 	MtraceSpec          string
@@ -541,6 +543,7 @@ type Config struct {
 	// Golang
 	ConfGo
 	ConfGoGrpc
+	ConfGoKafka
 
 	// profile, missing profile
 	ConfProfile
@@ -549,6 +552,8 @@ type Config struct {
 	ConfFowarder
 
 	ConfTrace
+
+	ConfSampling
 }
 
 var conf *Config = nil
@@ -1114,6 +1119,8 @@ func apply() {
 	conf.TraceMtraceSpecKey = getValueDef("mtrace_spec_key", "x-wtap-sp")
 	conf.TraceMtraceSpecKey1 = getValueDef("mtrace_spec_key1", "x-wtap-sp1")
 	conf.TraceMtraceTraceparentKey = getValueDef("mtrace_traceparent_key", "traceparent")
+	conf.TraceMtraceTracestateKey = getValueDef("mtrace_tracestate_key", "tracestate")
+	conf.TraceMtraceBaggageKey = getValueDef("mtrace_baggage_key", "baggage")
 	conf.MtraceSendUrlLength = getInt("mtrace_send_url_length", 80)
 	conf.MtraceSpec = getValueDef("mtrace_spec", "")
 	if conf.MtraceSpec == "" {
@@ -1361,6 +1368,7 @@ func apply() {
 	// Golang
 	conf.ConfGo.Apply(conf)
 	conf.ConfGoGrpc.Apply(conf)
+	conf.ConfGoKafka.Apply(conf)
 
 	conf.ConfProfile.Apply(conf)
 
@@ -1368,6 +1376,8 @@ func apply() {
 	conf.ConfFowarder.Apply(conf)
 
 	conf.ConfTrace.Apply(conf)
+
+	conf.ConfSampling.Apply(conf)
 }
 func GetValue(key string) string { return getValue(key) }
 func getValue(key string) string {
@@ -44,6 +44,8 @@ func StartCounterManager() {
 	httpc := NewTaskHttpc()
 	// Sql
 	sql := NewTaskSql()
+	// DB connection pool
+	dbPool := NewTaskDBConnPool()
 
 	agentInfo := NewTaskAgentInfo()
 
@@ -58,6 +60,7 @@ func StartCounterManager() {
 		user,
 		httpc,
 		sql,
+		dbPool,
 		agentInfo,
 		proc,
 		heap,
@@ -0,0 +1,50 @@
+package counter
+
+import (
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/agent/agent/trace"
+	"github.com/whatap/go-api/agent/util/logutil"
+	"github.com/whatap/golib/lang/pack"
+	"github.com/whatap/golib/util/hash"
+)
+
+// TaskDBConnPool sets the active and idle connections of the connection pools registered by whatapsql.
+type TaskDBConnPool struct {
+}
+
+func NewTaskDBConnPool() *TaskDBConnPool {
+	p := new(TaskDBConnPool)
+	return p
+}
+
+func (this *TaskDBConnPool) process(p *pack.CounterPack1) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA401", "process Recover", r)
+		}
+	}()
+	conf := config.GetConfig()
+	if !conf.CounterEnabledDBPool_ {
+		if conf.CounterLogEnabled {
+			logutil.Println("WA401-01", "Disable counter, db pool")
+		}
+		return
+	} else {
+		if conf.CounterLogEnabled {
+			logutil.Println("WA401-02", "Start counter, db pool")
+		}
+	}
+
+	stats := trace.GetDBConnPoolStats()
+	if len(stats) == 0 {
+		return
+	}
+	m := meter.GetInstanceConnPool()
+	for url, st := range stats {
+		m.AddDBConnPool(hash.HashStr(url), st.InUse, st.Idle)
+	}
+	b := m.GetBucketReset()
+	p.DbNumActive = b.GetActiveMap()
+	p.DbNumIdle = b.GetIdleMap()
+}
@@ -0,0 +1,139 @@
+package meter
+
+import (
+	"sync"
+)
+
+type GrpcConnBucket struct {
+	Side       string
+	LocalAddr  string
+	RemoteAddr string
+	// 현재 진행 중인 stream 수
+	ActiveStreams int32
+	Streams       int32
+	MessageIn     int32
+	MessageOut    int32
+	BytesIn       int64
+	BytesOut      int64
+
+	closed bool
+}
+
+func NewGrpcConnBucket(side, localAddr, remoteAddr string) *GrpcConnBucket {
+	p := new(GrpcConnBucket)
+	p.Side = side
+	p.LocalAddr = localAddr
+	p.RemoteAddr = remoteAddr
+	return p
+}
+
+// IsClosed returns true if the connection is closed in this bucket.
+func (this *GrpcConnBucket) IsClosed() bool {
+	return this.closed
+}
+
+// MeterGrpc counts the streams and the messages of each grpc connection. (whatapgrpc stats.Handler)
+type MeterGrpc struct {
+	conns  map[string]*GrpcConnBucket
+	Opened int32
+	Closed int32
+	lock   sync.Mutex
+}
+
+var meterGrpc *MeterGrpc = newMeterGrpc()
+
+func newMeterGrpc() *MeterGrpc {
+	p := new(MeterGrpc)
+	p.conns = make(map[string]*GrpcConnBucket)
+	return p
+}
+func GetInstanceMeterGrpc() *MeterGrpc {
+	if meterGrpc == nil {
+		return newMeterGrpc()
+	} else {
+		return meterGrpc
+	}
+}
+
+func grpcConnKey(side, localAddr, remoteAddr string) string {
+	return side + "|" + localAddr + "|" + remoteAddr
+}
+
+// getConn 은 lock 안에서 호출. 없으면 생성
+func (this *MeterGrpc) getConn(side, localAddr, remoteAddr string) *GrpcConnBucket {
+	key := grpcConnKey(side, localAddr, remoteAddr)
+	b, ok := this.conns[key]
+	if !ok {
+		b = NewGrpcConnBucket(side, localAddr, remoteAddr)
+		this.conns[key] = b
+	}
+	return b
+}
+
+// GetBucketReset returns the counters of the connections and resets them. The closed connections are removed.
+func (this *MeterGrpc) GetBucketReset() ([]*GrpcConnBucket, int32, int32) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	rt := make([]*GrpcConnBucket, 0, len(this.conns))
+	for key, b := range this.conns {
+		rt = append(rt, b)
+		if b.closed {
+			delete(this.conns, key)
+			continue
+		}
+		n := NewGrpcConnBucket(b.Side, b.LocalAddr, b.RemoteAddr)
+		n.ActiveStreams = b.ActiveStreams
+		this.conns[key] = n
+	}
+	opened, closed := this.Opened, this.Closed
+	this.Opened = 0
+	this.Closed = 0
+	return rt, opened, closed
+}
+
+func (this *MeterGrpc) OpenConn(side, localAddr, remoteAddr string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.getConn(side, localAddr, remoteAddr)
+	this.Opened++
+}
+
+func (this *MeterGrpc) CloseConn(side, localAddr, remoteAddr string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.getConn(side, localAddr, remoteAddr).closed = true
+	this.Closed++
+}
+
+func (this *MeterGrpc) StartStream(side, localAddr, remoteAddr string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	b := this.getConn(side, localAddr, remoteAddr)
+	b.Streams++
+	b.ActiveStreams++
+}
+
+func (this *MeterGrpc) EndStream(side, localAddr, remoteAddr string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	b := this.getConn(side, localAddr, remoteAddr)
+	if b.ActiveStreams > 0 {
+		b.ActiveStreams--
+	}
+}
+
+func (this *MeterGrpc) AddMessageIn(side, localAddr, remoteAddr string, bytes int64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	b := this.getConn(side, localAddr, remoteAddr)
+	b.MessageIn++
+	b.BytesIn += bytes
+}
+
+func (this *MeterGrpc) AddMessageOut(side, localAddr, remoteAddr string, bytes int64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	b := this.getConn(side, localAddr, remoteAddr)
+	b.MessageOut++
+	b.BytesOut += bytes
+}
@@ -0,0 +1,106 @@
+package meter
+
+import (
+	"fmt"
+	"sync"
+)
+
+type KafkaBucket struct {
+	Produced      int32
+	ProduceErrors int32
+	// ack 까지 걸린 시간의 합 (ms)
+	ProduceTime   int64
+	Consumed      int32
+	ConsumeErrors int32
+}
+
+func NewKafkaBucket() *KafkaBucket {
+	p := new(KafkaBucket)
+	return p
+}
+
+// KafkaLag is the consumer lag of the partition. (high water mark - next offset)
+type KafkaLag struct {
+	Group     string
+	Topic     string
+	Partition int32
+	Lag       int64
+}
+
+// MeterKafka counts the produced and consumed messages of whatapsarama and the consumer lag of each partition.
+type MeterKafka struct {
+	Bucket *KafkaBucket
+	lags   map[string]*KafkaLag
+	lock   sync.Mutex
+}
+
+var meterKafka *MeterKafka = newMeterKafka()
+
+func newMeterKafka() *MeterKafka {
+	p := new(MeterKafka)
+	p.Bucket = NewKafkaBucket()
+	p.lags = make(map[string]*KafkaLag)
+	return p
+}
+func GetInstanceMeterKafka() *MeterKafka {
+	if meterKafka == nil {
+		return newMeterKafka()
+	} else {
+		return meterKafka
+	}
+}
+
+// GetBucketReset returns the counters and the last lags of the partitions consumed in this interval.
+func (this *MeterKafka) GetBucketReset() (*KafkaBucket, []*KafkaLag) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	b := this.Bucket
+	this.Bucket = NewKafkaBucket()
+	lags := make([]*KafkaLag, 0, len(this.lags))
+	for _, it := range this.lags {
+		lags = append(lags, it)
+	}
+	// rebalance 등으로 할당이 해제된 partition 은 다음 주기에 전송하지 않음
+	this.lags = make(map[string]*KafkaLag)
+	return b, lags
+}
+
+func (this *MeterKafka) AddProduce(elapsed int64, err bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.Bucket.Produced++
+	this.Bucket.ProduceTime += elapsed
+	if err {
+		this.Bucket.ProduceErrors++
+	}
+}
+
+// AddProduceError counts the error of the message which is not counted by AddProduce. (Return.Successes 를 사용하지 않는 경우)
+func (this *MeterKafka) AddProduceError() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.Bucket.ProduceErrors++
+}
+
+func (this *MeterKafka) AddConsume(count int32, err bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.Bucket.Consumed += count
+	if err {
+		this.Bucket.ConsumeErrors++
+	}
+}
+
+func (this *MeterKafka) SetLag(group, topic string, partition int32, lag int64) {
+	if lag < 0 {
+		lag = 0
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	key := fmt.Sprintf("%s|%s|%d", group, topic, partition)
+	if it, ok := this.lags[key]; ok {
+		it.Lag = lag
+		return
+	}
+	this.lags[key] = &KafkaLag{Group: group, Topic: topic, Partition: partition, Lag: lag}
+}
@@ -0,0 +1,62 @@
+package meter
+
+import (
+	"sync"
+)
+
+type TxBytesBucket struct {
+	// 요청 또는 응답 bytes 가 기록된 트랜잭션 수
+	Count         int32
+	RequestBytes  int64
+	ResponseBytes int64
+	RequestMax    int64
+	ResponseMax   int64
+}
+
+func NewTxBytesBucket() *TxBytesBucket {
+	p := new(TxBytesBucket)
+	return p
+}
+
+// MeterTxBytes sums the request and the response body bytes of the ended transactions.
+type MeterTxBytes struct {
+	Bucket *TxBytesBucket
+	lock   sync.Mutex
+}
+
+var meterTxBytes *MeterTxBytes = newMeterTxBytes()
+
+func newMeterTxBytes() *MeterTxBytes {
+	p := new(MeterTxBytes)
+	p.Bucket = NewTxBytesBucket()
+	return p
+}
+func GetInstanceMeterTxBytes() *MeterTxBytes {
+	if meterTxBytes == nil {
+		return newMeterTxBytes()
+	} else {
+		return meterTxBytes
+	}
+}
+
+func (this *MeterTxBytes) GetBucketReset() *TxBytesBucket {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	b := this.Bucket
+	this.Bucket = NewTxBytesBucket()
+	return b
+}
+
+func (this *MeterTxBytes) Add(requestBytes, responseBytes int64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.Bucket.Count++
+	this.Bucket.RequestBytes += requestBytes
+	this.Bucket.ResponseBytes += responseBytes
+	if this.Bucket.RequestMax < requestBytes {
+		this.Bucket.RequestMax = requestBytes
+	}
+	if this.Bucket.ResponseMax < responseBytes {
+		this.Bucket.ResponseMax = responseBytes
+	}
+}
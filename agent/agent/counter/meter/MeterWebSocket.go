@@ -0,0 +1,79 @@
+package meter
+
+import (
+	"sync"
+)
+
+type WebSocketBucket struct {
+	Opened     int32
+	Closed     int32
+	MessageIn  int32
+	MessageOut int32
+	BytesIn    int64
+	BytesOut   int64
+}
+
+func NewWebSocketBucket() *WebSocketBucket {
+	p := new(WebSocketBucket)
+	return p
+}
+
+type MeterWebSocket struct {
+	Bucket *WebSocketBucket
+	// 현재 열려 있는 connection 수
+	Active int32
+	lock   sync.Mutex
+}
+
+var meterWebSocket *MeterWebSocket = newMeterWebSocket()
+
+func newMeterWebSocket() *MeterWebSocket {
+	p := new(MeterWebSocket)
+	p.Bucket = NewWebSocketBucket()
+	return p
+}
+func GetInstanceMeterWebSocket() *MeterWebSocket {
+	if meterWebSocket == nil {
+		return newMeterWebSocket()
+	} else {
+		return meterWebSocket
+	}
+}
+
+func (this *MeterWebSocket) GetBucketReset() (*WebSocketBucket, int32) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	b := this.Bucket
+	this.Bucket = NewWebSocketBucket()
+	return b, this.Active
+}
+
+func (this *MeterWebSocket) Open() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.Bucket.Opened++
+	this.Active++
+}
+
+func (this *MeterWebSocket) Close() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.Bucket.Closed++
+	if this.Active > 0 {
+		this.Active--
+	}
+}
+
+func (this *MeterWebSocket) AddMessageIn(bytes int64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.Bucket.MessageIn++
+	this.Bucket.BytesIn += bytes
+}
+
+func (this *MeterWebSocket) AddMessageOut(bytes int64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.Bucket.MessageOut++
+	this.Bucket.BytesOut += bytes
+}
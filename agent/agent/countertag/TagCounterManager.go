@@ -22,6 +22,11 @@ func StartTagCounterManager() {
 
 	if conf.AppType == lang.APP_TYPE_GO {
 		tasks = append(tasks, NewTagTaskGoRuntime())
+		tasks = append(tasks, NewTagTaskWebSocket())
+		tasks = append(tasks, NewTagTaskGrpc())
+		tasks = append(tasks, NewTagTaskKafka())
+		tasks = append(tasks, NewTagTaskDBPool())
+		tasks = append(tasks, NewTagTaskTxBytes())
 	}
 
 	var INTERVAL int32 = conf.TagCountInterval
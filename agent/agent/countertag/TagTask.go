@@ -7,3 +7,15 @@ import (
 type Task interface {
 	process(p *pack.TagCountPack)
 }
+
+// newTagCountPack returns the new pack of the category with the header of p. (go_runtime 과 같은 pack 을 사용하지 않도록 새로 생성)
+func newTagCountPack(p *pack.TagCountPack, category string) *pack.TagCountPack {
+	cp := pack.NewTagCountPack()
+	cp.Pcode = p.Pcode
+	cp.Oid = p.Oid
+	cp.Okind = p.Okind
+	cp.Onode = p.Onode
+	cp.Time = p.Time
+	cp.Category = category
+	return cp
+}
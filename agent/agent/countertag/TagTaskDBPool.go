@@ -0,0 +1,55 @@
+package countertag
+
+import (
+	"os"
+
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/agent/data"
+	"github.com/whatap/go-api/agent/agent/trace"
+	"github.com/whatap/golib/lang/pack"
+)
+
+// TagTaskDBPool sends the statistics of the connection pools registered by whatapsql. (category go_db_pool, db_pool_detail)
+// WaitCount, WaitTime, MaxIdleClosed and MaxLifetimeClosed are the counts in this interval.
+type TagTaskDBPool struct {
+	// 이전 주기의 누적값
+	last map[string]*trace.DBPoolStat
+}
+
+func NewTagTaskDBPool() *TagTaskDBPool {
+	p := new(TagTaskDBPool)
+	p.last = make(map[string]*trace.DBPoolStat)
+	return p
+}
+
+func (this *TagTaskDBPool) process(p *pack.TagCountPack) {
+	conf := config.GetConfig()
+	if !conf.CounterEnabledDBPool_ {
+		return
+	}
+	stats := trace.GetDBConnPoolStats()
+	pid := int32(os.Getpid())
+	last := make(map[string]*trace.DBPoolStat, len(stats))
+	for url, st := range stats {
+		last[url] = st
+		prev, ok := this.last[url]
+		// 등록된 후 처음 수집한 경우 또는 pool 이 다시 열려 누적값이 줄어든 경우 현재 누적값부터 계산
+		if !ok || st.WaitCount < prev.WaitCount {
+			prev = &trace.DBPoolStat{}
+		}
+		trace.SendConnPoolTagCount(pid, url, st.InUse, st.Idle)
+
+		dp := newTagCountPack(p, "go_db_pool")
+		dp.PutTag("pool", url)
+		dp.Put("MaxOpen", st.MaxOpen)
+		dp.Put("Open", st.Open)
+		dp.Put("InUse", st.InUse)
+		dp.Put("Idle", st.Idle)
+		dp.Put("WaitCount", st.WaitCount-prev.WaitCount)
+		dp.Put("WaitTime", st.WaitTime-prev.WaitTime)
+		dp.Put("MaxIdleClosed", st.MaxIdleClosed-prev.MaxIdleClosed)
+		dp.Put("MaxLifetimeClosed", st.MaxLifetimeClosed-prev.MaxLifetimeClosed)
+		data.SendHide(dp)
+	}
+	this.last = last
+}
@@ -0,0 +1,48 @@
+package countertag
+
+import (
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/agent/agent/data"
+	"github.com/whatap/golib/lang/pack"
+)
+
+// TagTaskGrpc sends the counters of each grpc connection. (category go_grpc_conn)
+type TagTaskGrpc struct {
+}
+
+func NewTagTaskGrpc() *TagTaskGrpc {
+	p := new(TagTaskGrpc)
+	return p
+}
+
+func (this *TagTaskGrpc) process(p *pack.TagCountPack) {
+	conns, opened, closed := meter.GetInstanceMeterGrpc().GetBucketReset()
+	// stats.Handler 를 사용하지 않는 경우 전송하지 않음
+	if len(conns) == 0 && opened == 0 && closed == 0 {
+		return
+	}
+
+	var active int32
+	for _, b := range conns {
+		if !b.IsClosed() {
+			active++
+		}
+		cp := newTagCountPack(p, "go_grpc_conn")
+		cp.PutTag("side", b.Side)
+		cp.PutTag("local", b.LocalAddr)
+		cp.PutTag("remote", b.RemoteAddr)
+		cp.Put("ActiveStreams", b.ActiveStreams)
+		cp.Put("Streams", b.Streams)
+		cp.Put("MessageIn", b.MessageIn)
+		cp.Put("MessageOut", b.MessageOut)
+		cp.Put("BytesIn", b.BytesIn)
+		cp.Put("BytesOut", b.BytesOut)
+		data.SendHide(cp)
+	}
+
+	sp := newTagCountPack(p, "go_grpc")
+	sp.Put("Active", active)
+	sp.Put("Opened", opened)
+	sp.Put("Closed", closed)
+	data.SendHide(sp)
+}
@@ -0,0 +1,44 @@
+package countertag
+
+import (
+	"strconv"
+
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/agent/agent/data"
+	"github.com/whatap/golib/lang/pack"
+)
+
+// TagTaskKafka sends the counters of the kafka producers, consumers (category go_kafka)
+// and the consumer lag of each partition (category go_kafka_lag).
+type TagTaskKafka struct {
+}
+
+func NewTagTaskKafka() *TagTaskKafka {
+	p := new(TagTaskKafka)
+	return p
+}
+
+func (this *TagTaskKafka) process(p *pack.TagCountPack) {
+	b, lags := meter.GetInstanceMeterKafka().GetBucketReset()
+	// whatapsarama 를 사용하지 않는 경우 전송하지 않음
+	if b.Produced == 0 && b.ProduceErrors == 0 && b.Consumed == 0 && b.ConsumeErrors == 0 && len(lags) == 0 {
+		return
+	}
+
+	kp := newTagCountPack(p, "go_kafka")
+	kp.Put("Produced", b.Produced)
+	kp.Put("ProduceErrors", b.ProduceErrors)
+	kp.Put("ProduceTime", b.ProduceTime)
+	kp.Put("Consumed", b.Consumed)
+	kp.Put("ConsumeErrors", b.ConsumeErrors)
+	data.SendHide(kp)
+
+	for _, it := range lags {
+		lp := newTagCountPack(p, "go_kafka_lag")
+		lp.PutTag("group", it.Group)
+		lp.PutTag("topic", it.Topic)
+		lp.PutTag("partition", strconv.Itoa(int(it.Partition)))
+		lp.Put("Lag", it.Lag)
+		data.SendHide(lp)
+	}
+}
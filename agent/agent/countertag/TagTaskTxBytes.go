@@ -0,0 +1,32 @@
+package countertag
+
+import (
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/agent/agent/data"
+	"github.com/whatap/golib/lang/pack"
+)
+
+// TagTaskTxBytes sends the request and the response bytes of the transactions. (category go_transaction_bytes)
+type TagTaskTxBytes struct {
+}
+
+func NewTagTaskTxBytes() *TagTaskTxBytes {
+	p := new(TagTaskTxBytes)
+	return p
+}
+
+func (this *TagTaskTxBytes) process(p *pack.TagCountPack) {
+	b := meter.GetInstanceMeterTxBytes().GetBucketReset()
+	// bytes 가 기록된 트랜잭션이 없으면 전송하지 않음
+	if b.Count == 0 {
+		return
+	}
+
+	bp := newTagCountPack(p, "go_transaction_bytes")
+	bp.Put("Count", b.Count)
+	bp.Put("RequestBytes", b.RequestBytes)
+	bp.Put("ResponseBytes", b.ResponseBytes)
+	bp.Put("RequestMax", b.RequestMax)
+	bp.Put("ResponseMax", b.ResponseMax)
+	data.SendHide(bp)
+}
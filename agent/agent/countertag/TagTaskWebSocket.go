@@ -0,0 +1,35 @@
+package countertag
+
+import (
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/agent/agent/data"
+	"github.com/whatap/golib/lang/pack"
+)
+
+// TagTaskWebSocket sends the counters of the websocket (long-lived) connections. (category go_websocket)
+type TagTaskWebSocket struct {
+}
+
+func NewTagTaskWebSocket() *TagTaskWebSocket {
+	p := new(TagTaskWebSocket)
+	return p
+}
+
+func (this *TagTaskWebSocket) process(p *pack.TagCountPack) {
+	b, active := meter.GetInstanceMeterWebSocket().GetBucketReset()
+	// websocket 을 사용하지 않는 경우 전송하지 않음
+	if active == 0 && b.Opened == 0 && b.Closed == 0 && b.MessageIn == 0 && b.MessageOut == 0 {
+		return
+	}
+
+	wp := newTagCountPack(p, "go_websocket")
+	wp.Put("Active", active)
+	wp.Put("Opened", b.Opened)
+	wp.Put("Closed", b.Closed)
+	wp.Put("MessageIn", b.MessageIn)
+	wp.Put("MessageOut", b.MessageOut)
+	wp.Put("BytesIn", b.BytesIn)
+	wp.Put("BytesOut", b.BytesOut)
+
+	data.SendHide(wp)
+}
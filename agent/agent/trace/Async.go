@@ -0,0 +1,121 @@
+package trace
+
+import (
+	"sync/atomic"
+
+	"github.com/whatap/go-api/agent/agent/stat"
+	"github.com/whatap/golib/util/keygen"
+)
+
+// asyncTotal is the counters of the async context merged by MergeAsync.
+type asyncTotal struct {
+	sqlCount   int32
+	sqlTime    int32
+	sqlInsert  int32
+	sqlUpdate  int32
+	sqlDelete  int32
+	sqlSelect  int32
+	sqlOthers  int32
+	dbcTime    int32
+	fetchCount int32
+	fetchTime  int64
+	rsCount    int32
+	rsTime     int64
+	httpcTime  int32
+
+	err        int64
+	errorLevel byte
+	thr        *stat.ErrorThrowable
+}
+
+// NewAsyncContext returns the context of the async work started by the transaction.
+// Steps of the async work are collected in the returned context and merged with MergeAsync.
+func (this *TraceContext) NewAsyncContext() *TraceContext {
+	p := PoolTraceContext()
+	p.Txid = keygen.Next()
+	// step 의 시작 시간을 부모 트랜잭션 기준으로 기록
+	p.StartTime = this.StartTime
+	p.StartCpu = this.StartCpu
+	p.StartMalloc = this.StartMalloc
+	p.ServiceHash = this.ServiceHash
+	p.ServiceName = this.ServiceName
+	p.ServiceURL = this.ServiceURL
+	p.Mtid = this.Mtid
+	p.Mdepth = this.Mdepth
+	return p
+}
+
+// MergeAsync adds the steps of the async context as children of the parent step index.
+// The counters are queued and added up by FoldAsync on the goroutine which ends the transaction.
+func (this *TraceContext) MergeAsync(child *TraceContext, parent int32) {
+	// GetSteps 는 시작 시간 순으로 정렬되어 parent 가 child 보다 앞에 있음
+	steps := child.Profile.GetSteps()
+	newIndex := make(map[int32]int32, len(steps))
+	for _, st := range steps {
+		p := parent
+		if i, ok := newIndex[st.GetParent()]; ok {
+			p = i
+		}
+		old := st.GetIndex()
+		this.Profile.PushChild(p, st)
+		newIndex[old] = st.GetIndex()
+		// async context 에서 이미 step 수 제한을 거친 step
+		this.Profile.AddHeavy(st)
+	}
+
+	// HttpcCount 는 다른 goroutine 에서도 atomic 으로 증가
+	atomic.AddInt32(&this.HttpcCount, atomic.LoadInt32(&child.HttpcCount))
+
+	// 나머지 counter 는 트랜잭션의 goroutine 에서만 변경하도록 종료 시 더함
+	this.asyncLock.Lock()
+	defer this.asyncLock.Unlock()
+	this.asyncTotals = append(this.asyncTotals, asyncTotal{
+		sqlCount:   child.SqlCount,
+		sqlTime:    child.SqlTime,
+		sqlInsert:  child.SqlInsert,
+		sqlUpdate:  child.SqlUpdate,
+		sqlDelete:  child.SqlDelete,
+		sqlSelect:  child.SqlSelect,
+		sqlOthers:  child.SqlOthers,
+		dbcTime:    child.DbcTime,
+		fetchCount: child.FetchCount,
+		fetchTime:  child.FetchTime,
+		rsCount:    child.RsCount,
+		rsTime:     child.RsTime,
+		httpcTime:  child.HttpcTime,
+		err:        child.Error,
+		errorLevel: child.ErrorLevel,
+		thr:        child.Thr,
+	})
+}
+
+// FoldAsync adds up the counters of the async contexts merged by MergeAsync.
+// It is called on the goroutine which ends the transaction.
+func (this *TraceContext) FoldAsync() {
+	this.asyncLock.Lock()
+	totals := this.asyncTotals
+	this.asyncTotals = nil
+	this.asyncLock.Unlock()
+
+	for _, it := range totals {
+		this.SqlCount += it.sqlCount
+		this.SqlTime += it.sqlTime
+		this.SqlInsert += it.sqlInsert
+		this.SqlUpdate += it.sqlUpdate
+		this.SqlDelete += it.sqlDelete
+		this.SqlSelect += it.sqlSelect
+		this.SqlOthers += it.sqlOthers
+		this.DbcTime += it.dbcTime
+		this.FetchCount += it.fetchCount
+		this.FetchTime += it.fetchTime
+		this.RsCount += it.rsCount
+		this.RsTime += it.rsTime
+		this.HttpcTime += it.httpcTime
+
+		if this.Error == 0 && it.err != 0 {
+			this.Error = it.err
+			this.ErrorLevel = it.errorLevel
+			this.Thr = it.thr
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package trace
 
 import (
+	"sync"
 	"time"
 
 	"github.com/whatap/golib/lang/pack"
@@ -13,8 +14,92 @@ import (
 var (
 	connpools [][]int32
 	timer     *time.Timer
+
+	dbPoolLock sync.Mutex
+	dbPools    = make(map[interface{}]*dbPool)
 )
 
+// DBPoolStat is the statistics of the connection pool. (database/sql DBStats)
+// WaitCount, WaitTime, MaxIdleClosed and MaxLifetimeClosed are the total counts since the pool is opened.
+type DBPoolStat struct {
+	MaxOpen           int32
+	Open              int32
+	InUse             int32
+	Idle              int32
+	WaitCount         int64
+	WaitTime          int64 // ms
+	MaxIdleClosed     int64
+	MaxLifetimeClosed int64
+}
+
+// IsFull returns true if all connections of the pool are in use and the new request waits for the connection.
+func (this *DBPoolStat) IsFull() bool {
+	return this.MaxOpen > 0 && this.InUse >= this.MaxOpen
+}
+
+func (this *DBPoolStat) add(o *DBPoolStat) {
+	this.MaxOpen += o.MaxOpen
+	this.Open += o.Open
+	this.InUse += o.InUse
+	this.Idle += o.Idle
+	this.WaitCount += o.WaitCount
+	this.WaitTime += o.WaitTime
+	this.MaxIdleClosed += o.MaxIdleClosed
+	this.MaxLifetimeClosed += o.MaxLifetimeClosed
+}
+
+type dbPool struct {
+	url  string
+	stat func() *DBPoolStat
+}
+
+// RegisterDBConnPool registers the connection pool (key) of the url of which the password is hidden.
+// stat is called periodically to report the pool counters. The pool is unregistered once stat returns nil (closed).
+func RegisterDBConnPool(key interface{}, url string, stat func() *DBPoolStat) {
+	if key == nil || stat == nil {
+		return
+	}
+	data.SendHashText(pack.TEXT_DB_URL, hash.HashStr(url), url)
+
+	dbPoolLock.Lock()
+	defer dbPoolLock.Unlock()
+	dbPools[key] = &dbPool{url: url, stat: stat}
+}
+
+func UnregisterDBConnPool(key interface{}) {
+	dbPoolLock.Lock()
+	defer dbPoolLock.Unlock()
+	delete(dbPools, key)
+}
+
+// GetDBConnPoolStats returns the statistics of the registered pools. The pools of the same url are summed.
+func GetDBConnPoolStats() map[string]*DBPoolStat {
+	dbPoolLock.Lock()
+	keys := make([]interface{}, 0, len(dbPools))
+	pools := make([]*dbPool, 0, len(dbPools))
+	for k, it := range dbPools {
+		keys = append(keys, k)
+		pools = append(pools, it)
+	}
+	dbPoolLock.Unlock()
+
+	rt := make(map[string]*DBPoolStat)
+	for i, it := range pools {
+		st := it.stat()
+		if st == nil {
+			// 종료된 pool 은 등록 해제
+			UnregisterDBConnPool(keys[i])
+			continue
+		}
+		if sum, ok := rt[it.url]; ok {
+			sum.add(st)
+		} else {
+			rt[it.url] = st
+		}
+	}
+	return rt
+}
+
 func AddDBConnPool(pid int32, url string, actCnt int32, inactCnt int32) {
 	SendConnPoolTagCount(pid, url, actCnt, inactCnt)
 
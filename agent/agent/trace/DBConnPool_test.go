@@ -0,0 +1,43 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDBConnPoolStats(t *testing.T) {
+	url := "pool://localhost/stats"
+	closed := false
+	RegisterDBConnPool("a", url, func() *DBPoolStat {
+		return &DBPoolStat{MaxOpen: 2, Open: 2, InUse: 2, WaitCount: 3, WaitTime: 10}
+	})
+	RegisterDBConnPool("b", url, func() *DBPoolStat {
+		if closed {
+			return nil
+		}
+		return &DBPoolStat{MaxOpen: 2, Open: 1, InUse: 0, Idle: 1, WaitCount: 1, WaitTime: 5}
+	})
+	t.Cleanup(func() {
+		UnregisterDBConnPool("a")
+		UnregisterDBConnPool("b")
+	})
+
+	// 같은 url 의 pool 은 합산
+	st := GetDBConnPoolStats()[url]
+	if assert.NotNil(t, st) {
+		assert.Equal(t, DBPoolStat{MaxOpen: 4, Open: 3, InUse: 2, Idle: 1, WaitCount: 4, WaitTime: 15}, *st)
+		assert.False(t, st.IsFull())
+	}
+
+	// nil 을 반환한 pool 은 등록 해제
+	closed = true
+	st = GetDBConnPoolStats()[url]
+	if assert.NotNil(t, st) {
+		assert.True(t, st.IsFull())
+	}
+	dbPoolLock.Lock()
+	_, ok := dbPools["b"]
+	dbPoolLock.Unlock()
+	assert.False(t, ok)
+}
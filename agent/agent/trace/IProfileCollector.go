@@ -11,6 +11,8 @@ type IProfileCollector interface {
 	Push(step step.Step)
 	HasStep() bool
 	Pop(step step.Step)
+	PushChild(parent int32, step step.Step)
+	ClearPushed()
 	GetStep4Error() []step.Step
 	AddHeavy(step step.Step)
 	AddTail(step step.Step)
@@ -17,6 +17,8 @@ type ProfileCircularCollector struct {
 	position    int
 	thisIndex   int32
 	parentIndex int32
+	// PushChild 로 시작되어 아직 Add 되지 않은 step
+	pushed map[step.Step]bool
 
 	conf *config.Config
 	lock sync.Mutex
@@ -34,6 +36,7 @@ func NewProfileCircularCollector() *ProfileCircularCollector {
 	p.position = 0
 	p.thisIndex = 0
 	p.parentIndex = -1
+	p.pushed = make(map[step.Step]bool)
 
 	return p
 }
@@ -59,6 +62,28 @@ func (this *ProfileCircularCollector) Push(st step.Step) {
 	this.thisIndex++
 }
 
+// PushChild reserves the index of st with the given parent. st is collected when it is added at the end of the step.
+func (this *ProfileCircularCollector) PushChild(parent int32, st step.Step) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	st.SetIndex(this.thisIndex)
+	st.SetParent(parent)
+	this.thisIndex++
+	this.pushed[st] = true
+}
+
+// setIndex 는 lock 을 잡은 상태에서 호출. PushChild 로 시작된 step 은 PushChild 에서 받은 index, parent 를 유지
+func (this *ProfileCircularCollector) setIndex(st step.Step) {
+	if this.pushed[st] {
+		delete(this.pushed, st)
+		return
+	}
+	st.SetIndex(this.thisIndex)
+	st.SetParent(this.parentIndex)
+	this.thisIndex++
+}
+
 func (this *ProfileCircularCollector) Add(st step.Step) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
@@ -68,11 +93,9 @@ func (this *ProfileCircularCollector) Add(st step.Step) {
 		this.position = 0
 	}
 
-	st.SetIndex(this.thisIndex)
-	st.SetParent(this.parentIndex)
+	this.setIndex(st)
 	this.buffer[this.position] = st
 	this.position++
-	this.thisIndex++
 }
 
 func (this *ProfileCircularCollector) AddHeavy(st step.Step) {
@@ -84,11 +107,9 @@ func (this *ProfileCircularCollector) AddHeavy(st step.Step) {
 		this.position = 0
 	}
 
-	st.SetIndex(this.thisIndex)
-	st.SetParent(this.parentIndex)
+	this.setIndex(st)
 	this.buffer[this.position] = st
 	this.position++
-	this.thisIndex++
 }
 
 func (this *ProfileCircularCollector) JustAdd(st step.Step) {
@@ -113,14 +134,19 @@ func (this *ProfileCircularCollector) AddTail(st step.Step) {
 		this.position = 0
 	}
 
-	st.SetIndex(this.thisIndex)
-	st.SetParent(this.parentIndex)
+	this.setIndex(st)
 	this.buffer[this.position] = st
 	this.position++
-	this.thisIndex++
 
 }
 
+// ClearPushed drops the steps started with PushChild and not added until the end of the transaction.
+func (this *ProfileCircularCollector) ClearPushed() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.pushed = make(map[step.Step]bool)
+}
+
 func (this *ProfileCircularCollector) Pop(st step.Step) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
@@ -0,0 +1,103 @@
+package trace
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/golib/lang/step"
+)
+
+type pushedCollector interface {
+	IProfileCollector
+	pushedSize() int
+}
+
+func (this *ProfileNormalCollector) pushedSize() int   { return len(this.pushed) }
+func (this *ProfileCircularCollector) pushedSize() int { return len(this.pushed) }
+func (this *ProfileLargeCollector) pushedSize() int    { return len(this.pushed) }
+func (this *ProfileSplitTxCollector) pushedSize() int  { return len(this.pushed) }
+
+func newMethodStep(startTime, elapsed int32) *step.MethodStepX {
+	st := step.NewMethodStepX()
+	st.StartTime = startTime
+	st.Elapsed = elapsed
+	return st
+}
+
+func TestProfileCollectorPushChild(t *testing.T) {
+	for mode := 1; mode <= 4; mode++ {
+		ctx := PoolTraceContext()
+		ctx.Txid = 12345
+		p := NewProfileCollector(mode, ctx).(pushedCollector)
+
+		// top > (m1 > sql), m2
+		top := newMethodStep(0, 10)
+		p.Add(top)
+		m1 := newMethodStep(1, 5)
+		p.PushChild(-1, m1)
+		sq := newMethodStep(2, 1)
+		p.PushChild(m1.GetIndex(), sq)
+		m2 := newMethodStep(7, 1)
+		p.Add(sq)
+		p.Add(m1)
+		p.Add(m2)
+		assert.Equal(t, 0, p.pushedSize(), "mode=%d", mode)
+
+		// index 는 빈 번호 없이 증가
+		steps := p.GetSteps()
+		assert.Equal(t, 4, len(steps), "mode=%d", mode)
+		idx := make([]int, 0)
+		for _, it := range steps {
+			idx = append(idx, int(it.GetIndex()))
+		}
+		sort.Ints(idx)
+		assert.Equal(t, []int{0, 1, 2, 3}, idx, "mode=%d", mode)
+		assert.Equal(t, m1.GetIndex(), sq.GetParent(), "mode=%d", mode)
+		assert.Equal(t, int32(-1), m1.GetParent(), "mode=%d", mode)
+		assert.Equal(t, int32(-1), m2.GetParent(), "mode=%d", mode)
+
+		CloseTraceContext(ctx)
+	}
+}
+
+func TestProfileCollectorClearPushed(t *testing.T) {
+	for mode := 1; mode <= 4; mode++ {
+		ctx := PoolTraceContext()
+		ctx.Txid = 12345
+		p := NewProfileCollector(mode, ctx).(pushedCollector)
+
+		// 종료되지 않은 step
+		m1 := newMethodStep(1, 0)
+		p.PushChild(-1, m1)
+		sq := newMethodStep(2, 1)
+		p.PushChild(m1.GetIndex(), sq)
+		p.Add(sq)
+		assert.Equal(t, 1, p.pushedSize(), "mode=%d", mode)
+
+		p.ClearPushed()
+		assert.Equal(t, 0, p.pushedSize(), "mode=%d", mode)
+
+		CloseTraceContext(ctx)
+	}
+
+	// normal collector 는 종료되지 않은 parent 의 하위 step 을 그 위의 parent 로 이동
+	p := NewProfileNormalCollector()
+	top := newMethodStep(0, 10)
+	p.PushChild(-1, top)
+	m1 := newMethodStep(1, 0)
+	p.PushChild(top.GetIndex(), m1)
+	sq := newMethodStep(2, 1)
+	p.PushChild(m1.GetIndex(), sq)
+	p.Add(sq)
+	p.Add(top)
+	p.ClearPushed()
+
+	steps := p.GetSteps()
+	assert.Equal(t, 2, len(steps))
+	assert.Equal(t, top, steps[0])
+	assert.Equal(t, int32(0), steps[0].GetIndex())
+	assert.Equal(t, sq, steps[1])
+	assert.Equal(t, int32(1), steps[1].GetIndex())
+	assert.Equal(t, int32(0), steps[1].GetParent())
+}
@@ -25,6 +25,8 @@ type ProfileLargeCollector struct {
 	splitCount  int
 	position    int32
 	parentIndex int32
+	// PushChild 로 시작되어 아직 Add 되지 않은 step
+	pushed map[step.Step]bool
 
 	parent *TraceContext
 
@@ -45,6 +47,7 @@ func NewProfileLargeCollector(parent *TraceContext) *ProfileLargeCollector {
 	p.splitCount = 0
 	p.position = 0
 	p.parentIndex = -1
+	p.pushed = make(map[step.Step]bool)
 
 	p.profile = GetInstanceProfileStepThread()
 
@@ -65,6 +68,28 @@ func (this *ProfileLargeCollector) Push(st step.Step) {
 	this.position++
 }
 
+// PushChild reserves the index of st with the given parent. st is collected when it is added at the end of the step.
+func (this *ProfileLargeCollector) PushChild(parent int32, st step.Step) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	st.SetIndex(this.position)
+	st.SetParent(parent)
+	this.position++
+	this.pushed[st] = true
+}
+
+// setIndex 는 lock 을 잡은 상태에서 호출. PushChild 로 시작된 step 은 PushChild 에서 받은 index, parent 를 유지
+func (this *ProfileLargeCollector) setIndex(st step.Step) {
+	if this.pushed[st] {
+		delete(this.pushed, st)
+		return
+	}
+	st.SetIndex(this.position)
+	st.SetParent(this.parentIndex)
+	this.position++
+}
+
 func (this *ProfileLargeCollector) Add(st step.Step) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
@@ -75,11 +100,9 @@ func (this *ProfileLargeCollector) Add(st step.Step) {
 		this.splitCount++
 	}
 
-	st.SetIndex(this.position)
-	st.SetParent(this.parentIndex)
+	this.setIndex(st)
 	this.buffer[this.bufferPos] = st
 	this.bufferPos++
-	this.position++
 }
 
 func (this *ProfileLargeCollector) AddHeavy(st step.Step) {
@@ -92,11 +115,9 @@ func (this *ProfileLargeCollector) AddHeavy(st step.Step) {
 		this.splitCount++
 	}
 
-	st.SetIndex(this.position)
-	st.SetParent(this.parentIndex)
+	this.setIndex(st)
 	this.buffer[this.bufferPos] = st
 	this.bufferPos++
-	this.position++
 }
 
 func (this *ProfileLargeCollector) JustAdd(st step.Step) {
@@ -121,6 +142,13 @@ func (this *ProfileLargeCollector) AddTail(st step.Step) {
 	this.Add(st)
 }
 
+// ClearPushed drops the steps started with PushChild and not added until the end of the transaction.
+func (this *ProfileLargeCollector) ClearPushed() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.pushed = make(map[step.Step]bool)
+}
+
 func (this *ProfileLargeCollector) Pop(st step.Step) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
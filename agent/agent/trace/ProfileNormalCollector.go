@@ -13,6 +13,12 @@ type ProfileNormalCollector struct {
 	buffer       []step.Step
 	position     int32
 	parent_index int32
+	// 다음 step 의 index. drop 된 step 이 있으므로 position 과 다를 수 있음
+	index int32
+	// PushChild 로 시작되어 아직 Add 되지 않은 step
+	pushed map[step.Step]bool
+	// PushChild 로 시작된 step 의 index 와 parent. parent 가 drop 되면 그 위의 parent 를 찾음
+	parents map[int32]int32
 
 	buffer_len int32
 	normal_len int32
@@ -29,6 +35,9 @@ func NewProfileNormalCollector() *ProfileNormalCollector {
 	p.buffer = make([]step.Step, 0, conf.ProfileStepMaxCount)
 	p.position = 0
 	p.parent_index = -1
+	p.index = 0
+	p.pushed = make(map[step.Step]bool)
+	p.parents = make(map[int32]int32)
 	p.buffer_len = conf.ProfileStepMaxCount
 	p.normal_len = conf.ProfileStepNormalCount
 	p.heavy_len = conf.ProfileStepHeavyCount
@@ -46,22 +55,14 @@ func (this *ProfileNormalCollector) Add(st step.Step) {
 	defer this.mutex.Unlock()
 
 	if this.position < this.normal_len {
-		st.SetIndex(this.position)
-		st.SetParent(this.parent_index)
-		//this.buffer[this.position] = st
-		this.buffer = append(this.buffer, st)
-		this.position++
+		this.add(st)
 		//	}
 		// Add 에 heavy_len 로직 추가
 		// Java에서 스텝 시작, 스텝 종료로 나누어서 push, pop 처리하는 부분이 없고, 모두 스텝 종료시에 Add로만 처리하기 때문에 heavy_len 처리 로직 추가.
-	} else if this.position < this.heavy_len {
-		if st.GetElapsed() >= this.heavy_time {
-			st.SetIndex(this.position)
-			st.SetParent(this.parent_index)
-			//this.buffer[this.position] = st
-			this.buffer = append(this.buffer, st)
-			this.position++
-		}
+	} else if this.position < this.heavy_len && st.GetElapsed() >= this.heavy_time {
+		this.add(st)
+	} else {
+		delete(this.pushed, st)
 	}
 }
 
@@ -70,12 +71,23 @@ func (this *ProfileNormalCollector) AddHeavy(st step.Step) {
 	defer this.mutex.Unlock()
 
 	if this.position < this.buffer_len {
-		st.SetIndex(this.position)
+		this.add(st)
+	} else {
+		delete(this.pushed, st)
+	}
+}
+
+// add 는 lock 을 잡은 상태에서 호출. PushChild 로 시작된 step 은 index, parent 를 유지
+func (this *ProfileNormalCollector) add(st step.Step) {
+	if this.pushed[st] {
+		delete(this.pushed, st)
+	} else {
+		st.SetIndex(this.index)
 		st.SetParent(this.parent_index)
-		//this.buffer[this.position] = st
-		this.buffer = append(this.buffer, st)
-		this.position++
+		this.index++
 	}
+	this.buffer = append(this.buffer, st)
+	this.position++
 }
 
 func (this *ProfileNormalCollector) GetSteps__() []step.Step {
@@ -108,17 +120,41 @@ func (this *ProfileNormalCollector) GetSteps() []step.Step {
 		tmp = append(tmp, this.buffer[0:this.position]...)
 	}
 
-	sort.Slice(tmp, func(i, j int) bool {
+	sort.SliceStable(tmp, func(i, j int) bool {
+		if tmp[i].GetStartTime() == tmp[j].GetStartTime() {
+			// 같은 시간에 시작하면 parent 가 먼저
+			return tmp[i].GetElapsed() > tmp[j].GetElapsed()
+		}
 		return tmp[i].GetStartTime() < tmp[j].GetStartTime()
 	})
-	// index 다시 정렬
+	// index 다시 정렬, parent 도 새 index 로 변경
+	newIndex := make(map[int32]int32, len(tmp))
+	for i, it := range tmp {
+		newIndex[it.GetIndex()] = int32(i)
+	}
 	for i, it := range tmp {
 		it.SetIndex(int32(i))
+		it.SetParent(this.resolveParent(newIndex, it.GetParent()))
 	}
 
 	return tmp
 }
 
+// resolveParent returns the new index of the parent. If the parent was dropped, the parent of the parent is used.
+func (this *ProfileNormalCollector) resolveParent(newIndex map[int32]int32, parent int32) int32 {
+	for parent >= 0 {
+		if i, ok := newIndex[parent]; ok {
+			return i
+		}
+		p, ok := this.parents[parent]
+		if !ok || p >= parent {
+			return -1
+		}
+		parent = p
+	}
+	return -1
+}
+
 func (this *ProfileNormalCollector) GetLastSteps(n int) []step.Step {
 	this.mutex.Lock()
 	defer this.mutex.Unlock()
@@ -146,10 +182,11 @@ func (this *ProfileNormalCollector) Push(st step.Step) {
 	defer this.mutex.Unlock()
 
 	if this.position < this.normal_len {
-		st.SetIndex(this.position)
+		st.SetIndex(this.index)
 		st.SetParent(this.parent_index)
-		this.parent_index = this.position
-		this.buffer[this.position] = st
+		this.parent_index = this.index
+		this.index++
+		this.buffer = append(this.buffer, st)
 		this.position++
 	} else {
 		st.SetDrop(true)
@@ -163,11 +200,7 @@ func (this *ProfileNormalCollector) Pop(st step.Step) {
 	if st.GetDrop() {
 		if this.position < this.heavy_len {
 			if st.GetElapsed() >= this.heavy_time {
-				st.SetIndex(this.position)
-				st.SetParent(this.parent_index)
-				//this.buffer[this.position] = st
-				this.buffer = append(this.buffer, st)
-				this.position++
+				this.add(st)
 			}
 		}
 	} else {
@@ -175,15 +208,34 @@ func (this *ProfileNormalCollector) Pop(st step.Step) {
 	}
 }
 
+// PushChild reserves the index of st with the given parent. st is collected when it is added at the end of the step.
+// Unlike Push, the parent is not kept in the collector, so concurrent steps of the transaction can have their own parents.
+func (this *ProfileNormalCollector) PushChild(parent int32, st step.Step) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	st.SetIndex(this.index)
+	st.SetParent(parent)
+	this.parents[this.index] = parent
+	this.index++
+	this.pushed[st] = true
+}
+
+// ClearPushed drops the steps started with PushChild and not added until the end of the transaction.
+// The index of the dropped step is kept in parents, so the children of the step are moved to its parent.
+func (this *ProfileNormalCollector) ClearPushed() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.pushed = make(map[step.Step]bool)
+}
+
 func (this *ProfileNormalCollector) AddTail(st step.Step) {
 	this.mutex.Lock()
 	defer this.mutex.Unlock()
 	if int(this.position) < cap(this.buffer) {
-		st.SetIndex(this.position)
-		st.SetParent(this.parent_index)
-		//this.buffer[this.position] = st
-		this.buffer = append(this.buffer, st)
-		this.position++
+		this.add(st)
+	} else {
+		delete(this.pushed, st)
 	}
 }
 
@@ -0,0 +1,30 @@
+package trace
+
+import (
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/golib/lang/step"
+)
+
+// ProfileNotSampledCollector buffers the steps of the transaction excluded by SampleHead up to sampling_keep_step_count.
+// The steps are sent only if the ended transaction is kept by the tail rules (error, elapsed, sql count) of KeepProfile.
+type ProfileNotSampledCollector struct {
+	*ProfileNormalCollector
+}
+
+func NewProfileNotSampledCollector() *ProfileNotSampledCollector {
+	p := &ProfileNotSampledCollector{NewProfileNormalCollector()}
+
+	// 전송 여부가 종료 시점에 결정되므로 버퍼를 작게 유지
+	max := config.GetConfig().SamplingKeepStepCount
+	if p.buffer_len > max {
+		p.buffer_len = max
+		p.buffer = make([]step.Step, 0, max)
+	}
+	if p.heavy_len > max {
+		p.heavy_len = max
+	}
+	if p.normal_len > max {
+		p.normal_len = max
+	}
+	return p
+}
@@ -28,6 +28,8 @@ type ProfileSplitTxCollector struct {
 
 	parentIndex int32
 	childIndex  int32
+	// PushChild 로 시작되어 아직 Add 되지 않은 step
+	pushed map[step.Step]bool
 
 	parent        *TraceContext
 	parentProfile *ProfileStepThread
@@ -52,6 +54,7 @@ func NewProfileSplitTxCollector(parent *TraceContext) *ProfileSplitTxCollector {
 
 	p.childSplitTxNum = 1
 	p.parentIndex = -1
+	p.pushed = make(map[step.Step]bool)
 
 	p.parentProfile = GetInstanceProfileStepThread()
 	p.profile = GetInstanceProfileVirtualTxThread()
@@ -75,6 +78,28 @@ func (this *ProfileSplitTxCollector) Push(step step.Step) {
 	this.childIndex++
 }
 
+// PushChild reserves the index of st with the given parent. st is collected when it is added at the end of the step.
+func (this *ProfileSplitTxCollector) PushChild(parent int32, st step.Step) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	st.SetIndex(this.childIndex)
+	st.SetParent(parent)
+	this.childIndex++
+	this.pushed[st] = true
+}
+
+// setIndex 는 lock 을 잡은 상태에서 호출. PushChild 로 시작된 step 은 PushChild 에서 받은 index, parent 를 유지
+func (this *ProfileSplitTxCollector) setIndex(st step.Step) {
+	if this.pushed[st] {
+		delete(this.pushed, st)
+		return
+	}
+	st.SetIndex(this.childIndex)
+	st.SetParent(this.parentIndex)
+	this.childIndex++
+}
+
 func (this *ProfileSplitTxCollector) Add(st step.Step) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
@@ -86,9 +111,7 @@ func (this *ProfileSplitTxCollector) Add(st step.Step) {
 		this.childSplitTxNum += 1
 	}
 
-	st.SetParent(this.parentIndex)
-	st.SetIndex(this.childIndex)
-	this.childIndex += 1
+	this.setIndex(st)
 
 	this.bufferChild[this.bufferChildPos] = st
 	this.bufferChildPos += 1
@@ -105,9 +128,7 @@ func (this *ProfileSplitTxCollector) AddHeavy(st step.Step) {
 		this.childSplitTxNum += 1
 	}
 
-	st.SetParent(this.parentIndex)
-	st.SetIndex(this.childIndex)
-	this.childIndex += 1
+	this.setIndex(st)
 
 	this.bufferChild[this.bufferChildPos] = st
 	this.bufferChildPos += 1
@@ -135,6 +156,13 @@ func (this *ProfileSplitTxCollector) AddTail(st step.Step) {
 	this.Add(st)
 }
 
+// ClearPushed drops the steps started with PushChild and not added until the end of the transaction.
+func (this *ProfileSplitTxCollector) ClearPushed() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.pushed = make(map[step.Step]bool)
+}
+
 func (this *ProfileSplitTxCollector) Pop(st step.Step) {
 	this.lock.Lock()
 	defer this.lock.Unlock()
@@ -34,6 +34,8 @@ type ChildTx struct {
 	httpcCount   int32
 	httpcTime    int32
 	dbcTime      int32
+	// Virtual, Async
+	txType string
 }
 
 type ProfileVirtualTxThread struct {
@@ -52,7 +54,7 @@ func GetInstanceProfileVirtualTxThread() *ProfileVirtualTxThread {
 	if profileVirtualTxThread != nil {
 		return profileVirtualTxThread
 	}
-	profileVirtualTxThread := newProfileVirtualTxThread()
+	profileVirtualTxThread = newProfileVirtualTxThread()
 	go profileVirtualTxThread.run()
 	langconf.AddConfObserver("ProfileVirtualTxThread", profileVirtualTxThread)
 
@@ -92,6 +94,25 @@ func (this *ProfileVirtualTxThread) Add(curTime int64, childName string, childTx
 	x.httpcCount = p.HttpcCount
 	x.httpcTime = p.HttpcTime
 	x.dbcTime = p.DbcTime
+	x.txType = "Virtual"
+
+	this.Queue.Put(x)
+}
+
+// AddAsync sends the async work which ended after the parent transaction as a child transaction.
+// childStart is the start offset of the async work from the start of the parent transaction.
+func (this *ProfileVirtualTxThread) AddAsync(curTime int64, childName string, childTxid, parentTxid int64, childStart, childElapsed int,
+	steps []step.Step) {
+
+	x := &ChildTx{}
+	x.etime = curTime
+	x.childStart = int32(childStart)
+	x.parentTxid = parentTxid
+	x.childTxid = childTxid
+	x.steps = steps
+	x.service = childName
+	x.childElapsed = int32(childElapsed)
+	x.txType = "Async"
 
 	this.Queue.Put(x)
 }
@@ -125,8 +146,10 @@ func (this *ProfileVirtualTxThread) run() {
 
 				tx.Fields = value.NewMapValue()
 				tx.Fields.PutLong("ParentTxid", log.parentTxid)
-				tx.Fields.PutString("TxType", "Virtual")
-				tx.Fields.PutLong("FirstStepIdx", int64(log.steps[0].GetIndex()))
+				tx.Fields.PutString("TxType", log.txType)
+				if len(log.steps) > 0 {
+					tx.Fields.PutLong("FirstStepIdx", int64(log.steps[0].GetIndex()))
+				}
 
 				if log.childStart >= 0 {
 					sqlCount := int32(0)
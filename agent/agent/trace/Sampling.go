@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/whatap/go-api/agent/agent/config"
+)
+
+// sampled flag received from the caller (traceparent, x-wtap-mst)
+const (
+	SAMPLED_NONE int8 = 0
+	SAMPLED_YES  int8 = 1
+	SAMPLED_NO   int8 = 2
+)
+
+// SampleHead decides whether the profile of ctx is collected when the transaction starts.
+// The caller's sampled flag is honored, otherwise the rate of the service or sampling_rate is used.
+// The steps of the transaction excluded by sampling are buffered up to sampling_keep_step_count until KeepProfile decides.
+// It is called again when the service name is changed by the route template before any step is collected.
+func SampleHead(ctx *TraceContext) {
+	ctx.NotSampled = sampleOut(ctx)
+	if _, ok := ctx.Profile.(*ProfileNotSampledCollector); ok {
+		if !ctx.NotSampled {
+			ctx.Profile = NewProfileCollector(config.GetConfig().InternalTraceCollectingMode, ctx)
+		}
+	} else if ctx.NotSampled {
+		ctx.Profile = NewProfileNotSampledCollector()
+	}
+}
+
+func sampleOut(ctx *TraceContext) bool {
+	conf := config.GetConfig()
+	if !conf.SamplingEnabled {
+		return false
+	}
+	switch ctx.McallerSampled {
+	case SAMPLED_YES:
+		return false
+	case SAMPLED_NO:
+		return true
+	}
+	rate := GetSamplingRate(ctx.ServiceName)
+	return rate < 100 && rand.Float32()*100 >= rate
+}
+
+// GetSamplingRate returns the sampling rate(%) of the service.
+func GetSamplingRate(service string) float32 {
+	conf := config.GetConfig()
+	for _, it := range conf.SamplingServiceRates {
+		if strings.HasSuffix(it.Service, "*") {
+			if strings.HasPrefix(service, it.Service[:len(it.Service)-1]) {
+				return it.Rate
+			}
+		} else if it.Service == service {
+			return it.Rate
+		}
+	}
+	return conf.SamplingRate
+}
+
+// KeepProfile decides whether the profile of the ended transaction is sent.
+// Transactions excluded by SampleHead are kept if they match the tail rules (error, elapsed, sql count).
+// The kept profile has the steps buffered by ProfileNotSampledCollector. The steps of the others are dropped.
+func KeepProfile(ctx *TraceContext) bool {
+	conf := config.GetConfig()
+	if !conf.SamplingEnabled || !ctx.NotSampled {
+		return true
+	}
+	if conf.SamplingKeepErrorEnabled && ctx.Error != 0 {
+		return true
+	}
+	if conf.SamplingKeepElapsed > 0 && ctx.Elapsed >= conf.SamplingKeepElapsed {
+		return true
+	}
+	if conf.SamplingKeepSqlCount > 0 && ctx.SqlCount > conf.SamplingKeepSqlCount {
+		return true
+	}
+	return false
+}
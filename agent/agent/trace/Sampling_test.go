@@ -0,0 +1,130 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/golib/lang/step"
+)
+
+func setSampling(t *testing.T, rate float32, rates []config.SamplingServiceRate) *config.Config {
+	conf := config.GetConfig()
+	old := conf.ConfSampling
+	conf.SamplingEnabled = true
+	conf.SamplingRate = rate
+	conf.SamplingServiceRates = rates
+	conf.SamplingKeepErrorEnabled = true
+	conf.SamplingKeepElapsed = 0
+	conf.SamplingKeepSqlCount = 0
+	t.Cleanup(func() { conf.ConfSampling = old })
+	return conf
+}
+
+func TestSampleHead(t *testing.T) {
+	setSampling(t, 0, []config.SamplingServiceRate{{Service: "/api/*", Rate: 100}, {Service: "/health", Rate: 0}})
+
+	ctx := PoolTraceContext()
+	defer CloseTraceContext(ctx)
+
+	// sampling_rate=0
+	ctx.ServiceName = "/order"
+	SampleHead(ctx)
+	assert.True(t, ctx.NotSampled)
+
+	// prefix 비교
+	ctx.Clear()
+	ctx.ServiceName = "/api/order"
+	SampleHead(ctx)
+	assert.False(t, ctx.NotSampled)
+
+	// caller 의 sampled flag 를 우선
+	ctx.Clear()
+	ctx.ServiceName = "/api/order"
+	ctx.McallerSampled = SAMPLED_NO
+	SampleHead(ctx)
+	assert.True(t, ctx.NotSampled)
+
+	ctx.Clear()
+	ctx.ServiceName = "/health"
+	ctx.McallerSampled = SAMPLED_YES
+	SampleHead(ctx)
+	assert.False(t, ctx.NotSampled)
+
+	assert.Equal(t, float32(100), GetSamplingRate("/api/user"))
+	assert.Equal(t, float32(0), GetSamplingRate("/health"))
+	assert.Equal(t, float32(0), GetSamplingRate("/healthz"))
+}
+
+func TestSampleHeadDisabled(t *testing.T) {
+	conf := setSampling(t, 0, nil)
+	conf.SamplingEnabled = false
+
+	ctx := PoolTraceContext()
+	defer CloseTraceContext(ctx)
+	ctx.ServiceName = "/order"
+	ctx.McallerSampled = SAMPLED_NO
+	SampleHead(ctx)
+	assert.False(t, ctx.NotSampled)
+	assert.True(t, KeepProfile(ctx))
+}
+
+func TestSampleHeadSteps(t *testing.T) {
+	conf := setSampling(t, 0, nil)
+	conf.SamplingKeepStepCount = 2
+
+	ctx := PoolTraceContext()
+	defer CloseTraceContext(ctx)
+	ctx.ServiceName = "/order"
+	SampleHead(ctx)
+	assert.True(t, ctx.NotSampled)
+
+	// sampling 에서 제외된 트랜잭션은 sampling_keep_step_count 까지 step 을 보관
+	ctx.Profile.Add(step.NewMessageStep())
+	ctx.Profile.AddHeavy(step.NewMessageStep())
+	ctx.Profile.AddTail(step.NewMessageStep())
+	assert.True(t, ctx.Profile.HasStep())
+	assert.Equal(t, 2, len(ctx.Profile.GetSteps()))
+
+	// Clear 후에는 다시 일반 collector 로 수집
+	ctx.Clear()
+	_, ok := ctx.Profile.(*ProfileNotSampledCollector)
+	assert.False(t, ok)
+	ctx.Profile.Add(step.NewMessageStep())
+	assert.True(t, ctx.Profile.HasStep())
+}
+
+func TestKeepProfile(t *testing.T) {
+	conf := setSampling(t, 0, nil)
+
+	ctx := PoolTraceContext()
+	defer CloseTraceContext(ctx)
+
+	ctx.NotSampled = false
+	assert.True(t, KeepProfile(ctx))
+
+	ctx.NotSampled = true
+	assert.False(t, KeepProfile(ctx))
+
+	// error
+	ctx.Error = 1
+	assert.True(t, KeepProfile(ctx))
+	conf.SamplingKeepErrorEnabled = false
+	assert.False(t, KeepProfile(ctx))
+	ctx.Error = 0
+
+	// elapsed
+	conf.SamplingKeepElapsed = 1000
+	ctx.Elapsed = 999
+	assert.False(t, KeepProfile(ctx))
+	ctx.Elapsed = 1000
+	assert.True(t, KeepProfile(ctx))
+	ctx.Elapsed = 0
+
+	// sql count
+	conf.SamplingKeepSqlCount = 10
+	ctx.SqlCount = 10
+	assert.False(t, KeepProfile(ctx))
+	ctx.SqlCount = 11
+	assert.True(t, KeepProfile(ctx))
+}
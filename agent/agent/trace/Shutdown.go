@@ -0,0 +1,59 @@
+package trace
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+var shutdown int32
+
+// IsShutdown reports whether the agent is shutting down and rejects new transactions.
+func IsShutdown() bool {
+	return atomic.LoadInt32(&shutdown) == 1
+}
+
+func SetShutdown(b bool) {
+	if b {
+		atomic.StoreInt32(&shutdown, 1)
+	} else {
+		atomic.StoreInt32(&shutdown, 0)
+	}
+}
+
+// FlushProfile waits until the ended transactions are processed and their profiles are
+// handed to the sender. The profile being processed after it is dequeued is also waited.
+// It returns the number of profiles left when ctx is done.
+func FlushProfile(ctx context.Context) int {
+	for atomic.LoadInt32(&pendingTx) > 0 {
+		select {
+		case <-ctx.Done():
+			return int(atomic.LoadInt32(&pendingTx)) + zipProfileInflight()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if zipProfileThread == nil {
+		return 0
+	}
+	return zipProfileThread.Flush(ctx)
+}
+
+func zipProfileInflight() int {
+	if zipProfileThread == nil {
+		return 0
+	}
+	return int(atomic.LoadInt32(&zipProfileThread.inflight))
+}
+
+// WaitActiveTx waits until the active transactions are ended by their owners.
+// It returns the number of transactions still active when ctx is done.
+func WaitActiveTx(ctx context.Context) int {
+	for ctxTable.Size() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctxTable.Size()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return 0
+}
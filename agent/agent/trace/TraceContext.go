@@ -3,6 +3,7 @@ package trace
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/whatap/golib/lang/step"
 	"github.com/whatap/golib/lang/value"
@@ -20,6 +21,18 @@ type TraceContext struct {
 	// bool
 	IsStaticContents bool
 
+	// shutdown 시 활성 상태였던 트랜잭션. atomic
+	aborted int32
+
+	// MergeAsync 에서 병합된 async context 의 counter. 종료 시 FoldAsync 로 더함
+	asyncLock   sync.Mutex
+	asyncTotals []asyncTotal
+
+	// sampling 에서 제외된 트랜잭션. tail 조건에 해당하지 않으면 프로파일을 보내지 않음
+	NotSampled bool
+	// caller 에서 전달된 sampled flag (SAMPLED_NONE, SAMPLED_YES, SAMPLED_NO)
+	McallerSampled int8
+
 	// int64
 	ProfileSeq int64
 
@@ -135,10 +148,12 @@ type TraceContext struct {
 	// byte
 	ActiveCrud byte
 
-	// int32
+	// int32. sql.Tx 가 다른 goroutine 에서 읽으므로 atomic 으로 변경
 	HttpcCount int32
 	// int32
 	HttpcTime int32
+	// int32. logsink 로 전송한 트랜잭션의 로그 수. atomic
+	LogCount int32
 
 	// 2017.5.23 임재환 추가
 	// string
@@ -241,6 +256,12 @@ func (this *TraceContext) Clear() {
 
 	// bool
 	this.IsStaticContents = false
+	atomic.StoreInt32(&this.aborted, 0)
+	this.asyncLock.Lock()
+	this.asyncTotals = nil
+	this.asyncLock.Unlock()
+	this.NotSampled = false
+	this.McallerSampled = SAMPLED_NONE
 
 	// int64
 	this.ProfileSeq = 0
@@ -360,6 +381,7 @@ func (this *TraceContext) Clear() {
 	this.HttpcCount = 0
 	// int32
 	this.HttpcTime = 0
+	this.LogCount = 0
 
 	// 2017.5.23 임재환 추가
 	// string
@@ -446,6 +468,16 @@ func (this *TraceContext) Clear() {
 // 	return out
 // }
 
+// SetAborted marks the transaction which is still active when the agent shuts down.
+// The owner of the transaction records the abort when it ends the transaction.
+func (this *TraceContext) SetAborted() {
+	atomic.StoreInt32(&this.aborted, 1)
+}
+
+func (this *TraceContext) IsAborted() bool {
+	return atomic.LoadInt32(&this.aborted) == 1
+}
+
 func (this *TraceContext) SetExtraFieldString(key string, val string) {
 	if this.Fields == nil {
 		this.Fields = value.NewMapValue()
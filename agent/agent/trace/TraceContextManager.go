@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	//"time"
 
@@ -375,7 +376,7 @@ func endTx(p *udp.UdpTxEndPack) {
 	tx.Domain = ctx.HttpHostHash
 	tx.Referer = ctx.Referer
 
-	tx.HttpcCount = ctx.HttpcCount
+	tx.HttpcCount = atomic.LoadInt32(&ctx.HttpcCount)
 	tx.HttpcTime = ctx.HttpcTime
 
 	tx.Status = ctx.Status
@@ -389,14 +390,7 @@ func endTx(p *udp.UdpTxEndPack) {
 	// ctx를 보내고 싶지만, import cycle 오류 발생.
 	meter.GetInstanceMeterService().Add(tx, ctx.McallerPcode, ctx.McallerOkind, ctx.McallerOid)
 
-	// DEBUG Queue
-	if conf.QueueProfileEnabled == false {
-		sendTransactionQue <- ctx
-	} else {
-		if profileQueue != nil {
-			profileQueue.PutForce(ctx)
-		}
-	}
+	SendTransaction(ctx)
 
 	// DEBUG
 	//log.Println("EndTx Txid=", p.Txid, "size=", ctxTable.Size(), ",len=", len(sendTransactionQue) )
@@ -613,7 +607,7 @@ func startEndTx(p *udp.UdpTxStartEndPack) {
 	tx.Domain = ctx.HttpHostHash
 	tx.Referer = ctx.Referer
 
-	tx.HttpcCount = ctx.HttpcCount
+	tx.HttpcCount = atomic.LoadInt32(&ctx.HttpcCount)
 	tx.HttpcTime = ctx.HttpcTime
 
 	tx.Status = ctx.Status
@@ -627,14 +621,7 @@ func startEndTx(p *udp.UdpTxStartEndPack) {
 	// ctx를 보내고 싶지만, import cycle 오류 발생.
 	meter.GetInstanceMeterService().Add(tx, ctx.McallerPcode, ctx.McallerOkind, ctx.McallerOid)
 
-	// DEBUG Queue
-	if conf.QueueProfileEnabled == false {
-		sendTransactionQue <- ctx
-	} else {
-		if profileQueue != nil {
-			profileQueue.PutForce(ctx)
-		}
-	}
+	SendTransaction(ctx)
 }
 
 var dbc int32 = hash.HashStr("php")
@@ -966,7 +953,7 @@ func profileHttpc(p *udp.UdpTxHttpcPack) {
 		st.Stack = stackToArray(p.Stack)
 	}
 
-	ctx.HttpcCount++
+	atomic.AddInt32(&ctx.HttpcCount, 1)
 	ctx.HttpcTime += st.Elapsed
 
 	// DEBUG METER
@@ -1262,6 +1249,17 @@ func GetContext(key int64) *TraceContext {
 	}
 	return tc.(*TraceContext)
 }
+
+// LOG_TXID_KEY is the default log tag of the txid. It is changed by logsink_txidtag.
+const LOG_TXID_KEY = "@txid"
+
+// AddLogCount counts the log line sent with the txid of the active transaction.
+func AddLogCount(txid int64) {
+	if ctx := GetContext(txid); ctx != nil {
+		atomic.AddInt32(&ctx.LogCount, 1)
+	}
+}
+
 func PutContext(key int64, v interface{}) interface{} {
 	return ctxTable.Put(key, v)
 }
@@ -1406,12 +1404,16 @@ func MatchQueryString(serviceName string, serviceURL *urlutil.URL, matchUrls []s
 	return sb.ToString()
 }
 func SendTransaction(ctx *TraceContext) {
+	// process 에서 처리가 끝나면 감소
+	atomic.AddInt32(&pendingTx, 1)
 	// DEBUG Queue
 	if conf.QueueProfileEnabled == false {
 		sendTransactionQue <- ctx
 	} else {
 		if profileQueue != nil {
 			profileQueue.PutForce(ctx)
+		} else {
+			atomic.AddInt32(&pendingTx, -1)
 		}
 	}
 }
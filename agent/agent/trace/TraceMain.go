@@ -6,6 +6,7 @@ import (
 	//"log"
 	//"runtime"
 	"sync"
+	"sync/atomic"
 
 	//"runtime/debug"
 	"time"
@@ -38,6 +39,9 @@ var sendTransactionQue chan *TraceContext
 var traceMainLock sync.Mutex
 var profileQueue *queue.RequestQueue
 
+// number of the ended transactions queued or being processed. FlushProfile waits until it is 0
+var pendingTx int32
+
 func StartProfileSender() {
 	conf := config.GetConfig()
 	// DEBUG Queue
@@ -52,6 +56,7 @@ func StartProfileSender() {
 		if profileQueue == nil {
 			profileQueue = queue.NewRequestQueue(int(conf.QueueProfileSize))
 			profileQueue.Overflowed = func(o interface{}) {
+				atomic.AddInt32(&pendingTx, -1)
 				if conf.QueueLogEnabled {
 					logutil.Println("WA550-01", "Profile Queue overflowed")
 				}
@@ -124,6 +129,8 @@ func process() {
 		}
 		ctx = v.(*TraceContext)
 	}
+	// SendProfile 에서 ZipProfileThread 에 추가된 후 감소
+	defer atomic.AddInt32(&pendingTx, -1)
 
 	if ctx.IsStaticContents {
 		//logutil.Infoln("Ignore", "IsStaticContents Resurn")
@@ -329,6 +336,9 @@ func SendProfile(ctx *TraceContext, profile *pack.ProfilePack, rejected bool) {
 		}
 	}
 
+	// sampling (head, tail)
+	keep := KeepProfile(ctx)
+
 	// ServiceRec -> TransactionRec
 	service_rec := stat.GetInstanceStatTranx().GetService(transaction.Service)
 
@@ -413,7 +423,13 @@ func SendProfile(ctx *TraceContext, profile *pack.ProfilePack, rejected bool) {
 			return
 		}
 
+		// sampling 에서 제외
+		if !keep {
+			return
+		}
 		service_rec.Profiled = true
+	} else if !keep {
+		return
 	}
 
 	// JAVA NOT
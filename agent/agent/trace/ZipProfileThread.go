@@ -2,8 +2,10 @@ package trace
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	wio "github.com/whatap/golib/io"
@@ -33,6 +35,12 @@ type ZipProfileThread struct {
 	buffer    bytes.Buffer
 	packCount int
 	firstTime int64
+
+	// buffer 는 run 과 Flush 에서 같이 사용
+	lock     sync.Mutex
+	flushing int32
+	// number of the packs queued or being appended to the buffer by run
+	inflight int32
 }
 
 var zipProfileThread *ZipProfileThread
@@ -65,8 +73,10 @@ func (this *ZipProfileThread) Run() {
 }
 
 func (this *ZipProfileThread) Add(p pack.Pack) {
+	atomic.AddInt32(&this.inflight, 1)
 	ok := this.Queue.Put(p)
 	if ok == false {
+		atomic.AddInt32(&this.inflight, -1)
 		// 큐가 차면 직접 압축없이 보낸다.
 		data.Send(p)
 		this.noZipSent += 1
@@ -74,12 +84,15 @@ func (this *ZipProfileThread) Add(p pack.Pack) {
 }
 
 func (this *ZipProfileThread) AddWait(p pack.Pack, waitTimeForFull int64) {
+	atomic.AddInt32(&this.inflight, 1)
 	ok := this.Queue.Put(p)
 	if ok == false {
 		if waitTimeForFull > 0 {
 			for this.Queue.Put(p) == false {
 				time.Sleep(time.Duration(waitTimeForFull) * time.Millisecond)
 			}
+		} else {
+			atomic.AddInt32(&this.inflight, -1)
 		}
 	}
 }
@@ -88,15 +101,22 @@ func (this *ZipProfileThread) run() {
 	for {
 		tmp := this.Queue.GetTimeout(this.conf.TraceZipMaxWaitTime)
 		func() {
+			this.lock.Lock()
 			defer func() {
+				this.lock.Unlock()
 				if r := recover(); r != nil {
 					logutil.Println("WA111")
 				}
 			}()
 			if tmp != nil {
+				defer atomic.AddInt32(&this.inflight, -1)
 				if log, ok := tmp.(pack.Pack); ok {
 					this.append(log)
 				}
+				// Flush 중에는 버퍼에 쌓아두지 않고 바로 전송
+				if atomic.LoadInt32(&this.flushing) == 1 && this.Queue.Size() == 0 {
+					this.sendAndClear()
+				}
 			} else {
 				this.sendAndClear()
 			}
@@ -193,9 +213,21 @@ func (this *ZipProfileThread) doZip(p *pack.ZipPack) {
 	}
 }
 
-func (this *ZipProfileThread) flush() {
-	for this.Queue.Size() > 0 {
-		time.Sleep(10 * time.Millisecond)
-		this.sendAndClear()
+// Flush waits until the queued profiles including the one being appended by run are zipped and handed to the sender.
+// It returns the number of profiles left when ctx is done.
+func (this *ZipProfileThread) Flush(ctx context.Context) int {
+	atomic.StoreInt32(&this.flushing, 1)
+	defer atomic.StoreInt32(&this.flushing, 0)
+
+	for atomic.LoadInt32(&this.inflight) > 0 {
+		select {
+		case <-ctx.Done():
+			return int(atomic.LoadInt32(&this.inflight))
+		case <-time.After(10 * time.Millisecond):
+		}
 	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.sendAndClear()
+	return 0
 }
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"runtime/debug"
+
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	"github.com/whatap/go-api/agent/util/logutil"
+
+	"github.com/whatap/golib/lang/step"
+	"github.com/whatap/golib/util/dateutil"
+)
+
+// StartAsync starts the async work of the transaction. The async step is a method step which is the parent of the steps
+// of the async work. Steps of the async work are collected in the returned child context.
+func StartAsync(ctx *agenttrace.TraceContext, startTime int64, name string, parent int32) (*agenttrace.TraceContext, *step.MethodStepX) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11410", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
+	if ctx == nil {
+		return nil, nil
+	}
+	st := StartMethod(ctx, startTime, name)
+	PushStep(ctx, parent, st)
+	return ctx.NewAsyncContext(), st
+}
+
+// MergeAsync ends the async step and merges the steps of the child context into the active transaction.
+func MergeAsync(ctx, child *agenttrace.TraceContext, st *step.MethodStepX, elapsed int32, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11420", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
+	if ctx == nil || child == nil || st == nil {
+		return
+	}
+	if err != nil {
+		ProfileError(child, err)
+	}
+	ctx.MergeAsync(child, st.GetIndex())
+	EndMethod(ctx, st, "", elapsed, 0, 0, nil)
+	agenttrace.CloseTraceContext(child)
+}
+
+// SendAsync sends the async work which ended after the transaction as a child transaction of parentTxid.
+func SendAsync(parentTxid int64, child *agenttrace.TraceContext, name string, startTime int64, elapsed int32, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11430", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
+	if child == nil {
+		return
+	}
+	if err != nil {
+		ProfileError(child, err)
+	}
+	steps := child.Profile.GetSteps()
+	agenttrace.GetInstanceProfileVirtualTxThread().AddAsync(dateutil.SystemNow(), name, child.Txid, parentTxid,
+		int(startTime-child.StartTime), int(elapsed), steps)
+	agenttrace.CloseTraceContext(child)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	"github.com/whatap/golib/lang/step"
+)
+
+func TestMergeAsync(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("The code is panic, %v\n stack=%s", r, string(debug.Stack()))
+		}
+	}()
+	ctx := agenttrace.PoolTraceContext()
+	assert.NotNil(t, ctx)
+	ctx.Txid = 12345
+	ctx.StartTime = int64(123456789)
+
+	child, st := StartAsync(ctx, ctx.StartTime+10, "async-1", -1)
+	assert.NotNil(t, child)
+	assert.NotNil(t, st)
+	assert.NotEqual(t, ctx.Txid, child.Txid)
+	assert.Equal(t, ctx.StartTime, child.StartTime)
+
+	sq := StartSql(child, ctx.StartTime+20, "dbhost", "select 1", "")
+	EndSql(child, sq, 5, 0, 0, nil)
+	MergeAsync(ctx, child, st, 30, fmt.Errorf("async error"))
+
+	steps := ctx.Profile.GetSteps()
+	assert.Equal(t, st, steps[0])
+	assert.Equal(t, int32(-1), steps[0].GetParent())
+	assert.Equal(t, int32(10), steps[0].GetStartTime())
+	assert.Equal(t, int32(30), steps[0].GetElapsed())
+	for _, it := range steps[1:] {
+		// sql, error message
+		assert.Equal(t, int32(0), it.GetParent())
+	}
+	assert.IsType(t, step.NewSqlStepX(), steps[1])
+
+	// counter 는 트랜잭션 종료 시 더함
+	assert.Equal(t, int32(0), ctx.SqlCount)
+	ctx.FoldAsync()
+	assert.Equal(t, int32(1), ctx.SqlCount)
+	assert.NotEqual(t, int64(0), ctx.Error)
+	ctx.FoldAsync()
+	assert.Equal(t, int32(1), ctx.SqlCount)
+}
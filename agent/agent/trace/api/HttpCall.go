@@ -2,6 +2,7 @@ package api
 
 import (
 	"runtime/debug"
+	"sync/atomic"
 
 	agentconfig "github.com/whatap/go-api/agent/agent/config"
 	"github.com/whatap/go-api/agent/agent/counter/meter"
@@ -51,6 +52,7 @@ func EndHttpc(ctx *agenttrace.TraceContext, st *step.HttpcStepX, elapsed int32,
 	}
 	conf := agentconfig.GetConfig()
 	st.Elapsed = elapsed
+	st.Status = status
 	st.StepId = stepId
 	thr := ErrorToThr(err)
 
@@ -77,7 +79,7 @@ func EndHttpc(ctx *agenttrace.TraceContext, st *step.HttpcStepX, elapsed int32,
 		st.Stack = thr.ErrorStack
 	}
 
-	ctx.HttpcCount++
+	atomic.AddInt32(&ctx.HttpcCount, 1)
 	ctx.HttpcTime += st.Elapsed
 	// Active status
 	ctx.ActiveHttpcHash = 0
@@ -119,6 +121,7 @@ func ProfileHttpc1(ctx *agenttrace.TraceContext, startTime int64, url string, el
 	st.Host = hash.HashStr(HttpcURL.Host)
 	st.Port = int32(HttpcURL.Port)
 	st.Elapsed = elapsed
+	st.Status = status
 	st.StepId = stepId
 
 	data.SendHashText(pack.TEXT_HTTPC_URL, st.Url, nUrl)
@@ -151,7 +154,7 @@ func ProfileHttpc1(ctx *agenttrace.TraceContext, startTime int64, url string, el
 		st.Stack = thr.ErrorStack
 	}
 
-	ctx.HttpcCount++
+	atomic.AddInt32(&ctx.HttpcCount, 1)
 	ctx.HttpcTime += st.Elapsed
 
 	// DEBUG METER
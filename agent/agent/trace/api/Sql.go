@@ -174,6 +174,53 @@ func EndSql(ctx *agenttrace.TraceContext, st *step.SqlStepX, elapsed int32, cpu,
 		ctx.Profile.AddHeavy(st)
 	}
 }
+
+// ProfileSqlFetch records the rows fetched by the sql step as a result set step.
+func ProfileSqlFetch(ctx *agenttrace.TraceContext, st *step.SqlStepX, startTime int64, fetch, elapsed int32) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11160", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
+	if st == nil {
+		return
+	}
+	meter.GetInstanceMeterSQL().AddFetch(st.Dbc, fetch, int64(elapsed))
+	stat.GetInstanceStatSql().AddFetch(st.Dbc, st.Hash, fetch, int64(elapsed))
+	if ctx == nil {
+		return
+	}
+
+	rs := step.NewResultSetStep()
+	rs.StartTime = int32(startTime - ctx.StartTime)
+	rs.Dbc = st.Dbc
+	rs.SqlHash = st.Hash
+	rs.Elapsed = elapsed
+	rs.Fetch = fetch
+
+	ctx.RsCount += fetch
+	ctx.RsTime += int64(elapsed)
+	ctx.Profile.Add(rs)
+}
+
+// ProfileSqlUpdate records the rows affected by the sql step.
+func ProfileSqlUpdate(ctx *agenttrace.TraceContext, st *step.SqlStepX, updated int32) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11170", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
+	if st == nil {
+		return
+	}
+	stat.GetInstanceStatSql().AddUpdate(st.Dbc, st.Hash, updated)
+	if ctx == nil {
+		return
+	}
+	ctx.JdbcUpdated++
+	ctx.JdbcUpdateRecord += updated
+}
+
 func ProfileSql(ctx *agenttrace.TraceContext, startTime int64, dbhost, sql, sqlParam string, elapsed int32, cpu, mem int64, err error) {
 	st := StartSql(ctx, startTime, dbhost, sql, sqlParam)
 	EndSql(ctx, st, elapsed, cpu, mem, err)
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 
 	agentconfig "github.com/whatap/go-api/agent/agent/config"
 	"github.com/whatap/go-api/agent/agent/counter/meter"
@@ -29,6 +31,9 @@ var (
 	ERROR_MSG_TITLE_HASH = hash.HashStr("ERROR")
 )
 
+// ErrTxAborted is recorded on the transactions which are still active when the agent shuts down.
+var ErrTxAborted = errors.New("transaction aborted by shutdown")
+
 func StartTx(ctx *agenttrace.TraceContext) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -39,6 +44,11 @@ func StartTx(ctx *agenttrace.TraceContext) {
 	if ctx == nil {
 		return
 	}
+	// shutdown 이후 시작된 트랜잭션은 수집하지 않음.
+	if agenttrace.IsShutdown() {
+		ctx.IsStaticContents = true
+		return
+	}
 	conf := agentconfig.GetConfig()
 
 	meter.GetInstanceMeterService().Arrival++
@@ -64,29 +74,15 @@ func StartTx(ctx *agenttrace.TraceContext) {
 		return
 	}
 
-	if conf.ProfileHttpHostEnabled {
-		if ctx.ServiceURL.Host != "" {
-			if strings.HasPrefix(ctx.ServiceName, "/") {
-				ctx.ServiceName = "/" + ctx.ServiceURL.HostPort() + ctx.ServiceName
-			} else {
-				ctx.ServiceName = "/" + ctx.ServiceURL.HostPort() + "/" + ctx.ServiceName
-			}
-			ctx.ServiceHash = hash.HashStr(ctx.ServiceName)
-		}
-	}
-
-	if conf.QueryStringEnabled {
-		qs := agenttrace.MatchQueryString(normalizeServiceName, ctx.ServiceURL, conf.QueryStringUrls, conf.QueryStringKeys)
-		if qs != "" {
-			ctx.ServiceName = ctx.ServiceName + "?" + qs
-			ctx.ServiceHash = hash.HashStr(ctx.ServiceName)
-		}
-	}
+	addServiceHostQuery(ctx, normalizeServiceName)
 
 	if !ctx.IsStaticContents {
 		ctx.IsStaticContents = agentconfig.IsIgnoreTrace(ctx.ServiceHash, ctx.ServiceName)
 	}
 
+	// head sampling
+	agenttrace.SampleHead(ctx)
+
 	// 도메인
 	ctx.HttpHost = ctx.ServiceURL.HostPort()
 	ctx.HttpHostHash = hash.HashStr(ctx.ServiceURL.HostPort())
@@ -111,6 +107,66 @@ func StartTx(ctx *agenttrace.TraceContext) {
 	agenttrace.PutContext(ctx.Txid, ctx)
 }
 
+// addServiceHostQuery adds the host (profile_http_host_enabled) and the query string (query_string_enabled) to the service name.
+func addServiceHostQuery(ctx *agenttrace.TraceContext, normalizeServiceName string) {
+	conf := agentconfig.GetConfig()
+	if conf.ProfileHttpHostEnabled {
+		if ctx.ServiceURL.Host != "" {
+			if strings.HasPrefix(ctx.ServiceName, "/") {
+				ctx.ServiceName = "/" + ctx.ServiceURL.HostPort() + ctx.ServiceName
+			} else {
+				ctx.ServiceName = "/" + ctx.ServiceURL.HostPort() + "/" + ctx.ServiceName
+			}
+			ctx.ServiceHash = hash.HashStr(ctx.ServiceName)
+		}
+	}
+
+	if conf.QueryStringEnabled {
+		qs := agenttrace.MatchQueryString(normalizeServiceName, ctx.ServiceURL, conf.QueryStringUrls, conf.QueryStringKeys)
+		if qs != "" {
+			ctx.ServiceName = ctx.ServiceName + "?" + qs
+			ctx.ServiceHash = hash.HashStr(ctx.ServiceName)
+		}
+	}
+}
+
+// UpdateServiceName names the started transaction after the route template of the web framework. ex) /users/:id
+// The path of the request is kept as the OriginURL message.
+// The sampling is decided again with the new name only if no step is profiled yet. Otherwise the first decision is kept.
+func UpdateServiceName(ctx *agenttrace.TraceContext, name string) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11090", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
+	if ctx == nil || ctx.ServiceURL == nil || name == "" {
+		return
+	}
+	conf := agentconfig.GetConfig()
+	path := ctx.ServiceURL.Path
+	// step 이 수집된 후에는 collector 를 바꾸지 않도록 처음 판단을 유지
+	resample := ctx.Profile == nil || !ctx.Profile.HasStep()
+
+	ctx.ServiceName = name
+	ctx.ServiceHash = hash.HashStr(ctx.ServiceName)
+	addServiceHostQuery(ctx, name)
+	data.SendHashText(pack.TEXT_SERVICE, ctx.ServiceHash, ctx.ServiceName)
+
+	if !ctx.IsStaticContents {
+		ctx.IsStaticContents = agentconfig.IsIgnoreTrace(ctx.ServiceHash, ctx.ServiceName)
+	}
+	if resample {
+		// service 별 sampling 비율을 경로 템플릿으로 다시 판단
+		agenttrace.SampleHead(ctx)
+	}
+
+	// StartTx 에서 정규화된 경우에는 이미 OriginURL 이 추가됨
+	added := conf.TraceNormalizeEnabled && agenttrace.GetInstanceServiceURLPatternDetector().Normalize(path) != path
+	if !added && path != name {
+		agenttrace.AddMessage(ctx, 0, 0, "OriginURL", "", path, 0, false)
+	}
+}
+
 func EndTx(ctx *agenttrace.TraceContext) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -121,9 +177,74 @@ func EndTx(ctx *agenttrace.TraceContext) {
 	if ctx == nil {
 		return
 	}
+	// shutdown 에서 이미 종료되어 전송된 트랜잭션
+	if agenttrace.IsShutdown() && agenttrace.RemoveContext(ctx.Txid) == nil {
+		return
+	}
+	// shutdown 시 활성 상태였던 트랜잭션
+	if ctx.IsAborted() {
+		ProfileError(ctx, ErrTxAborted)
+	}
+	endTx(ctx)
+}
+
+// AbortActiveTx marks every active transaction as aborted and returns the count.
+// The transactions are ended by their owners, which record ErrTxAborted in EndTx,
+// or by EndAbortedTx after the shutdown deadline.
+func AbortActiveTx() int {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11021", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
 
+	n := 0
+	en := agenttrace.GetContextEnumeration()
+	for en.HasMoreElements() {
+		if ctx, ok := en.NextElement().(*agenttrace.TraceContext); ok && ctx != nil {
+			ctx.SetAborted()
+			n++
+		}
+	}
+	return n
+}
+
+// EndAbortedTx ends and sends the aborted transactions which are not ended by their owners
+// with ErrTxAborted and returns the count. The owners ending them later are ignored.
+func EndAbortedTx() int {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11022", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
+
+	n := 0
+	en := agenttrace.GetContextEnumeration()
+	for en.HasMoreElements() {
+		ctx, ok := en.NextElement().(*agenttrace.TraceContext)
+		if !ok || ctx == nil || !ctx.IsAborted() {
+			continue
+		}
+		// owner 의 EndTx 와 동시에 종료하지 않도록 ctxTable 에서 제거한 쪽만 종료
+		if agenttrace.RemoveContext(ctx.Txid) == nil {
+			continue
+		}
+		ProfileError(ctx, ErrTxAborted)
+		endTx(ctx)
+		n++
+	}
+	return n
+}
+
+func endTx(ctx *agenttrace.TraceContext) {
 	agenttrace.RemoveContext(ctx.Txid)
+	// async 작업의 counter
+	ctx.FoldAsync()
 	ctx.Elapsed = int32(dateutil.SystemNow() - ctx.StartTime)
+	// PushStep 으로 시작되고 종료되지 않은 step
+	if ctx.Profile != nil {
+		ctx.Profile.ClearPushed()
+	}
 
 	if ctx.IsStaticContents {
 		return
@@ -192,7 +313,7 @@ func EndTx(ctx *agenttrace.TraceContext) {
 	tx.Domain = ctx.HttpHostHash
 	tx.Referer = ctx.Referer
 
-	tx.HttpcCount = ctx.HttpcCount
+	tx.HttpcCount = atomic.LoadInt32(&ctx.HttpcCount)
 	tx.HttpcTime = ctx.HttpcTime
 
 	tx.Status = ctx.Status
@@ -209,6 +330,20 @@ func EndTx(ctx *agenttrace.TraceContext) {
 	agenttrace.SendTransaction(ctx)
 }
 
+// PushStep reserves the index of the started step st as a child of the parent step index (-1 : top level).
+// st keeps the index and the parent when it is added to the profile at the end of the step.
+func PushStep(ctx *agenttrace.TraceContext, parent int32, st step.Step) {
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-API11080", " Recover ", r, "/n", string(debug.Stack()))
+		}
+	}()
+	if ctx == nil || st == nil || ctx.Profile == nil {
+		return
+	}
+	ctx.Profile.PushChild(parent, st)
+}
+
 func ProfileMsg(ctx *agenttrace.TraceContext, title, message string, elapsed, value int32) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -9,7 +9,7 @@ import (
 	agentconfig "github.com/whatap/go-api/agent/agent/config"
 	agenttrace "github.com/whatap/go-api/agent/agent/trace"
 
-	// "github.com/whatap/golib/lang/step"
+	"github.com/whatap/golib/lang/step"
 
 	"github.com/whatap/golib/util/hash"
 	"github.com/whatap/golib/util/urlutil"
@@ -87,6 +87,25 @@ func TestEndTx(t *testing.T) {
 	// assert.IsType(t, *step.HttpcStepX, st)
 }
 
+func TestEndAbortedTx(t *testing.T) {
+	ctx := agenttrace.PoolTraceContext()
+	ctx.Txid = 12346
+	ctx.ServiceURL = urlutil.NewURL("http://aaa.bbb.com/abort")
+	StartTx(ctx)
+
+	agenttrace.SetShutdown(true)
+	t.Cleanup(func() { agenttrace.SetShutdown(false) })
+
+	assert.True(t, AbortActiveTx() >= 1)
+	assert.True(t, ctx.IsAborted())
+	assert.Equal(t, 1, EndAbortedTx())
+	assert.False(t, agenttrace.ContainsTxid(12346))
+
+	// shutdown 에서 종료된 트랜잭션은 다시 종료하지 않음
+	assert.Equal(t, 0, EndAbortedTx())
+	EndTx(ctx)
+}
+
 func TestEndTxNilContext(t *testing.T) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -155,3 +174,97 @@ func TestProfileErrorNilContext(t *testing.T) {
 
 	//assert.Equal(t, h, ctx.Error)
 }
+
+func TestPushStep(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("The code is panic, %v\n stack=%s", r, string(debug.Stack()))
+		}
+	}()
+	ctx := agenttrace.PoolTraceContext()
+	assert.NotNil(t, ctx)
+	ctx.Txid = 12345
+	ctx.StartTime = int64(123456789)
+
+	// method1 > method2 > sql
+	m1 := StartMethod(ctx, ctx.StartTime+1, "method1")
+	PushStep(ctx, -1, m1)
+	m2 := StartMethod(ctx, ctx.StartTime+2, "method2")
+	PushStep(ctx, m1.GetIndex(), m2)
+	sq := StartSql(ctx, ctx.StartTime+3, "dbhost", "select 1", "")
+	PushStep(ctx, m2.GetIndex(), sq)
+	// 하위 step 이 먼저 종료
+	EndSql(ctx, sq, 1, 0, 0, nil)
+	EndMethod(ctx, m2, "", 2, 0, 0, nil)
+	EndMethod(ctx, m1, "", 3, 0, 0, nil)
+
+	steps := ctx.Profile.GetSteps()
+	assert.Equal(t, 3, len(steps))
+	assert.Equal(t, m1, steps[0])
+	assert.Equal(t, int32(-1), steps[0].GetParent())
+	assert.Equal(t, m2, steps[1])
+	assert.Equal(t, int32(0), steps[1].GetParent())
+	assert.Equal(t, sq, steps[2])
+	assert.Equal(t, int32(1), steps[2].GetParent())
+}
+
+func TestUpdateServiceName(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("The code is panic, %v\n stack=%s", r, string(debug.Stack()))
+		}
+	}()
+	ctx := agenttrace.PoolTraceContext()
+	assert.NotNil(t, ctx)
+	ctx.StartTime = int64(123456789)
+	ctx.Txid = 12345
+	ctx.ServiceURL = urlutil.NewURL("/users/123")
+	StartTx(ctx)
+
+	UpdateServiceName(ctx, "/users/:id")
+	assert.Equal(t, "/users/:id", ctx.ServiceName)
+	assert.Equal(t, hash.HashStr("/users/:id"), ctx.ServiceHash)
+
+	// 요청 url 은 OriginURL 메시지로 기록
+	found := false
+	for _, it := range ctx.Profile.GetSteps() {
+		if st, ok := it.(*step.MessageStep); ok && st.Desc == "/users/123" {
+			found = true
+		}
+	}
+	assert.True(t, found)
+
+	// 빈 이름은 무시
+	UpdateServiceName(ctx, "")
+	assert.Equal(t, "/users/:id", ctx.ServiceName)
+}
+
+func TestUpdateServiceNameSampling(t *testing.T) {
+	conf := agentconfig.GetConfig()
+	old := conf.ConfSampling
+	conf.SamplingEnabled = true
+	conf.SamplingRate = 100
+	conf.SamplingServiceRates = []agentconfig.SamplingServiceRate{{Service: "/orders/:id", Rate: 0}}
+	t.Cleanup(func() { conf.ConfSampling = old })
+
+	// step 이 없으면 경로 템플릿으로 다시 판단
+	ctx := agenttrace.PoolTraceContext()
+	ctx.Txid = 12347
+	ctx.ServiceURL = urlutil.NewURL("/orders/1")
+	StartTx(ctx)
+	assert.False(t, ctx.NotSampled)
+	UpdateServiceName(ctx, "/orders/:id")
+	assert.True(t, ctx.NotSampled)
+	EndTx(ctx)
+
+	// step 이 수집된 후에는 처음 판단을 유지
+	ctx = agenttrace.PoolTraceContext()
+	ctx.Txid = 12348
+	ctx.ServiceURL = urlutil.NewURL("/orders/2")
+	StartTx(ctx)
+	ProfileMsg(ctx, "title", "msg", 0, 0)
+	UpdateServiceName(ctx, "/orders/:id")
+	assert.False(t, ctx.NotSampled)
+	assert.True(t, len(ctx.Profile.GetSteps()) >= 2)
+	EndTx(ctx)
+}
@@ -2,8 +2,11 @@ package watch
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/whatap/golib/lang/pack"
+	"github.com/whatap/golib/util/hexa32"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
 	"github.com/whatap/go-api/agent/util/logutil"
 )
 
@@ -31,6 +34,8 @@ func ApplyAppLog(p *pack.LogSinkPack, line string) (ret bool) {
 			for k, v := range tags {
 				if k == TxIdTag {
 					p.Category = AppLogCategory
+					v = NormalizeTxid(v)
+					addLogCount(v)
 				}
 				p.Tags.PutString(k, v)
 			}
@@ -44,6 +49,23 @@ func ApplyAppLog(p *pack.LogSinkPack, line string) (ret bool) {
 	return
 }
 
+// NormalizeTxid returns the txid in hexa32 so that the log is indexed by the same txid.
+// The decimal txid of trace.GetTxid is converted to hexa32.
+func NormalizeTxid(txid string) string {
+	txid = strings.TrimSpace(txid)
+	if n := hexa32.ToLong32(txid); n != 0 {
+		return hexa32.ToString32(n)
+	}
+	return txid
+}
+
+// addLogCount counts the log line of the active transaction of the txid in hexa32.
+func addLogCount(txid string) {
+	if n := hexa32.ToLong32(txid); n != 0 {
+		agenttrace.AddLogCount(n)
+	}
+}
+
 func validateTxHeader(line string) (ret bool) {
 
 	matches := AppLogPattern.FindAllStringSubmatch(line, -1)
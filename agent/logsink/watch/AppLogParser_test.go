@@ -0,0 +1,61 @@
+package watch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	"github.com/whatap/golib/lang/pack"
+)
+
+func TestNormalizeTxid(t *testing.T) {
+	tests := []struct {
+		txid       string
+		normalized string
+	}{
+		{"z40ch6vn9j7ln8", "z40ch6vn9j7ln8"},
+		{" x1234 ", "x1234"},
+		// trace.GetTxid 의 10 진수
+		{"1234567890", "x14pc0mi"},
+		{"-1234", "z16i"},
+		{"", ""},
+		{"!!", "!!"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.normalized, NormalizeTxid(tt.txid), tt.txid)
+	}
+}
+
+// activeTx registers the active transaction of txid.
+func activeTx(t *testing.T, txid int64) *agenttrace.TraceContext {
+	ctx := agenttrace.PoolTraceContext()
+	ctx.Txid = txid
+	agenttrace.PutContext(txid, ctx)
+	t.Cleanup(func() {
+		agenttrace.RemoveContext(txid)
+		agenttrace.CloseTraceContext(ctx)
+	})
+	return ctx
+}
+
+func TestApplyAppLogCount(t *testing.T) {
+	ctx := activeTx(t, 1234567890)
+
+	p := pack.NewLogSinkPack()
+	p.Category = "app.log"
+	assert.True(t, ApplyAppLog(p, `order failed -- {"@txid":"1234567890","@mtid":"x1"} --`))
+	assert.Equal(t, AppLogCategory, p.Category)
+	assert.Equal(t, "x14pc0mi", p.Tags.GetString(TxIdTag))
+	assert.Equal(t, "x1", p.Tags.GetString("@mtid"))
+	assert.Equal(t, int32(1), ctx.LogCount)
+
+	// 종료된 트랜잭션, txid 가 없는 로그는 기록하지 않음
+	assert.True(t, ApplyAppLog(pack.NewLogSinkPack(), `-- {"@txid":"x1234"} --`))
+	assert.False(t, ApplyAppLog(pack.NewLogSinkPack(), `plain`))
+	assert.Equal(t, int32(1), ctx.LogCount)
+
+	// 파서로 찾은 @txid
+	parser := newTestParser(PARSER_LOGFMT, "", "")
+	parser.Apply(pack.NewLogSinkPack(), `msg=x @txid=x14pc0mi`)
+	assert.Equal(t, int32(2), ctx.LogCount)
+}
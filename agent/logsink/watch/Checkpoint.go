@@ -0,0 +1,125 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/util/logutil"
+	"github.com/whatap/golib/util/dateutil"
+)
+
+// 파일 앞부분의 crc 로 copytruncate 등으로 내용이 바뀐 파일을 확인
+const headSize = 256
+
+// Checkpoint is the position of the watched file stored under {WHATAP_HOME}/logsink/checkpoint.
+// Dev and Ino identify the file across the restart. (0 if the platform does not support)
+type Checkpoint struct {
+	File    string `json:"file"`
+	Dev     uint64 `json:"dev"`
+	Ino     uint64 `json:"ino"`
+	Pos     int64  `json:"pos"`
+	Head    uint32 `json:"head"`
+	HeadLen int    `json:"head_len"`
+	Time    int64  `json:"time"`
+	// DateFormatFile 의 마지막 파일명
+	Current string `json:"current,omitempty"`
+}
+
+func checkpointDir() string {
+	conf := config.GetConfig()
+	if conf.WatchLogCheckpointPath != "" {
+		return conf.WatchLogCheckpointPath
+	}
+	return filepath.Join(config.GetWhatapHome(), "logsink", "checkpoint")
+}
+
+func checkpointFile(fileName string) string {
+	if abs, err := filepath.Abs(fileName); err == nil {
+		fileName = abs
+	}
+	h := fnv.New64a()
+	h.Write([]byte(fileName))
+	return filepath.Join(checkpointDir(), fmt.Sprintf("%016x.json", h.Sum64()))
+}
+
+// LoadCheckpoint returns the checkpoint of the file. It returns nil if the checkpoint is disabled or not found.
+func LoadCheckpoint(fileName string) *Checkpoint {
+	conf := config.GetConfig()
+	if !conf.WatchLogCheckpointEnabled {
+		return nil
+	}
+	b, err := ioutil.ReadFile(checkpointFile(fileName))
+	if err != nil {
+		return nil
+	}
+	cp := new(Checkpoint)
+	if err := json.Unmarshal(b, cp); err != nil || cp.File != fileName {
+		return nil
+	}
+	return cp
+}
+
+// Save writes the checkpoint to the temporary file and renames it so that the checkpoint is not broken.
+func (this *Checkpoint) Save() error {
+	conf := config.GetConfig()
+	if !conf.WatchLogCheckpointEnabled {
+		return nil
+	}
+	this.Time = dateutil.Now()
+	b, err := json.Marshal(this)
+	if err != nil {
+		return err
+	}
+	path := checkpointFile(this.File)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// RemoveCheckpoint removes the checkpoint of the file which is not watched any more.
+func RemoveCheckpoint(fileName string) {
+	if err := os.Remove(checkpointFile(fileName)); err != nil && !os.IsNotExist(err) {
+		logutil.Println("WA-LOGS-008", "Remove checkpoint Error ", fileName, ",err=", err)
+	}
+}
+
+// IsSameFile returns true if the checkpoint is of the opened file f. The file is regarded as different
+// if the device and inode are changed (rotated) or the head of the file is changed (truncated).
+func (this *Checkpoint) IsSameFile(f *os.File, fi os.FileInfo) bool {
+	if dev, ino, ok := fileId(fi); ok && (dev != this.Dev || ino != this.Ino) {
+		return false
+	}
+	if fi.Size() < this.Pos {
+		return false
+	}
+	if this.HeadLen > 0 {
+		if head, n := fileHead(f, int64(this.HeadLen)); n != this.HeadLen || head != this.Head {
+			return false
+		}
+	}
+	return true
+}
+
+// fileHead returns the crc of the first bytes of the file up to min(size, headSize).
+func fileHead(f *os.File, size int64) (uint32, int) {
+	if size > headSize {
+		size = headSize
+	}
+	if size <= 0 {
+		return 0, 0
+	}
+	buf := make([]byte, size)
+	n, _ := f.ReadAt(buf, 0)
+	return crc32.ChecksumIEEE(buf[:n]), n
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package watch
+
+import (
+	"os"
+)
+
+// fileId is not supported. The rotation is detected by os.SameFile while the file is opened,
+// and by the head of the file after the restart.
+func fileId(fi os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
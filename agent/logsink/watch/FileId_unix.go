@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package watch
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileId returns the device and inode of the file.
+func fileId(fi os.FileInfo) (dev, ino uint64, ok bool) {
+	if fi == nil {
+		return 0, 0, false
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,166 @@
+package watch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/strftime"
+
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/util/logutil"
+)
+
+// FilePattern is the entry of logsink.files which matches several files.
+//
+//	/var/log/app/*.log    glob
+//	/var/log/app/**/*.json  ** 는 0 개 이상의 디렉토리
+//	/var/log/app/         디렉토리의 파일 (하위 디렉토리 제외)
+//
+// The matched files are filtered by logsink.files.include and logsink.files.exclude with the file name.
+type FilePattern struct {
+	Entry string
+	// 처음 찾은 파일은 checkpoint 가 없으면 끝부터 읽고, 이후 생성된 파일은 처음부터 읽음
+	discovered bool
+}
+
+func NewFilePattern(entry string) *FilePattern {
+	p := new(FilePattern)
+	p.Entry = entry
+	return p
+}
+
+// IsFilePattern returns true if the entry of logsink.files is a glob pattern or a directory.
+func IsFilePattern(entry string) bool {
+	if strings.ContainsAny(entry, "*?[") {
+		return true
+	}
+	if strings.HasSuffix(entry, "/") || strings.HasSuffix(entry, string(filepath.Separator)) {
+		return true
+	}
+	fi, err := os.Stat(entry)
+	return err == nil && fi.IsDir()
+}
+
+// Match returns the files matched by the pattern. strftime format of the pattern is applied first.
+func (this *FilePattern) Match() []string {
+	pattern := this.Entry
+	if str, err := strftime.Format(pattern, time.Now()); err == nil {
+		pattern = str
+	}
+	pattern = filepath.Clean(pattern)
+	if fi, err := os.Stat(pattern); err == nil && fi.IsDir() {
+		pattern = filepath.Join(pattern, "*")
+	}
+
+	root := patternRoot(pattern)
+	patternParts := splitPath(relPath(root, pattern))
+	rt := make([]string, 0)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 권한이 없는 디렉토리 등은 제외
+			if d != nil && d.IsDir() && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel := splitPath(relPath(root, path))
+		if d.IsDir() {
+			if path != root && !matchPrefix(patternParts, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && matchParts(patternParts, rel) && isIncluded(d.Name()) {
+			rt = append(rt, path)
+		}
+		return nil
+	})
+	return rt
+}
+
+// patternRoot returns the directory of the pattern without the wildcards.
+func patternRoot(pattern string) string {
+	i := strings.IndexAny(pattern, "*?[")
+	if i < 0 {
+		return filepath.Dir(pattern)
+	}
+	return filepath.Dir(pattern[:i+1])
+}
+
+func relPath(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil && rel != "." {
+		return rel
+	}
+	return ""
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// matchParts returns true if all parts of the path match the pattern. ** matches zero or more directories.
+func matchParts(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchParts(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+		return false
+	}
+	return matchParts(pattern[1:], path[1:])
+}
+
+// matchPrefix returns true if the directory may contain the files matched by the pattern.
+func matchPrefix(pattern, dir []string) bool {
+	for i, it := range dir {
+		if i < len(pattern) && pattern[i] == "**" {
+			return true
+		}
+		// 마지막 부분은 파일명
+		if i >= len(pattern)-1 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[i], it); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isIncluded filters the file name by logsink.files.include and logsink.files.exclude.
+func isIncluded(name string) bool {
+	conf := config.GetConfig()
+	for _, it := range conf.LogSinkFilesExclude {
+		if ok, _ := filepath.Match(it, name); ok {
+			return false
+		}
+	}
+	if len(conf.LogSinkFilesInclude) == 0 {
+		return true
+	}
+	for _, it := range conf.LogSinkFilesInclude {
+		if ok, err := filepath.Match(it, name); ok {
+			return true
+		} else if err != nil && conf.DebugLogSinkEnabled {
+			logutil.Infoln("WA-LOGS-301", "invalid include pattern ", it, ", err=", err)
+		}
+	}
+	return false
+}
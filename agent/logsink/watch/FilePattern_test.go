@@ -0,0 +1,113 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/agent/agent/config"
+)
+
+// setupFiles creates the files under the temporary directory.
+func setupFiles(t *testing.T, files ...string) string {
+	dir := t.TempDir()
+	for _, it := range files {
+		path := filepath.Join(dir, filepath.FromSlash(it))
+		if !assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755)) || !assert.Nil(t, ioutil.WriteFile(path, []byte("a\n"), 0644)) {
+			t.FailNow()
+		}
+	}
+	return dir
+}
+
+func setFilter(t *testing.T, include, exclude []string) {
+	conf := config.GetConfig()
+	oldInclude, oldExclude := conf.LogSinkFilesInclude, conf.LogSinkFilesExclude
+	conf.LogSinkFilesInclude, conf.LogSinkFilesExclude = include, exclude
+	t.Cleanup(func() { conf.LogSinkFilesInclude, conf.LogSinkFilesExclude = oldInclude, oldExclude })
+}
+
+func matchFiles(dir, entry string) []string {
+	rt := make([]string, 0)
+	for _, it := range NewFilePattern(filepath.Join(dir, filepath.FromSlash(entry))).Match() {
+		rel, _ := filepath.Rel(dir, it)
+		rt = append(rt, filepath.ToSlash(rel))
+	}
+	sort.Strings(rt)
+	return rt
+}
+
+func TestFilePatternMatch(t *testing.T) {
+	dir := setupFiles(t, "a.log", "b.json", "c.log.gz", "sub/d.log", "sub/deep/e.log", "sub/deep/f.json", "other/g.log")
+	setFilter(t, []string{}, []string{"*.gz"})
+
+	tests := []struct {
+		entry string
+		files []string
+	}{
+		{"*.log", []string{"a.log"}},
+		{"*", []string{"a.log", "b.json"}},
+		{"*/*.log", []string{"other/g.log", "sub/d.log"}},
+		{"sub/*/*.json", []string{"sub/deep/f.json"}},
+		// ** 는 0 개 이상의 디렉토리
+		{"**/*.log", []string{"a.log", "other/g.log", "sub/d.log", "sub/deep/e.log"}},
+		{"sub/**/*.json", []string{"sub/deep/f.json"}},
+		{"**/deep/*", []string{"sub/deep/e.log", "sub/deep/f.json"}},
+		{"s?b/[d]*.log", []string{"sub/d.log"}},
+		// 디렉토리는 하위 디렉토리를 제외한 파일
+		{"sub/", []string{"sub/d.log"}},
+		{"sub", []string{"sub/d.log"}},
+		{"none/*.log", []string{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.files, matchFiles(dir, tt.entry), tt.entry)
+	}
+}
+
+func TestFilePatternFilter(t *testing.T) {
+	dir := setupFiles(t, "a.log", "b.json", "c.log.gz", "sub/d.log", "sub/e.txt")
+
+	tests := []struct {
+		include []string
+		exclude []string
+		files   []string
+	}{
+		{[]string{}, []string{}, []string{"a.log", "b.json", "c.log.gz", "sub/d.log", "sub/e.txt"}},
+		{[]string{"*.log"}, []string{}, []string{"a.log", "sub/d.log"}},
+		{[]string{"*.log", "*.json"}, []string{}, []string{"a.log", "b.json", "sub/d.log"}},
+		{[]string{}, []string{"*.gz", "*.txt"}, []string{"a.log", "b.json", "sub/d.log"}},
+		// exclude 가 우선
+		{[]string{"*.log*"}, []string{"*.gz", "d.*"}, []string{"a.log"}},
+		// 잘못된 include 패턴은 제외
+		{[]string{"[", "*.json"}, []string{}, []string{"b.json"}},
+	}
+	for _, tt := range tests {
+		setFilter(t, tt.include, tt.exclude)
+		assert.Equal(t, tt.files, matchFiles(dir, "**/*"), "include=%v, exclude=%v", tt.include, tt.exclude)
+	}
+}
+
+func TestIsFilePattern(t *testing.T) {
+	dir := setupFiles(t, "a.log", "sub/b.log")
+	tests := []struct {
+		entry string
+		ok    bool
+	}{
+		{filepath.Join(dir, "*.log"), true},
+		{filepath.Join(dir, "**", "b.log"), true},
+		{filepath.Join(dir, "a?.log"), true},
+		{filepath.Join(dir, "[ab].log"), true},
+		{filepath.Join(dir, "sub") + "/", true},
+		{filepath.Join(dir, "sub"), true},
+		{filepath.Join(dir, "a.log"), false},
+		{filepath.Join(dir, "none.log"), false},
+		// strftime 형식의 파일
+		{filepath.Join(dir, "app-%Y%m%d.log"), false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ok, IsFilePattern(tt.entry), tt.entry)
+	}
+}
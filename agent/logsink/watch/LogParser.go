@@ -0,0 +1,360 @@
+package watch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/util/logutil"
+	"github.com/whatap/golib/lang/pack"
+)
+
+const (
+	PARSER_JSON   = "json"
+	PARSER_LOGFMT = "logfmt"
+	PARSER_REGEX  = "regex"
+)
+
+const (
+	LEVEL_NONE  = -1
+	LEVEL_TRACE = 0
+	LEVEL_DEBUG = 1
+	LEVEL_INFO  = 2
+	LEVEL_WARN  = 3
+	LEVEL_ERROR = 4
+	LEVEL_FATAL = 5
+)
+
+var levelNames = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+var levels = map[string]int{
+	"trace": LEVEL_TRACE, "debug": LEVEL_DEBUG,
+	"info": LEVEL_INFO, "notice": LEVEL_INFO,
+	"warn": LEVEL_WARN, "warning": LEVEL_WARN,
+	"error": LEVEL_ERROR, "err": LEVEL_ERROR,
+	"fatal": LEVEL_FATAL, "panic": LEVEL_FATAL, "dpanic": LEVEL_FATAL, "critical": LEVEL_FATAL, "crit": LEVEL_FATAL,
+}
+
+// logsink_parser_time_format 이 없을 때 확인하는 시간 필드의 layout
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006/01/02 15:04:05.999999999",
+}
+
+// LogParser parses the log line of the watched file and sets the tags, time and level of the LogSinkPack.
+//
+//	logsink_parser=json                    모든 파일
+//	logsink.{category}.parser=logfmt       파일별 (category 는 파일명)
+//	logsink.{category}.pattern=^(?P<time>\S+ \S+) \[(?P<level>\w+)\] (?P<msg>.*)$
+//	logsink.{category}.level=warn
+type LogParser struct {
+	Format   string
+	pattern  *regexp.Regexp
+	minLevel int
+
+	timeFields    []string
+	levelFields   []string
+	messageFields []string
+	timeFormat    string
+	maxTags       int
+}
+
+// NewLogParser returns the parser of the category. It returns nil if the parser is not configured.
+func NewLogParser(category string) *LogParser {
+	conf := config.GetConfig()
+	format := strings.ToLower(strings.TrimSpace(config.GetValueDef("logsink."+category+".parser", conf.LogSinkParser)))
+	if format == "" || format == "none" {
+		return nil
+	}
+	p := new(LogParser)
+	p.Format = format
+	switch format {
+	case PARSER_JSON, PARSER_LOGFMT:
+	case PARSER_REGEX:
+		expr := config.GetValue("logsink." + category + ".pattern")
+		re, err := regexp.Compile(expr)
+		if expr == "" || err != nil {
+			logutil.Println("WA-LOGS-401", "Invalid pattern of ", category, ", pattern=", expr, ", err=", err)
+			return nil
+		}
+		p.pattern = re
+	default:
+		logutil.Println("WA-LOGS-402", "Unknown parser of ", category, ", parser=", format)
+		return nil
+	}
+	p.minLevel = ParseLevel(config.GetValueDef("logsink."+category+".level", conf.LogSinkLevel))
+	p.timeFields = conf.LogSinkParserTimeFields
+	p.levelFields = conf.LogSinkParserLevelFields
+	p.messageFields = conf.LogSinkParserMessageFields
+	p.timeFormat = conf.LogSinkParserTimeFormat
+	p.maxTags = int(conf.LogSinkParserMaxTags)
+	return p
+}
+
+// Apply sets the parsed fields to the tags of the pack, the time field to the time of the pack
+// and the normalized level to the level tag.
+// It returns false if the level of the line is lower than logsink_level so that the line is not sent.
+func (this *LogParser) Apply(p *pack.LogSinkPack, line string) bool {
+	fields, ok := this.Parse(line)
+	if !ok {
+		return true
+	}
+	level := LEVEL_NONE
+	for _, k := range this.levelFields {
+		if v, ok := fields[k]; ok {
+			level = ParseLevel(v)
+			delete(fields, k)
+			break
+		}
+	}
+	if level != LEVEL_NONE && level < this.minLevel {
+		return false
+	}
+	for _, k := range this.timeFields {
+		if v, ok := fields[k]; ok {
+			if t, ok := this.parseTime(v); ok {
+				p.Time = t
+				delete(fields, k)
+			}
+			break
+		}
+	}
+	// 메시지는 Content 에 있으므로 태그에서 제외
+	for _, k := range this.messageFields {
+		delete(fields, k)
+	}
+	if level != LEVEL_NONE {
+		p.Tags.PutString("level", LevelName(level))
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	cnt := 0
+	for _, k := range keys {
+		// txid 는 최대 태그 수에 포함하지 않음
+		if k == TxIdTag {
+			p.Category = AppLogCategory
+			txid := NormalizeTxid(fields[k])
+			p.Tags.PutString(k, txid)
+			addLogCount(txid)
+			continue
+		}
+		if this.maxTags > 0 && cnt >= this.maxTags {
+			continue
+		}
+		p.Tags.PutString(k, fields[k])
+		cnt++
+	}
+	return true
+}
+
+// Parse returns the fields of the line. The multiline log is parsed with the first line except json.
+func (this *LogParser) Parse(line string) (map[string]string, bool) {
+	switch this.Format {
+	case PARSER_JSON:
+		if fields, ok := parseJson(line); ok {
+			return fields, true
+		}
+		return parseJson(firstLine(line))
+	case PARSER_LOGFMT:
+		return parseLogfmt(firstLine(line))
+	case PARSER_REGEX:
+		return parseRegex(this.pattern, firstLine(line))
+	}
+	return nil, false
+}
+
+func firstLine(line string) string {
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// parseJson parses the json object line of zap, zerolog, slog. The nested object is flattened with the dot.
+func parseJson(line string) (map[string]string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+	d := json.NewDecoder(strings.NewReader(line))
+	d.UseNumber()
+	m := make(map[string]interface{})
+	if err := d.Decode(&m); err != nil {
+		return nil, false
+	}
+	fields := make(map[string]string)
+	flatten(fields, "", m)
+	return fields, true
+}
+
+func flatten(fields map[string]string, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case nil:
+		case string:
+			fields[k] = val
+		case map[string]interface{}:
+			flatten(fields, k, val)
+		case []interface{}:
+			if b, err := json.Marshal(val); err == nil {
+				fields[k] = string(b)
+			}
+		default:
+			fields[k] = fmt.Sprint(val)
+		}
+	}
+}
+
+// parseLogfmt parses the key=value pairs. The value may be quoted.
+func parseLogfmt(line string) (map[string]string, bool) {
+	fields := make(map[string]string)
+	i, n := 0, len(line)
+	for i < n {
+		for i < n && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		start := i
+		for i < n && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		key := line[start:i]
+		// 값이 없는 단어는 로그 메시지일 수 있으므로 제외
+		if i >= n || line[i] != '=' {
+			continue
+		}
+		// skip '='
+		i++
+		var val string
+		if i < n && line[i] == '"' {
+			var buf bytes.Buffer
+			i++
+			for i < n && line[i] != '"' {
+				if line[i] == '\\' && i+1 < n {
+					i++
+					switch line[i] {
+					case 'n':
+						buf.WriteByte('\n')
+					case 't':
+						buf.WriteByte('\t')
+					default:
+						buf.WriteByte(line[i])
+					}
+				} else {
+					buf.WriteByte(line[i])
+				}
+				i++
+			}
+			// skip '"'
+			i++
+			val = buf.String()
+		} else {
+			vs := i
+			for i < n && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			val = line[vs:i]
+		}
+		if key != "" {
+			fields[key] = val
+		}
+	}
+	return fields, len(fields) > 0
+}
+
+// parseRegex returns the named groups of the pattern.
+func parseRegex(re *regexp.Regexp, line string) (map[string]string, bool) {
+	if re == nil {
+		return nil, false
+	}
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	fields := make(map[string]string)
+	for i, name := range re.SubexpNames() {
+		if i > 0 && name != "" && m[i] != "" {
+			fields[name] = m[i]
+		}
+	}
+	return fields, true
+}
+
+// ParseLevel returns the level of the name. (zap, zerolog, slog, logrus and the number of pino, bunyan)
+// It returns LEVEL_NONE if the level is unknown.
+func ParseLevel(name string) int {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return LEVEL_NONE
+	}
+	if lv, ok := levels[name]; ok {
+		return lv
+	}
+	// slog 의 WARN+2, INFO-4
+	if i := strings.IndexAny(name, "+-"); i > 0 {
+		if lv, ok := levels[name[:i]]; ok {
+			return lv
+		}
+	}
+	// pino, bunyan 10(trace) ~ 60(fatal)
+	if n, err := strconv.Atoi(name); err == nil && n >= 10 {
+		lv := n/10 - 1
+		if lv > LEVEL_FATAL {
+			lv = LEVEL_FATAL
+		}
+		return lv
+	}
+	return LEVEL_NONE
+}
+
+// LevelName returns the normalized name of the level. It returns "" for LEVEL_NONE.
+func LevelName(level int) string {
+	if level < 0 || level >= len(levelNames) {
+		return ""
+	}
+	return levelNames[level]
+}
+
+// parseTime returns the time in milliseconds of the RFC3339 or the epoch seconds, milliseconds, microseconds, nanoseconds.
+func (this *LogParser) parseTime(v string) (int64, bool) {
+	v = strings.TrimSpace(v)
+	if this.timeFormat != "" {
+		if t, err := time.ParseInLocation(this.timeFormat, v, time.Local); err == nil {
+			return t.UnixMilli(), true
+		}
+		return 0, false
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		switch {
+		case f < 1e11:
+			return int64(f * 1000), true
+		case f < 1e14:
+			return int64(f), true
+		case f < 1e17:
+			return int64(f / 1e3), true
+		default:
+			return int64(f / 1e6), true
+		}
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t.UnixMilli(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,232 @@
+package watch
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/golib/lang/pack"
+)
+
+func newTestParser(format, pattern, minLevel string) *LogParser {
+	p := &LogParser{
+		Format:        format,
+		minLevel:      ParseLevel(minLevel),
+		timeFields:    []string{"time", "ts", "timestamp", "@timestamp"},
+		levelFields:   []string{"level", "lvl", "severity", "log.level"},
+		messageFields: []string{"msg", "message"},
+		maxTags:       30,
+	}
+	if pattern != "" {
+		p.pattern = regexp.MustCompile(pattern)
+	}
+	return p
+}
+
+func tagsOf(p *pack.LogSinkPack) map[string]string {
+	rt := make(map[string]string)
+	en := p.Tags.Keys()
+	for en.HasMoreElements() {
+		k := en.NextString()
+		rt[k] = p.Tags.GetString(k)
+	}
+	return rt
+}
+
+func localMillis(layout, v string) int64 {
+	t, _ := time.ParseInLocation(layout, v, time.Local)
+	return t.UnixMilli()
+}
+
+func TestLogParserApply(t *testing.T) {
+	regex := `^(?P<time>\S+ \S+) \[(?P<level>\w+)\] (?P<msg>.*)$`
+	tests := []struct {
+		name    string
+		parser  *LogParser
+		line    string
+		time    int64
+		tags    map[string]string
+		applied bool
+	}{
+		{"zap", newTestParser(PARSER_JSON, "", ""),
+			`{"level":"info","ts":1700000000.123456,"caller":"main.go:10","msg":"hello","user":{"id":7,"name":"kim"},"tags":["a","b"]}`,
+			1700000000123, map[string]string{"level": "info", "caller": "main.go:10", "user.id": "7", "user.name": "kim", "tags": `["a","b"]`}, true},
+		{"zerolog", newTestParser(PARSER_JSON, "", ""),
+			`{"level":"warn","time":"2023-11-14T22:13:20Z","message":"disk full","path":"/var"}`,
+			1700000000000, map[string]string{"level": "warn", "path": "/var"}, true},
+		{"slog", newTestParser(PARSER_JSON, "", ""),
+			`{"time":"2023-11-14T22:13:20.5+09:00","level":"WARN+2","msg":"slow","req":{"method":"GET"}}`,
+			1700000000500 - 9*3600*1000, map[string]string{"level": "warn", "req.method": "GET"}, true},
+		{"pino", newTestParser(PARSER_JSON, "", ""),
+			`{"level":50,"time":1700000000123,"pid":123,"hostname":"h","msg":"failed"}`,
+			1700000000123, map[string]string{"level": "error", "pid": "123", "hostname": "h"}, true},
+		// json 이 아닌 여러 줄 로그는 첫 줄로 파싱
+		{"json multiline", newTestParser(PARSER_JSON, "", ""),
+			"{\"level\":\"error\",\"msg\":\"panic\"}\ngoroutine 1 [running]:",
+			0, map[string]string{"level": "error"}, true},
+		{"not json", newTestParser(PARSER_JSON, "", "warn"),
+			"plain text", 0, map[string]string{}, true},
+		{"logfmt", newTestParser(PARSER_LOGFMT, "", ""),
+			`time=2023-11-14T22:13:20Z level=error msg="failed to \"connect\"" err="dial tcp: i/o timeout" retry=3 trailing`,
+			1700000000000, map[string]string{"level": "error", "err": "dial tcp: i/o timeout", "retry": "3"}, true},
+		{"regex", newTestParser(PARSER_REGEX, regex, ""),
+			"2023-11-14 22:13:20.123 [WARN] disk full\n\tat main",
+			localMillis("2006-01-02 15:04:05.999", "2023-11-14 22:13:20.123"), map[string]string{"level": "warn"}, true},
+		{"regex not matched", newTestParser(PARSER_REGEX, regex, "error"),
+			"disk full", 0, map[string]string{}, true},
+		// logsink_level 보다 낮은 레벨은 전송하지 않음
+		{"below level", newTestParser(PARSER_JSON, "", "warn"),
+			`{"level":"info","msg":"x"}`, 0, nil, false},
+		{"pino below level", newTestParser(PARSER_JSON, "", "warn"),
+			`{"level":30,"msg":"x"}`, 0, nil, false},
+		{"slog below level", newTestParser(PARSER_JSON, "", "warn"),
+			`{"level":"INFO+2","msg":"x"}`, 0, nil, false},
+		{"above level", newTestParser(PARSER_LOGFMT, "", "warn"),
+			`level=fatal msg=x`, 0, map[string]string{"level": "fatal"}, true},
+		// 레벨이 없는 로그는 전송
+		{"no level", newTestParser(PARSER_LOGFMT, "", "error"),
+			`msg=x a=1`, 0, map[string]string{"a": "1"}, true},
+	}
+	for _, tt := range tests {
+		p := pack.NewLogSinkPack()
+		p.Category = "app.log"
+		assert.Equal(t, tt.applied, tt.parser.Apply(p, tt.line), tt.name)
+		if !tt.applied {
+			continue
+		}
+		assert.Equal(t, tt.time, p.Time, tt.name)
+		assert.Equal(t, tt.tags, tagsOf(p), tt.name)
+		assert.Equal(t, "app.log", p.Category, tt.name)
+	}
+}
+
+func TestLogParserTxid(t *testing.T) {
+	parser := newTestParser(PARSER_JSON, "", "")
+	parser.maxTags = 1
+	p := pack.NewLogSinkPack()
+	p.Category = "app.log"
+	parser.Apply(p, `{"msg":"x","a":"1","b":"2","@txid":" 8a7b6c5d4e3f2a1b "}`)
+
+	// txid 는 AppLog 카테고리로 전송하고 최대 태그 수에 관계 없이 추가
+	assert.Equal(t, AppLogCategory, p.Category)
+	tags := tagsOf(p)
+	assert.Equal(t, 2, len(tags))
+	assert.Equal(t, "1", tags["a"])
+	assert.Equal(t, NormalizeTxid("8a7b6c5d4e3f2a1b"), tags[TxIdTag])
+}
+
+func TestNewLogParser(t *testing.T) {
+	conf := config.GetConfig()
+	oldParser, oldLevel := conf.LogSinkParser, conf.LogSinkLevel
+	t.Cleanup(func() { conf.LogSinkParser, conf.LogSinkLevel = oldParser, oldLevel })
+
+	conf.LogSinkParser, conf.LogSinkLevel = "", ""
+	assert.Nil(t, NewLogParser("app.log"))
+
+	conf.LogSinkParser, conf.LogSinkLevel = " JSON ", "warn"
+	p := NewLogParser("app.log")
+	if assert.NotNil(t, p) {
+		assert.Equal(t, PARSER_JSON, p.Format)
+		assert.Equal(t, LEVEL_WARN, p.minLevel)
+	}
+
+	// pattern 이 없는 regex, 알 수 없는 파서
+	conf.LogSinkParser = PARSER_REGEX
+	assert.Nil(t, NewLogParser("app.log"))
+	conf.LogSinkParser = "xml"
+	assert.Nil(t, NewLogParser("app.log"))
+}
+
+func TestParseLogfmt(t *testing.T) {
+	tests := []struct {
+		line   string
+		fields map[string]string
+		ok     bool
+	}{
+		{`a=1 b=two`, map[string]string{"a": "1", "b": "two"}, true},
+		{`msg="hello world" empty="" e=`, map[string]string{"msg": "hello world", "empty": "", "e": ""}, true},
+		{`msg="line1\nline2\ttab \"q\" \\"`, map[string]string{"msg": "line1\nline2\ttab \"q\" \\"}, true},
+		{`url="a=b c" x=1`, map[string]string{"url": "a=b c", "x": "1"}, true},
+		// 닫히지 않은 따옴표
+		{`msg="open x=1`, map[string]string{"msg": "open x=1"}, true},
+		{"\tlevel=info  word  k=v", map[string]string{"level": "info", "k": "v"}, true},
+		{`=v`, map[string]string{}, false},
+		{`plain text`, map[string]string{}, false},
+	}
+	for _, tt := range tests {
+		fields, ok := parseLogfmt(tt.line)
+		assert.Equal(t, tt.ok, ok, tt.line)
+		assert.Equal(t, tt.fields, fields, tt.line)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		level int
+	}{
+		{"trace", LEVEL_TRACE},
+		{"DEBUG", LEVEL_DEBUG},
+		{" info ", LEVEL_INFO},
+		{"notice", LEVEL_INFO},
+		{"Warning", LEVEL_WARN},
+		{"err", LEVEL_ERROR},
+		{"dpanic", LEVEL_FATAL},
+		{"CRITICAL", LEVEL_FATAL},
+		// slog
+		{"WARN+2", LEVEL_WARN},
+		{"INFO-4", LEVEL_INFO},
+		{"ERROR+8", LEVEL_ERROR},
+		// pino, bunyan
+		{"10", LEVEL_TRACE},
+		{"20", LEVEL_DEBUG},
+		{"30", LEVEL_INFO},
+		{"40", LEVEL_WARN},
+		{"50", LEVEL_ERROR},
+		{"60", LEVEL_FATAL},
+		{"70", LEVEL_FATAL},
+		{"5", LEVEL_NONE},
+		{"", LEVEL_NONE},
+		{"verbose", LEVEL_NONE},
+		{"+2", LEVEL_NONE},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.level, ParseLevel(tt.name), tt.name)
+	}
+	assert.Equal(t, "warn", LevelName(LEVEL_WARN))
+	assert.Equal(t, "", LevelName(LEVEL_NONE))
+	assert.Equal(t, "", LevelName(LEVEL_FATAL+1))
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		format string
+		v      string
+		time   int64
+		ok     bool
+	}{
+		// epoch 초, 밀리초, 마이크로초, 나노초
+		{"", "1700000000", 1700000000000, true},
+		{"", "1700000000.123456", 1700000000123, true},
+		{"", "1700000000123", 1700000000123, true},
+		{"", "1700000000123456", 1700000000123, true},
+		{"", "1700000000123456789", 1700000000123, true},
+		{"", "2023-11-14T22:13:20.123456789Z", 1700000000123, true},
+		{"", "2023-11-14T22:13:20+09:00", 1700000000000 - 9*3600*1000, true},
+		{"", "2023-11-14 22:13:20.123", localMillis("2006-01-02 15:04:05", "2023-11-14 22:13:20") + 123, true},
+		{"", "2023/11/14 22:13:20", localMillis("2006-01-02 15:04:05", "2023-11-14 22:13:20"), true},
+		{"", "yesterday", 0, false},
+		{"02/Jan/2006:15:04:05 -0700", "14/Nov/2023:22:13:20 +0000", 1700000000000, true},
+		// 형식을 지정하면 epoch 는 확인하지 않음
+		{"02/Jan/2006:15:04:05 -0700", "1700000000", 0, false},
+	}
+	for _, tt := range tests {
+		p := newTestParser(PARSER_JSON, "", "")
+		p.timeFormat = tt.format
+		tm, ok := p.parseTime(tt.v)
+		assert.Equal(t, tt.ok, ok, tt.v)
+		assert.Equal(t, tt.time, tm, tt.v)
+	}
+}
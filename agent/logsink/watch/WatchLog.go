@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/whatap/go-api/agent/agent/config"
@@ -19,13 +20,13 @@ import (
 	"github.com/whatap/golib/lang/pack"
 	"github.com/whatap/golib/util/dateutil"
 	"golang.org/x/text/encoding/korean"
-	"golang.org/x/text/transform"
 )
 
 type WatchLog struct {
 	Id        string
 	Activated bool
 
+	// 읽은 위치를 유지하기 위해 Stop 전까지 열어둠
 	file     *os.File
 	FileName string
 	FileInfo os.FileInfo
@@ -43,6 +44,21 @@ type WatchLog struct {
 	trxLogFound bool
 
 	Category string
+	// logsink.{category}.parser, logsink_parser 로 지정한 파서. 지정하지 않으면 nil
+	Parser *LogParser
+
+	// 파일 앞부분의 crc. copytruncate 확인
+	head    uint32
+	headLen int
+	// 마지막으로 저장한 위치
+	savedPos int64
+
+	// 파일을 찾은 logsink.files 의 패턴. 지정된 파일은 ""
+	Pattern string
+	// 마지막으로 내용을 읽은 시간. 패턴으로 찾은 파일은 logsink_idle_timeout 동안 읽은 내용이 없으면 감시 목록에서 제외
+	LastActiveTime int64
+
+	lock sync.Mutex
 }
 
 func NewWatchLog(id string) *WatchLog {
@@ -51,13 +67,19 @@ func NewWatchLog(id string) *WatchLog {
 	p.Words = make([]string, 0)
 	p.logsendThreshold = LogSendThreshold
 	p.Category = filepath.Base(id)
+	p.savedPos = -1
+	p.LastActiveTime = dateutil.SystemNow()
 	return p
 }
 
 func (wl *WatchLog) Config(id string, fileName string) {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
 	wl.Category = filepath.Base(id)
+	if wl.FileName != fileName {
+		wl.closeFile()
+	}
 	wl.FileName = fileName
-	wl.file = nil
 	if fi, err := os.Stat(fileName); err == nil {
 		wl.FileInfo = fi
 	} else {
@@ -67,185 +89,227 @@ func (wl *WatchLog) Config(id string, fileName string) {
 }
 
 func (wl *WatchLog) Process() {
-	if wl.trxLogFound {
-		wl.processMultilineLogs()
-	} else {
-		wl.process()
-	}
-
-}
-
-func (wl *WatchLog) processMultilineLogs() (err error) {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			logutil.Println("WA-LOGS-001", "Recover Process ", r, ",stack=", string(debug.Stack()))
+		}
+	}()
 
-	fi, statErr := os.Stat(wl.FileName)
-	if statErr != nil {
-		err = statErr
+	now := dateutil.SystemNow()
+	if now < wl.LastCheckTime+int64(wl.CheckInterval) {
 		return
 	}
+	wl.LastCheckTime = now
 
-	fileposthistime := fi.Size()
-
-	if fileposthistime == wl.FilePos {
-		return
-	} else if fileposthistime < wl.FilePos {
-		wl.FilePos = 0
+	pos := wl.FilePos
+	if wl.openFile() {
+		wl.readAndSend(false)
 	}
-
-	f, fileErr := os.Open(wl.FileName)
-	if fileErr != nil {
-		err = fileErr
-		return
+	if wl.FilePos != pos {
+		wl.LastActiveTime = now
 	}
+	wl.saveCheckpoint()
+}
 
-	defer f.Close()
+// openFile opens the file and detects the rotation. It returns false if there is nothing to read.
+//
+// rename 후 새 파일이 생성된 경우(inode 변경) 이전 파일의 남은 내용을 모두 읽은 후 새 파일을 처음부터 읽고,
+// copytruncate 로 파일이 줄어들거나 앞부분이 바뀐 경우 처음부터 읽음
+func (wl *WatchLog) openFile() bool {
+	fi, err := os.Stat(wl.FileName)
+	if err != nil {
+		logutil.Println("WA-LOGS-002", "File not found ", wl.FileName, ", Error ", err)
+		wl.FileInfo = nil
+		if wl.file != nil {
+			// 삭제 또는 rename 된 파일. 새 파일이 생성되면 처음부터 읽음
+			wl.drain()
+			wl.closeFile()
+			wl.FilePos = 0
+		}
+		return false
+	}
 
-	_, seekErr := f.Seek(wl.FilePos, io.SeekStart)
-	if seekErr != nil {
-		err = seekErr
-		return
+	if wl.file != nil {
+		if ofi, err := wl.file.Stat(); err != nil || !os.SameFile(ofi, fi) {
+			if config.GetConfig().DebugLogSinkEnabled {
+				logutil.Infoln("WA-LOGS-006", "rotated ", wl.FileName, ", pos=", wl.FilePos)
+			}
+			wl.drain()
+			wl.closeFile()
+			wl.FilePos = 0
+		}
 	}
 
-	var scanner bufio.Scanner
+	if wl.file == nil {
+		f, err := os.Open(wl.FileName)
+		if err != nil {
+			logutil.Println("WA-LOGS-003", "Open Error ", ",err=", err)
+			return false
+		}
+		wl.file = f
+		wl.head, wl.headLen = 0, 0
+	}
 
-	if wl.encoding == "euc-kr" {
-		dec := transform.NewReader(f, korean.EUCKR.NewDecoder())
-		scanner = *bufio.NewScanner(dec)
-	} else {
-		scanner = *bufio.NewScanner(f)
+	// os.Stat 은 변화하는 파일 용량을 못 가져옴. Open 후 Stat 으로 가져와야 함
+	if fi, err = wl.file.Stat(); err != nil {
+		wl.closeFile()
+		return false
 	}
+	wl.FileInfo = fi
 
-	scanner.Split(bufio.ScanLines)
+	// 처음 확인한 파일은 끝부터 읽음
+	if wl.FilePos < 0 {
+		wl.FilePos = fi.Size()
+		wl.updateHead(fi.Size())
+		return false
+	}
 
-	multilinebuffer := bytes.Buffer{}
-	var loopCondition = true
-	lineCount := int32(0)
-	for loopCondition {
-		select {
-		case <-time.After(time.Second * 1):
-			loopCondition = false
-		default:
-			if !scanner.Scan() {
-				loopCondition = false
-				break
-			}
-			line := scanner.Text()
-			lineCount += 1
-			if validateTxHeader(line) || lineCount > LogSendThreshold {
-				if multilinebuffer.Len() > SEND_THRESHOLD {
-					wl.parseAndSend([]string{multilinebuffer.String()})
-					multilinebuffer.Reset()
-					lineCount = 0
-				}
-			}
-			if multilinebuffer.Len() > 0 {
-				multilinebuffer.WriteString(NEWLINE)
-			}
-			multilinebuffer.WriteString(line)
+	if wl.isTruncated(fi.Size()) {
+		if config.GetConfig().DebugLogSinkEnabled {
+			logutil.Infoln("WA-LOGS-007", "truncated ", wl.FileName, ", pos=", wl.FilePos, ", size=", fi.Size())
 		}
+		wl.FilePos = 0
+		wl.head, wl.headLen = 0, 0
 	}
+	wl.updateHead(fi.Size())
+	return wl.FilePos < fi.Size()
+}
 
-	// fmt.Println("<-----------------------loop-exit----------------------->")
-	if multilinebuffer.Len() > 0 {
-		wl.parseAndSend([]string{multilinebuffer.String()})
-
-		multilinebuffer.Reset()
+// isTruncated returns true if the file is smaller than the read position or the head of the file is changed.
+func (wl *WatchLog) isTruncated(size int64) bool {
+	if size < wl.FilePos {
+		return true
 	}
+	if wl.headLen > 0 {
+		if head, n := fileHead(wl.file, int64(wl.headLen)); n != wl.headLen || head != wl.head {
+			return true
+		}
+	}
+	return false
+}
 
-	pos, seekErr := f.Seek(0, os.SEEK_CUR)
-	err = seekErr
-	if seekErr == nil {
-		wl.FilePos = pos
+func (wl *WatchLog) updateHead(size int64) {
+	if wl.headLen < headSize && size > int64(wl.headLen) {
+		wl.head, wl.headLen = fileHead(wl.file, size)
 	}
+}
 
-	return
+// drain reads the rest of the opened file including the last line without the newline before the file is switched.
+func (wl *WatchLog) drain() {
+	if wl.file == nil || wl.FilePos < 0 {
+		return
+	}
+	wl.readAndSend(true)
 }
 
-func (wl *WatchLog) process() {
-	defer func() {
-		// 열린파일이 닫히는 것을 보장함 defer
+func (wl *WatchLog) closeFile() {
+	if wl.file != nil {
 		wl.file.Close()
 		wl.file = nil
-		if r := recover(); r != nil {
-			logutil.Println("WA-LOGS-001", "Recover Process ", r, ",stack=", string(debug.Stack()))
-		}
-	}()
-
-	//	if wl.FileInfo == nil {
-	//		wl.FilePos = -1
-	//		return
-	//	}
-	// 다시 파일 정보 확인
-	if fi, err := os.Stat(wl.FileName); err == nil {
-		wl.FileInfo = fi
-	} else {
-		wl.FileInfo = nil
-		wl.FilePos = -1
-		logutil.Println("WA-LOGS-002", "File not found ", wl.FileName, ", Error ", err)
 	}
+}
 
-	if wl.FilePos < 0 {
-		if wl.FileInfo != nil {
-			wl.FilePos = wl.FileInfo.Size()
-		}
+// readAndSend reads the lines from FilePos and sends them. FilePos is moved to the end of the last line read.
+// If all is true, it reads until EOF.
+func (wl *WatchLog) readAndSend(all bool) {
+	if _, err := wl.file.Seek(wl.FilePos, io.SeekStart); err != nil {
+		logutil.Println("WA-LOGS-004", "Error SEEK_SET ", wl.FilePos, ",err=", err)
 		return
 	}
-
-	now := dateutil.SystemNow()
-	if now < wl.LastCheckTime+int64(wl.CheckInterval) {
-		return
+	ConfLogSink := config.GetConfig().ConfLogSink
+	r := bufio.NewReaderSize(wl.file, int(ConfLogSink.WatchLogBufferSize))
+	if wl.trxLogFound {
+		wl.processMultilineLogs(r, all)
+	} else {
+		wl.process(r, all)
 	}
-	wl.LastCheckTime = dateutil.SystemNow()
+}
 
-	if wl.FilePos > wl.FileInfo.Size() {
-		wl.FilePos = wl.FileInfo.Size()
-		return
+// readLine returns the line and the bytes read including the newline. The line is cut to watchlog_max_line_size.
+// The last line without the newline is being written, so it is read next time unless all is true.
+func (wl *WatchLog) readLine(r *bufio.Reader, all bool) (string, int, bool) {
+	max := int(config.GetConfig().WatchLogMaxLineSize)
+	b := make([]byte, 0)
+	n := 0
+	for {
+		frag, err := r.ReadSlice('\n')
+		n += len(frag)
+		// 최대 길이를 넘는 부분은 버리고 줄의 끝까지 읽음
+		if max <= 0 || len(b)+len(frag) <= max {
+			b = append(b, frag...)
+		} else if len(b) < max {
+			b = append(b, frag[:max-len(b)]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (n == 0 || !all) {
+			return "", 0, false
+		}
+		break
 	}
+	b = bytes.TrimRight(b, "\r\n")
+	if wl.encoding == "euc-kr" {
+		if dec, err := korean.EUCKR.NewDecoder().Bytes(b); err == nil {
+			b = dec
+		}
+	}
+	return string(b), n, true
+}
 
-	if f, err := os.Open(wl.FileName); err == nil {
-		wl.file = f
-		// os.Stat 은 변화하는 파일 용량을 못 가져옴. Open 후 Stat 으로 가져와야 함
-		if fi, err := f.Stat(); err == nil {
-			wl.FileInfo = fi
-		} else {
-			wl.FileInfo = nil
-			wl.FilePos = -1
-			return
+func (wl *WatchLog) processMultilineLogs(r *bufio.Reader, all bool) {
+	multilinebuffer := bytes.Buffer{}
+	// 전송한 줄까지의 위치
+	pos := wl.FilePos
+	read := int64(0)
+	lineCount := int32(0)
+	deadline := time.Now().Add(time.Second * 1)
+	for all || time.Now().Before(deadline) {
+		line, n, ok := wl.readLine(r, all)
+		if !ok {
+			break
 		}
-	} else {
-		logutil.Println("WA-LOGS-003", "Open Error ", ",err=", err)
+		lineCount += 1
+		if validateTxHeader(line) || lineCount > LogSendThreshold {
+			if multilinebuffer.Len() > SEND_THRESHOLD {
+				wl.parseAndSend([]string{multilinebuffer.String()})
+				multilinebuffer.Reset()
+				lineCount = 0
+				pos += read
+				read = 0
+			}
+		}
+		if multilinebuffer.Len() > 0 {
+			multilinebuffer.WriteString(NEWLINE)
+		}
+		multilinebuffer.WriteString(line)
+		read += int64(n)
 	}
 
-	fileLength := wl.FileInfo.Size()
+	if multilinebuffer.Len() > 0 {
+		wl.parseAndSend([]string{multilinebuffer.String()})
+		multilinebuffer.Reset()
+	}
+	wl.FilePos = pos + read
+}
 
+func (wl *WatchLog) process(r *bufio.Reader, all bool) {
 	//conf := config.GetConfig()
 	ConfLogSink := config.GetConfig().ConfLogSink
 
-	for readCnt := 0; readCnt < int(ConfLogSink.WatchLogReadCount); readCnt++ {
-		if wl.FilePos >= fileLength {
-			return
-		}
-
-		//		if pos, err := f.Seek(wl.FilePos, os.SEEK_SET); err != nil {
-		if _, err := wl.file.Seek(wl.FilePos, os.SEEK_SET); err != nil {
-			logutil.Println("WA-LOGS-004", "Error SEEK_SET ", wl.FilePos, ",err=", err)
-			return
-		}
+	for readCnt := 0; all || readCnt < int(ConfLogSink.WatchLogReadCount); readCnt++ {
 		// Read
-		lines := wl.read(int(ConfLogSink.WatchLogLineSize))
-
-		if cur, err := wl.file.Seek(0, os.SEEK_CUR); err != nil {
-			logutil.Println("WA-LOGS-005", "Error SEEK_CUR ", err)
-			return
-		} else {
-			wl.FilePos = cur
+		lines, n := wl.read(r, int(ConfLogSink.WatchLogLineSize), all)
+		wl.FilePos += n
+		if len(lines) > 0 {
+			wl.parseAndSend(lines)
 		}
-
-		if len(lines) == 0 {
+		if n == 0 {
 			return
 		}
 
-		wl.parseAndSend(lines)
-
 		//		match := 0
 		//		if wl.parseAndSend(lines) {
 		//			match += 1
@@ -275,15 +339,20 @@ func (wl *WatchLog) parseAndSend(buffer []string) bool {
 	return rt
 }
 
-func (wl *WatchLog) read(lineLimit int) []string {
+// read returns the lines up to lineLimit and the bytes read.
+func (wl *WatchLog) read(r *bufio.Reader, lineLimit int, all bool) ([]string, int64) {
 	//sT := dateutil.SystemNow()
-	scanner := bufio.NewScanner(wl.file)
-	scanner.Split(bufio.ScanLines)
 	lineCount := 0
+	n := int64(0)
 	result := make([]string, 0)
 
-	for scanner.Scan() && lineCount < lineLimit {
-		line := strings.TrimSpace(scanner.Text())
+	for lineCount < lineLimit {
+		line, size, ok := wl.readLine(r, all)
+		if !ok {
+			break
+		}
+		n += int64(size)
+		line = strings.TrimSpace(line)
 		if line != "" {
 			result = append(result, line)
 			lineCount++
@@ -293,14 +362,14 @@ func (wl *WatchLog) read(lineLimit int) []string {
 	//logutil.Infoln("read elpased=", dateutil.SystemNow()-sT, ",len=", len(result))
 	//}
 
-	return result
+	return result, n
 }
 
-func (wl *WatchLog) send(word string, wlog *WatchLog, line string) {
+// NewLogSinkPack returns the LogSinkPack of the category with the oname, okindName and onodeName tags.
+func NewLogSinkPack(category string) *pack.LogSinkPack {
 	p := pack.NewLogSinkPack()
 	p.Time = dateutil.Now()
-	p.Category = wl.Category
-	p.Tags.PutString("file", wlog.FileName)
+	p.Category = category
 	// Java ONAME, OKIND, ONODE
 	conf := config.GetConfig()
 	secu := secure.GetSecurityMaster()
@@ -314,12 +383,14 @@ func (wl *WatchLog) send(word string, wlog *WatchLog, line string) {
 	if conf.ONODE != 0 {
 		p.Tags.PutString("onodeName", conf.ONODE_NAME)
 	}
+	return p
+}
 
-	wl.trxLogFound = ApplyAppLog(p, line) || wl.trxLogFound
-
-	p.Content = line
-	p.Line = wlog.FileInfo.Size()
+// WatchLog 가 전송하는 함수. 테스트에서 변경
+var sendLogSinkPack = SendLogSinkPack
 
+// SendLogSinkPack sends the pack through the zip thread if logsink_zip_enabled.
+func SendLogSinkPack(p *pack.LogSinkPack) {
 	ConfLogSink := config.GetConfig().ConfLogSink
 	if ConfLogSink.LogSinkZipEnabled {
 		logsink_zip.GetInstance().Add(p)
@@ -328,8 +399,64 @@ func (wl *WatchLog) send(word string, wlog *WatchLog, line string) {
 	}
 }
 
+func (wl *WatchLog) send(word string, wlog *WatchLog, line string) {
+	p := NewLogSinkPack(wl.Category)
+	p.Tags.PutString("file", wlog.FileName)
+
+	// logsink_level 보다 낮은 레벨의 로그는 전송하지 않음
+	if wl.Parser != nil && !wl.Parser.Apply(p, line) {
+		return
+	}
+	wl.trxLogFound = ApplyAppLog(p, line) || wl.trxLogFound
+
+	p.Content = line
+	if wlog.FileInfo != nil {
+		p.Line = wlog.FileInfo.Size()
+	}
+	sendLogSinkPack(p)
+}
+
+// saveCheckpoint stores the read position of the opened file if it is changed.
+func (wl *WatchLog) saveCheckpoint() {
+	if wl.file == nil || wl.FilePos < 0 || wl.FilePos == wl.savedPos {
+		return
+	}
+	cp := &Checkpoint{File: wl.FileName, Pos: wl.FilePos, Head: wl.head, HeadLen: wl.headLen}
+	cp.Dev, cp.Ino, _ = fileId(wl.FileInfo)
+	if err := cp.Save(); err != nil {
+		logutil.Println("WA-LOGS-009", "Save checkpoint Error ", wl.FileName, ",err=", err)
+		return
+	}
+	wl.savedPos = wl.FilePos
+}
+
+// restore sets the read position from the checkpoint. If the file is rotated or truncated after the checkpoint,
+// the file is read from the beginning. It returns false if there is no checkpoint.
+func (wl *WatchLog) restore() bool {
+	cp := LoadCheckpoint(wl.FileName)
+	if cp == nil {
+		return false
+	}
+	wl.FilePos = 0
+	f, err := os.Open(wl.FileName)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && cp.IsSameFile(f, fi) {
+		wl.FilePos = cp.Pos
+	}
+	if config.GetConfig().DebugLogSinkEnabled {
+		logutil.Infoln("WA-LOGS-010", "restore ", wl.FileName, ", checkpoint=", cp.Pos, ", pos=", wl.FilePos)
+	}
+	return true
+}
+
+// Activate starts reading from the checkpoint, or the end of the file if there is no checkpoint.
 func (wl *WatchLog) Activate() {
-	if wl.Activated == false {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	if wl.Activated == false && !wl.restore() {
 		if wl.FileInfo != nil {
 			wl.FilePos = wl.FileInfo.Size()
 		} else {
@@ -339,19 +466,23 @@ func (wl *WatchLog) Activate() {
 	wl.Activated = true
 }
 
+// ActivateFirst starts reading from the checkpoint, or the beginning of the file if there is no checkpoint.
+// The file which is not created yet is read from the beginning when it is created.
 func (wl *WatchLog) ActivateFirst() {
-	if wl.Activated == false {
-		if wl.FileInfo != nil {
-			wl.FilePos = 0
-		} else {
-			wl.FilePos = -1
-		}
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	if wl.Activated == false && !wl.restore() {
+		wl.FilePos = 0
 	}
 	wl.Activated = true
 }
 
 func (wl *WatchLog) Stop() {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
 	wl.Activated = false
+	wl.saveCheckpoint()
+	wl.closeFile()
 }
 
 func (wl *WatchLog) IsActive() bool {
@@ -368,5 +499,7 @@ func (wl *WatchLog) ToString() string {
 }
 
 func (wl *WatchLog) Reset() {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
 	wl.FilePos = -1
 }
@@ -2,6 +2,7 @@ package watch
 
 import (
 	"math"
+	"os"
 	"path/filepath"
 	"regexp"
 	"runtime/debug"
@@ -33,6 +34,23 @@ func NewDateFormatFile(fileName, curFileName string) *DateFormatFile {
 	return p
 }
 
+// restore returns the file name of the last run from the checkpoint of the date format.
+// It returns "" if there is no checkpoint.
+func (this *DateFormatFile) restore() string {
+	if cp := LoadCheckpoint(this.fileName); cp != nil {
+		return cp.Current
+	}
+	return ""
+}
+
+// save stores the current file name so that the rest of the file is read after the restart on another date.
+func (this *DateFormatFile) save() {
+	cp := &Checkpoint{File: this.fileName, Current: this.curFileName}
+	if err := cp.Save(); err != nil {
+		logutil.Println("WA-LOGS-217", "Save checkpoint Error ", this.fileName, ",err=", err)
+	}
+}
+
 type WatchLogManager struct {
 	watchEnabled bool
 	conf         *config.Config
@@ -40,6 +58,11 @@ type WatchLogManager struct {
 	table *hmap.StringKeyLinkedMap
 
 	dateFormatFiles *hmap.StringKeyLinkedMap
+
+	// logsink.files 의 glob, 디렉토리 패턴. 설정 변경 시 patternLock 으로 교체
+	filePatterns  *hmap.StringKeyLinkedMap
+	lastDiscovery int64
+	patternLock   sync.Mutex
 }
 
 var watchLogManager *WatchLogManager
@@ -56,6 +79,7 @@ func GetInstance() *WatchLogManager {
 	watchLogManager.conf = config.GetConfig()
 	watchLogManager.table = hmap.NewStringKeyLinkedMap()
 	watchLogManager.dateFormatFiles = hmap.NewStringKeyLinkedMap()
+	watchLogManager.filePatterns = hmap.NewStringKeyLinkedMap()
 
 	langconf.AddConfObserver("WatchLogManager", watchLogManager)
 
@@ -103,6 +127,8 @@ func (this *WatchLogManager) run() {
 func (this *WatchLogManager) process() {
 	// check dateformat files
 	this.processDateFormatFiles()
+	// discover the files of the patterns
+	this.processFilePatterns()
 	now := time.Now().UnixMilli()
 	en := this.table.Values()
 	if this.conf.DebugLogSinkEnabled {
@@ -129,9 +155,26 @@ func (this *WatchLogManager) process() {
 				if dog.ExpirationTime != 0 && dog.ExpirationTime < now {
 					dog.Stop()
 					this.table.Remove(dog.Id)
+					// 더 이상 읽지 않는 이전 날짜의 파일
+					RemoveCheckpoint(dog.FileName)
 					if this.conf.DebugLogSinkEnabled {
 						logutil.Infoln("WA-LOGS-203", "expire dog id=", dog.Id, ", ", dog.ExpirationTime)
 					}
+				} else if _, err := os.Stat(dog.FileName); dog.Pattern != "" && os.IsNotExist(err) {
+					// 패턴으로 찾은 파일이 삭제됨
+					dog.Stop()
+					this.table.Remove(dog.Id)
+					RemoveCheckpoint(dog.FileName)
+					if this.conf.DebugLogSinkEnabled {
+						logutil.Infoln("WA-LOGS-223", "remove dog of deleted file id=", dog.Id, ", pattern=", dog.Pattern)
+					}
+				} else if dog.Pattern != "" && this.conf.LogSinkIdleTimeout > 0 && dog.LastActiveTime+this.conf.LogSinkIdleTimeout < now {
+					// 패턴으로 찾은 파일 중 추가된 내용이 없는 파일. 다시 찾으면 checkpoint 부터 읽음
+					dog.Stop()
+					this.table.Remove(dog.Id)
+					if this.conf.DebugLogSinkEnabled {
+						logutil.Infoln("WA-LOGS-219", "evict idle dog id=", dog.Id, ", pattern=", dog.Pattern)
+					}
 				}
 			}()
 		}
@@ -151,6 +194,7 @@ func (this *WatchLogManager) processDateFormatFiles() {
 				if str != dff.curFileName {
 					dff.prevFileName = dff.curFileName
 					dff.curFileName = str
+					dff.save()
 
 					// new and activate
 					dog := this.Add(dff.curFileName, dff.curFileName, filepath.Base(dff.fileName), []string{}, conf.LogSinkInterval)
@@ -174,6 +218,78 @@ func (this *WatchLogManager) processDateFormatFiles() {
 	}
 }
 
+// addPrevDateFormatFile adds the file of the previous date to read the rest which is written before the restart.
+// The file is read from the checkpoint until logsink_stop_interval.
+func (this *WatchLogManager) addPrevDateFormatFile(fileName, prev string) string {
+	conf := config.GetConfig()
+	dog := this.Add(prev, prev, filepath.Base(fileName), []string{}, conf.LogSinkInterval)
+	dog.Activate()
+	dog.ExpirationTime = time.Now().UnixMilli() + conf.LogSinkStopInterval
+	if this.conf.DebugLogSinkEnabled {
+		logutil.Infoln("WA-LOGS-218", "add previous dateformatfile to WatchLog ", fileName, ", id=", prev)
+	}
+	return prev
+}
+
+// processFilePatterns adds the new files of the patterns every logsink_discovery_interval.
+// Each file is watched by its own WatchLog with the category of the file name, up to logsink_max_files.
+func (this *WatchLogManager) processFilePatterns() {
+	conf := config.GetConfig()
+	now := time.Now().UnixMilli()
+	this.patternLock.Lock()
+	filePatterns := this.filePatterns
+	if filePatterns.Size() == 0 || now < this.lastDiscovery+conf.LogSinkDiscoveryInterval {
+		this.patternLock.Unlock()
+		return
+	}
+	this.lastDiscovery = now
+	this.patternLock.Unlock()
+
+	watched := 0
+	en := this.table.Values()
+	for en.HasMoreElements() {
+		if dog, ok := en.NextElement().(*WatchLog); ok && dog.Pattern != "" {
+			watched++
+		}
+	}
+
+	en = filePatterns.Values()
+	for en.HasMoreElements() {
+		fp, ok := en.NextElement().(*FilePattern)
+		if !ok {
+			continue
+		}
+		for _, file := range fp.Match() {
+			if this.table.ContainsKey(file) {
+				continue
+			}
+			// idle 로 제외된 파일은 변경된 후 다시 추가
+			if fi, err := os.Stat(file); err != nil || (conf.LogSinkIdleTimeout > 0 && fi.ModTime().UnixMilli()+conf.LogSinkIdleTimeout < now) {
+				continue
+			}
+			if conf.LogSinkMaxFiles > 0 && watched >= int(conf.LogSinkMaxFiles) {
+				if this.conf.DebugLogSinkEnabled {
+					logutil.Infoln("WA-LOGS-221", "exceed logsink_max_files ", conf.LogSinkMaxFiles, ", skip ", file)
+				}
+				break
+			}
+			dog := this.Add(file, file, "", []string{}, conf.LogSinkInterval)
+			dog.Pattern = fp.Entry
+			if fp.discovered {
+				// 새로 생성된 파일
+				dog.ActivateFirst()
+			} else {
+				dog.Activate()
+			}
+			watched++
+			if this.conf.DebugLogSinkEnabled {
+				logutil.Infoln("WA-LOGS-222", "add file of pattern ", fp.Entry, ", id=", file)
+			}
+		}
+		fp.discovered = true
+	}
+}
+
 func (this *WatchLogManager) Add(id string, file string, category string, words []string, checkInterval int32) *WatchLog {
 	// java intern, 이미 있는 건 그대로 사용.
 	var dog *WatchLog
@@ -199,6 +315,7 @@ func (this *WatchLogManager) Add(id string, file string, category string, words
 	if category != "" {
 		dog.Category = category
 	}
+	dog.Parser = NewLogParser(dog.Category)
 	return dog
 }
 
@@ -209,9 +326,28 @@ func (this *WatchLogManager) resetDogList(reset bool) {
 		}
 	}()
 	ids := make([]string, 0)
+	// 재시작 전 날짜의 파일
+	prevIds := make([]string, 0)
+	this.patternLock.Lock()
+	oldPatterns := this.filePatterns
+	this.patternLock.Unlock()
+	patterns := hmap.NewStringKeyLinkedMap()
 	if len(this.conf.LogSinkFiles) > 0 {
 		for _, it := range this.conf.LogSinkFiles {
-			ids = append(ids, strings.TrimSpace(it))
+			it = strings.TrimSpace(it)
+			// glob, 디렉토리 패턴은 processFilePatterns 에서 파일별로 추가
+			if IsFilePattern(it) {
+				if old := oldPatterns.Get(it); old != nil {
+					patterns.Put(it, old)
+				} else {
+					patterns.Put(it, NewFilePattern(it))
+				}
+				if this.conf.DebugLogSinkEnabled {
+					logutil.Println("WA-LOGS-220", "resetDogList add file pattern ", it)
+				}
+				continue
+			}
+			ids = append(ids, it)
 		}
 		sl := sort.StringSlice(ids)
 		sl.Sort()
@@ -227,10 +363,18 @@ func (this *WatchLogManager) resetDogList(reset bool) {
 				logutil.Println("WA-LOGS-209", "resetDogList logsink ", "id=", id, ",file=", file, ",enabled=", enabled)
 			}
 			if file != "" {
+				// 재시작 전 날짜의 파일이 바뀐 경우 새 파일은 처음부터 읽음
+				first := false
 				if str, err := strftime.Format(file, time.Now()); err == nil {
 					// dateformat file
 					if file != str {
-						this.dateFormatFiles.Put(file, NewDateFormatFile(file, str))
+						dff := NewDateFormatFile(file, str)
+						if prev := dff.restore(); prev != "" && prev != str {
+							prevIds = append(prevIds, this.addPrevDateFormatFile(file, prev))
+							first = true
+						}
+						dff.save()
+						this.dateFormatFiles.Put(file, dff)
 						if this.conf.DebugLogSinkEnabled {
 							logutil.Infoln("WA-LOGS-210", "resetDogList add dateformatFile ", file, ", ", str)
 						}
@@ -252,7 +396,11 @@ func (this *WatchLogManager) resetDogList(reset bool) {
 					if this.conf.DebugLogSinkEnabled {
 						logutil.Println("WA-LOGS-211", "Activate ", "id=", id, ",file=", file, ",enabled=", enabled)
 					}
-					dog.Activate()
+					if first {
+						dog.ActivateFirst()
+					} else {
+						dog.Activate()
+					}
 				} else {
 					if this.conf.DebugLogSinkEnabled {
 						logutil.Println("WA-LOGS-212", "Stop ", "id=", id, ",file=", file, ",enabled=", enabled)
@@ -289,11 +437,15 @@ func (this *WatchLogManager) resetDogList(reset bool) {
 			//if file != "" && len(words) > 0 {
 			if file != "" {
 				dog := NewWatchLog(id)
-				this.table.Put(id, dog)
+				// 이전 WatchLog 의 위치를 저장하고 파일을 닫음
+				if old, ok := this.table.Put(id, dog).(*WatchLog); ok && old != dog {
+					old.Stop()
+				}
 
 				dog.Config(id, file)
 				dog.Words = words
 				dog.CheckInterval = int(math.Max(float64(checkInterval), float64(1000)))
+				dog.Parser = NewLogParser(dog.Category)
 				if reset {
 					dog.Reset()
 				}
@@ -312,6 +464,13 @@ func (this *WatchLogManager) resetDogList(reset bool) {
 			}
 		}
 	}
+	this.patternLock.Lock()
+	this.filePatterns = patterns
+	// 다음 주기에 패턴의 파일을 다시 찾음
+	this.lastDiscovery = 0
+	this.patternLock.Unlock()
+
+	ids = append(ids, prevIds...)
 	// 삭제된 id들에 대해서는 삭제한다.
 	en1 := this.table.Keys()
 	for en1.HasMoreElements() {
@@ -323,8 +482,14 @@ func (this *WatchLogManager) resetDogList(reset bool) {
 				break
 			}
 		}
+		// 설정된 패턴으로 찾은 파일
+		if dog, ok := this.table.Get(id).(*WatchLog); ok && dog.Pattern != "" && patterns.ContainsKey(dog.Pattern) {
+			exists = true
+		}
 		if exists == false {
-			this.table.Remove(id)
+			if dog, ok := this.table.Remove(id).(*WatchLog); ok {
+				dog.Stop()
+			}
 			if this.conf.DebugLogSinkEnabled {
 				logutil.Println("WA-LOGS-216", "clear. ", "remove ", id)
 			}
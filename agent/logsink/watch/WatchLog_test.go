@@ -0,0 +1,215 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/golib/lang/pack"
+)
+
+// sentLogs collects the contents of the packs sent by WatchLog.
+type sentLogs struct {
+	lock  sync.Mutex
+	packs []*pack.LogSinkPack
+}
+
+func (this *sentLogs) contents() []string {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	rt := make([]string, 0)
+	for _, p := range this.packs {
+		rt = append(rt, p.Content)
+	}
+	this.packs = nil
+	return rt
+}
+
+// setupWatch stores the checkpoints under the temporary directory and captures the sent packs.
+func setupWatch(t *testing.T) (string, *sentLogs) {
+	dir := t.TempDir()
+	conf := config.GetConfig()
+	oldEnabled, oldPath := conf.WatchLogCheckpointEnabled, conf.WatchLogCheckpointPath
+	conf.WatchLogCheckpointEnabled = true
+	conf.WatchLogCheckpointPath = filepath.Join(dir, "checkpoint")
+
+	sent := &sentLogs{}
+	oldSend := sendLogSinkPack
+	sendLogSinkPack = func(p *pack.LogSinkPack) {
+		sent.lock.Lock()
+		defer sent.lock.Unlock()
+		sent.packs = append(sent.packs, p)
+	}
+	t.Cleanup(func() {
+		conf.WatchLogCheckpointEnabled, conf.WatchLogCheckpointPath = oldEnabled, oldPath
+		sendLogSinkPack = oldSend
+	})
+	return dir, sent
+}
+
+func newTestWatchLog(fileName string) *WatchLog {
+	wl := NewWatchLog(fileName)
+	wl.Config(fileName, fileName)
+	return wl
+}
+
+func writeFile(t *testing.T, fileName string, s string) {
+	if !assert.Nil(t, ioutil.WriteFile(fileName, []byte(s), 0644)) {
+		t.FailNow()
+	}
+}
+
+func appendFile(t *testing.T, fileName string, s string) {
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer f.Close()
+	f.WriteString(s)
+}
+
+func TestWatchLogPartialLine(t *testing.T) {
+	dir, sent := setupWatch(t)
+	fileName := filepath.Join(dir, "app.log")
+	writeFile(t, fileName, "a\nb")
+
+	wl := newTestWatchLog(fileName)
+	wl.ActivateFirst()
+	defer wl.Stop()
+
+	// 줄바꿈이 없는 마지막 줄은 다음에 읽음
+	wl.Process()
+	assert.Equal(t, []string{"a"}, sent.contents())
+	assert.Equal(t, int64(2), wl.FilePos)
+
+	wl.Process()
+	assert.Equal(t, []string{}, sent.contents())
+
+	appendFile(t, fileName, "c\r\nd\n")
+	wl.Process()
+	assert.Equal(t, []string{"bc", "d"}, sent.contents())
+	assert.Equal(t, int64(8), wl.FilePos)
+}
+
+func TestWatchLogMaxLineSize(t *testing.T) {
+	dir, sent := setupWatch(t)
+	conf := config.GetConfig()
+	oldMax, oldBuf := conf.WatchLogMaxLineSize, conf.WatchLogBufferSize
+	conf.WatchLogMaxLineSize = 10
+	conf.WatchLogBufferSize = 16
+	t.Cleanup(func() { conf.WatchLogMaxLineSize, conf.WatchLogBufferSize = oldMax, oldBuf })
+
+	fileName := filepath.Join(dir, "app.log")
+	writeFile(t, fileName, strings.Repeat("x", 100)+"\nok\n")
+
+	wl := newTestWatchLog(fileName)
+	wl.ActivateFirst()
+	defer wl.Stop()
+
+	// 최대 길이를 넘는 부분은 버리고 다음 줄부터 읽음
+	wl.Process()
+	assert.Equal(t, []string{strings.Repeat("x", 10), "ok"}, sent.contents())
+	assert.Equal(t, int64(104), wl.FilePos)
+}
+
+func TestWatchLogCheckpoint(t *testing.T) {
+	dir, sent := setupWatch(t)
+	fileName := filepath.Join(dir, "app.log")
+	writeFile(t, fileName, "a\nb\n")
+
+	wl := newTestWatchLog(fileName)
+	wl.ActivateFirst()
+	wl.Process()
+	assert.Equal(t, []string{"a", "b"}, sent.contents())
+	wl.Stop()
+
+	cp := LoadCheckpoint(fileName)
+	if assert.NotNil(t, cp) {
+		assert.Equal(t, int64(4), cp.Pos)
+	}
+
+	// 재시작 후 저장한 위치부터 읽음
+	appendFile(t, fileName, "c\n")
+	wl = newTestWatchLog(fileName)
+	wl.Activate()
+	assert.Equal(t, int64(4), wl.FilePos)
+	wl.Process()
+	assert.Equal(t, []string{"c"}, sent.contents())
+	wl.Stop()
+
+	// 중지된 동안 rotate 된 파일은 처음부터 읽음
+	assert.Nil(t, os.Rename(fileName, fileName+".1"))
+	writeFile(t, fileName, "d\ne\nf\n")
+	wl = newTestWatchLog(fileName)
+	wl.Activate()
+	assert.Equal(t, int64(0), wl.FilePos)
+	wl.Process()
+	assert.Equal(t, []string{"d", "e", "f"}, sent.contents())
+	wl.Stop()
+
+	// checkpoint 가 없는 파일은 Activate 시 끝부터 읽음
+	RemoveCheckpoint(fileName)
+	assert.Nil(t, LoadCheckpoint(fileName))
+	wl = newTestWatchLog(fileName)
+	wl.Activate()
+	assert.Equal(t, int64(6), wl.FilePos)
+	wl.Stop()
+}
+
+func TestWatchLogRotate(t *testing.T) {
+	dir, sent := setupWatch(t)
+	fileName := filepath.Join(dir, "app.log")
+	writeFile(t, fileName, "a\n")
+
+	wl := newTestWatchLog(fileName)
+	wl.ActivateFirst()
+	defer wl.Stop()
+	wl.Process()
+	assert.Equal(t, []string{"a"}, sent.contents())
+
+	// rename 후 새 파일 생성. 이전 파일의 남은 내용(줄바꿈이 없는 줄 포함)을 읽은 후 새 파일을 처음부터 읽음
+	appendFile(t, fileName, "b\nc")
+	assert.Nil(t, os.Rename(fileName, fileName+".1"))
+	writeFile(t, fileName, "d\n")
+	wl.Process()
+	assert.Equal(t, []string{"b", "c", "d"}, sent.contents())
+	assert.Equal(t, int64(2), wl.FilePos)
+
+	// 파일이 없는 동안에도 이전 파일을 모두 읽음
+	appendFile(t, fileName, "e\n")
+	assert.Nil(t, os.Rename(fileName, fileName+".2"))
+	wl.Process()
+	assert.Equal(t, []string{"e"}, sent.contents())
+	writeFile(t, fileName, "f\n")
+	wl.Process()
+	assert.Equal(t, []string{"f"}, sent.contents())
+}
+
+func TestWatchLogCopyTruncate(t *testing.T) {
+	dir, sent := setupWatch(t)
+	fileName := filepath.Join(dir, "app.log")
+	writeFile(t, fileName, "aaaa\nbbbb\n")
+
+	wl := newTestWatchLog(fileName)
+	wl.ActivateFirst()
+	defer wl.Stop()
+	wl.Process()
+	assert.Equal(t, []string{"aaaa", "bbbb"}, sent.contents())
+
+	// 읽은 위치보다 작아진 파일은 처음부터 읽음
+	assert.Nil(t, os.Truncate(fileName, 0))
+	appendFile(t, fileName, "c\n")
+	wl.Process()
+	assert.Equal(t, []string{"c"}, sent.contents())
+
+	// 읽은 위치보다 커졌지만 앞부분이 바뀐 파일도 처음부터 읽음
+	assert.Nil(t, os.Truncate(fileName, 0))
+	appendFile(t, fileName, "dd\neeee\n")
+	wl.Process()
+	assert.Equal(t, []string{"dd", "eeee"}, sent.contents())
+}
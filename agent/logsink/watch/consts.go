@@ -1,6 +1,10 @@
 package watch
 
-import "regexp"
+import (
+	"regexp"
+
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+)
 
 const (
 	SEND_THRESHOLD   = 0
@@ -10,7 +14,7 @@ const (
 
 var (
 	LogSendThreshold  int32 = 500
-	TxIdTag                 = "@txid"
+	TxIdTag                 = agenttrace.LOG_TXID_KEY
 	AppLogCategory          = "AppLog"
 	AppLogPattern, _        = regexp.Compile(`-- (\{.*\}) --`)
 	DebugAppLogParser       = false
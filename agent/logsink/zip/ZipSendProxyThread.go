@@ -2,8 +2,11 @@ package zip
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/whatap/go-api/agent/agent/config"
 	"github.com/whatap/go-api/agent/agent/data"
@@ -20,6 +23,10 @@ type ZipSendProxyThread struct {
 	buffer    bytes.Buffer
 	packCount int
 	firstTime int64
+
+	// buffer 는 run 과 Flush 에서 같이 사용
+	lock     sync.Mutex
+	flushing int32
 }
 
 var zipSendProxyThread *ZipSendProxyThread
@@ -47,14 +54,53 @@ func (this *ZipSendProxyThread) Add(p *pack.LogSinkPack) {
 func (this *ZipSendProxyThread) run() {
 	ConfLogSink := config.GetConfig().ConfLogSink
 	for true {
-		if tmp := this.Queue.GetTimeout(int(ConfLogSink.MaxWaitTime)); tmp != nil {
-			if log, ok := tmp.(*pack.LogSinkPack); ok {
-				this.Append(log)
+		tmp := this.Queue.GetTimeout(int(ConfLogSink.MaxWaitTime))
+		func() {
+			this.lock.Lock()
+			defer this.lock.Unlock()
+			if tmp != nil {
+				if log, ok := tmp.(*pack.LogSinkPack); ok {
+					this.Append(log)
+				}
+				// Flush 중에는 버퍼에 쌓아두지 않고 바로 전송
+				if atomic.LoadInt32(&this.flushing) == 1 && this.Queue.Size() == 0 {
+					this.sendAndClear()
+				}
+			} else {
+				this.sendAndClear()
 			}
-		} else {
-			this.sendAndClear()
+		}()
+	}
+}
+
+// Flush waits until the queued log packs are zipped and handed to the sender.
+// It returns the number of packs left in the queue when ctx is done.
+func (this *ZipSendProxyThread) Flush(ctx context.Context) int {
+	atomic.StoreInt32(&this.flushing, 1)
+	defer atomic.StoreInt32(&this.flushing, 0)
+
+	for this.Queue.Size() > 0 {
+		select {
+		case <-ctx.Done():
+			return this.Queue.Size()
+		case <-time.After(10 * time.Millisecond):
 		}
 	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.sendAndClear()
+	return 0
+}
+
+// Flush flushes the running ZipSendProxyThread. It does nothing if logsink was never started.
+func Flush(ctx context.Context) int {
+	zipSendProxyThreadMutex.Lock()
+	p := zipSendProxyThread
+	zipSendProxyThreadMutex.Unlock()
+	if p == nil {
+		return 0
+	}
+	return p.Flush(ctx)
 }
 
 func (this *ZipSendProxyThread) Append(p *pack.LogSinkPack) {
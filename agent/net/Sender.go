@@ -2,6 +2,8 @@ package net
 
 import (
 	//"log"
+	"context"
+	"errors"
 	"fmt"
 	"runtime"
 	"runtime/debug"
@@ -21,8 +23,13 @@ type TcpSend struct {
 	flag  byte
 	pack  pack.Pack
 	flush bool
+	// pack 이 nil 인 flush 요청. 앞선 데이터를 모두 쓴 뒤 결과를 전달
+	done chan error
 }
 
+// ErrNotConnected is returned by Flush when the packs are queued but the session to the collector is not open.
+var ErrNotConnected = errors.New("whatap: not connected to the collector")
+
 var lock = sync.Mutex{}
 var senderStart bool = false
 
@@ -37,19 +44,19 @@ func Send(f byte, p pack.Pack, flush bool) {
 	// DEBUG Queue
 	// conf 설정으로 하면 실행 중에 변경되는 설정에 따라가게 됨. queue_tcp_enabled는 무조건 재시작해야 함.
 	if buffer != nil {
-		buffer <- TcpSend{f, p, flush}
+		buffer <- TcpSend{flag: f, pack: p, flush: flush}
 	} else if TcpQueue != nil {
-		TcpQueue.Put1(TcpSend{f, p, flush})
+		TcpQueue.Put1(TcpSend{flag: f, pack: p, flush: flush})
 	}
 }
 func SendProfile(f byte, p pack.Pack, flush bool) {
 	InitSender()
 	// DEBUG Queue
 	if buffer != nil {
-		buffer <- TcpSend{f, p, flush}
+		buffer <- TcpSend{flag: f, pack: p, flush: flush}
 	} else if TcpQueue != nil {
 		// profile 우선순위 낮게 처리
-		TcpQueue.Put2(TcpSend{f, p, flush})
+		TcpQueue.Put2(TcpSend{flag: f, pack: p, flush: flush})
 	}
 }
 func InitSender() {
@@ -90,6 +97,59 @@ func InitSender() {
 	}
 }
 
+// Flush waits until the packs queued before the call are written to the tcp session
+// and the session buffer is flushed. It returns the number of packs still queued when ctx is done.
+// It returns immediately if the session is not open because the packs can not be sent until the session is opened.
+func Flush(ctx context.Context) (int, error) {
+	if buffer == nil && TcpQueue == nil {
+		return 0, nil
+	}
+	if !IsOpen() {
+		if n := pendingCount(); n > 0 {
+			return n, ErrNotConnected
+		}
+		return 0, nil
+	}
+	done := make(chan error, 1)
+	req := TcpSend{flush: true, done: done}
+	if buffer != nil {
+		select {
+		case buffer <- req:
+		case <-ctx.Done():
+			return pendingCount(), ctx.Err()
+		}
+	} else {
+		// profile 이 들어가는 queue2 뒤에 넣어서 앞선 데이터를 모두 보낸 후 처리
+		for TcpQueue.Put2(req) == false {
+			select {
+			case <-ctx.Done():
+				return pendingCount(), ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	select {
+	case err := <-done:
+		return 0, err
+	case <-ctx.Done():
+		// flush 요청 자체는 남은 데이터로 세지 않음
+		n := pendingCount() - 1
+		if n < 0 {
+			n = 0
+		}
+		return n, ctx.Err()
+	}
+}
+
+func pendingCount() int {
+	if buffer != nil {
+		return len(buffer)
+	} else if TcpQueue != nil {
+		return TcpQueue.Size1() + TcpQueue.Size2()
+	}
+	return 0
+}
+
 func PrintMemUsage() {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
@@ -179,6 +239,20 @@ func runSend() {
 				//fmt.Println("Sender.runSend waiting for session to open")
 				time.Sleep(100 * time.Millisecond)
 			}
+			// flush 요청
+			if p.pack == nil {
+				_, err := session.Flush()
+				if err != nil {
+					logutil.Println("WA10901-09", "Flush Error", err)
+					session.Close()
+				} else {
+					session.RetryQueue.Clear()
+				}
+				if p.done != nil {
+					p.done <- err
+				}
+				return
+			}
 			if cypher_level != conf.CypherLevel {
 				cypher_level = conf.CypherLevel
 				session.Close()
@@ -179,6 +179,14 @@ func (this *TcpSession) open() (ret bool) {
 
 	return true
 }
+// IsOpen reports whether the tcp session to the collector is open.
+func IsOpen() bool {
+	sessionLock.Lock()
+	s := session
+	sessionLock.Unlock()
+	return s != nil && s.isOpen()
+}
+
 func (this *TcpSession) isOpen() bool {
 	//logutil.Printf("Client %p", this.client)
 	return this.client != nil
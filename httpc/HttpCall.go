@@ -83,6 +83,10 @@ func Start(ctx context.Context, url string) (*HttpcCtx, error) {
 		st.StepId = traceCtx.MStepId
 		httpcCtx.StepId = traceCtx.MStepId
 		httpcCtx.step = st
+		// method.Start 로 시작된 step 의 하위 step
+		if parent := trace.GetParentStep(ctx); parent >= 0 {
+			agentapi.PushStep(traceCtx.Ctx, parent, st)
+		}
 	}
 
 	return httpcCtx, nil
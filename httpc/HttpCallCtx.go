@@ -70,3 +70,10 @@ func (this *HttpcCtx) Clear() {
 	this.TraceMtraceSpecValue = ""
 	this.TraceMtraceMcallee = 0
 }
+
+// SetParam sets the additional text of the http call step. ex) the message size of the grpc call
+func (this *HttpcCtx) SetParam(param string) {
+	if st, ok := this.step.(*step.HttpcStepX); ok {
+		st.Param = param
+	}
+}
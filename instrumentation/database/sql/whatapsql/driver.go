@@ -4,10 +4,9 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
-	"fmt"
-	"github.com/go-sql-driver/mysql"
-	"strings"
-	"time"
+	"io"
+	"reflect"
+	"sync"
 
 	//"fmt"
 	//"runtime/debug"
@@ -77,41 +76,69 @@ func (ct WrapConnector) Connect(ctx context.Context) (c driver.Conn, err error)
 	wCtx := selectContext(ctx, ct.ctx)
 	sqlCtx, _ := whatapsql.StartOpen(wCtx, ct.dataSourceName)
 
-	for i := 0; i < 5; i++ {
-		c, err = ct.Connector.Connect(ctx)
-		if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-			fmt.Println("[mysql] retrying", err)
-			time.Sleep(time.Second * 5)
-			continue
-		}
-		break
-	}
+	c, err = ct.Connector.Connect(ctx)
 	whatapsql.End(sqlCtx, err)
 	if err != nil {
 		return nil, err
 	}
-	return driver.Conn(WrapConn{c, wCtx, ct.dataSourceName}), err
+	return driver.Conn(WrapConn{c, wCtx, ct.dataSourceName, &connState{}}), err
 }
 
 type WrapConn struct {
 	driver.Conn
 	ctx            context.Context
 	dataSourceName string
+	state          *connState
+}
+
+// connState is the DB transaction of the connection shared by the copies of WrapConn and the statements prepared on it.
+type connState struct {
+	lock sync.Mutex
+	tx   *whatapsql.TxCtx
+}
+
+// withTx returns the context of which the sql step is recorded in the DB transaction of the connection.
+func (s *connState) withTx(ctx context.Context) context.Context {
+	if s == nil {
+		return ctx
+	}
+	s.lock.Lock()
+	tx := s.tx
+	s.lock.Unlock()
+	return whatapsql.WithTx(ctx, tx)
+}
+
+func (s *connState) begin(ctx context.Context, dataSourceName string) *whatapsql.TxCtx {
+	if s == nil {
+		return nil
+	}
+	tx := whatapsql.StartTx(ctx, dataSourceName)
+	s.lock.Lock()
+	s.tx = tx
+	s.lock.Unlock()
+	return tx
+}
+
+func (s *connState) end(tx *whatapsql.TxCtx, outcome string, err error) {
+	if s == nil || tx == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.tx == tx {
+		s.tx = nil
+	}
+	s.lock.Unlock()
+	if err != nil {
+		outcome = whatapsql.TX_ERROR
+	}
+	whatapsql.EndTx(tx, outcome, err)
 }
 
 func (c WrapConn) Exec(query string, args []driver.Value) (res driver.Result, err error) {
 	if exec, ok := c.Conn.(driver.Execer); ok {
-		sqlCtx, _ := whatapsql.StartWithParam(c.ctx, c.dataSourceName, query, convertDriverValue(args)...)
-		for i := 0; i < 5; i++ {
-			res, err = exec.Exec(query, args)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
-		whatapsql.End(sqlCtx, err)
+		sqlCtx, _ := whatapsql.StartWithParam(c.state.withTx(c.ctx), c.dataSourceName, query, convertDriverValue(args)...)
+		res, err = exec.Exec(query, args)
+		whatapsql.EndWithRows(sqlCtx, rowsAffected(res, err), err)
 		return res, err
 	}
 	return nil, driver.ErrSkip
@@ -120,17 +147,9 @@ func (c WrapConn) Exec(query string, args []driver.Value) (res driver.Result, er
 func (c WrapConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
 	wCtx := selectContext(ctx, c.ctx)
 	if execCtx, ok := c.Conn.(driver.ExecerContext); ok {
-		sqlCtx, _ := whatapsql.StartWithParam(wCtx, c.dataSourceName, query, convertDriverNamedValue(args)...)
-		for i := 0; i < 5; i++ {
-			res, err = execCtx.ExecContext(ctx, query, args)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
-		whatapsql.End(sqlCtx, err)
+		sqlCtx, _ := whatapsql.StartWithParam(c.state.withTx(wCtx), c.dataSourceName, query, convertDriverNamedValue(args)...)
+		res, err = execCtx.ExecContext(ctx, query, args)
+		whatapsql.EndWithRows(sqlCtx, rowsAffected(res, err), err)
 		return res, err
 	}
 	return nil, driver.ErrSkip
@@ -138,19 +157,10 @@ func (c WrapConn) ExecContext(ctx context.Context, query string, args []driver.N
 
 func (c WrapConn) Query(query string, args []driver.Value) (rows driver.Rows, err error) {
 	if queryer, ok := c.Conn.(driver.Queryer); ok {
-		sqlCtx, _ := whatapsql.StartWithParam(c.ctx, c.dataSourceName, query, convertDriverValue(args)...)
-
-		for i := 0; i < 5; i++ {
-			rows, err = queryer.Query(query, args)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
-		whatapsql.End(sqlCtx, err)
-		return
+		sqlCtx, _ := whatapsql.StartWithParam(c.state.withTx(c.ctx), c.dataSourceName, query, convertDriverValue(args)...)
+
+		rows, err = queryer.Query(query, args)
+		return wrapRows(rows, whatapsql.StartFetch(sqlCtx, err)), err
 	}
 	return nil, driver.ErrSkip
 }
@@ -158,65 +168,32 @@ func (c WrapConn) Query(query string, args []driver.Value) (rows driver.Rows, er
 func (c WrapConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
 	wCtx := selectContext(ctx, c.ctx)
 	if queryerCtx, ok := c.Conn.(driver.QueryerContext); ok {
-		sqlCtx, _ := whatapsql.StartWithParam(wCtx, c.dataSourceName, query, convertDriverNamedValue(args)...)
-		for i := 0; i < 5; i++ {
-			rows, err = queryerCtx.QueryContext(ctx, query, args)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
-		whatapsql.End(sqlCtx, err)
-		return
+		sqlCtx, _ := whatapsql.StartWithParam(c.state.withTx(wCtx), c.dataSourceName, query, convertDriverNamedValue(args)...)
+		rows, err = queryerCtx.QueryContext(ctx, query, args)
+		return wrapRows(rows, whatapsql.StartFetch(sqlCtx, err)), err
 	}
 	return nil, driver.ErrSkip
 }
 func (c WrapConn) Prepare(query string) (stmt driver.Stmt, err error) {
-	for i := 0; i < 5; i++ {
-		stmt, err = c.Conn.Prepare(query)
-		if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-			fmt.Println("[mysql] retrying", err)
-			time.Sleep(time.Second * 5)
-			continue
-		}
-		break
-	}
+	stmt, err = c.Conn.Prepare(query)
 
 	if err != nil {
 		return nil, err
 	}
-	return driver.Stmt(WrapStmt{stmt, c.ctx, c.dataSourceName, query}), err
+	return driver.Stmt(WrapStmt{stmt, c.ctx, c.dataSourceName, query, c.state}), err
 }
 
 func (c WrapConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
 	wCtx := selectContext(ctx, c.ctx)
 	if prepCtx, ok := c.Conn.(driver.ConnPrepareContext); ok {
-		for i := 0; i < 5; i++ {
-			stmt, err = prepCtx.PrepareContext(ctx, query)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
+		stmt, err = prepCtx.PrepareContext(ctx, query)
 	} else {
-		for i := 0; i < 5; i++ {
-			stmt, err = c.Conn.Prepare(query)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
+		stmt, err = c.Conn.Prepare(query)
 	}
 	if err != nil {
 		return nil, err
 	}
-	return driver.Stmt(WrapStmt{stmt, wCtx, c.dataSourceName, query}), err
+	return driver.Stmt(WrapStmt{stmt, wCtx, c.dataSourceName, query, c.state}), err
 }
 
 func (c WrapConn) Close() error {
@@ -241,15 +218,7 @@ func (c WrapConn) ResetSession(ctx context.Context) error {
 }
 func (c WrapConn) Begin() (tx driver.Tx, err error) {
 	st := dateutil.SystemNow()
-	for i := 0; i < 5; i++ {
-		tx, err = c.Conn.Begin()
-		if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-			fmt.Println("[mysql] retrying", err)
-			time.Sleep(time.Second * 5)
-			continue
-		}
-		break
-	}
+	tx, err = c.Conn.Begin()
 	elapsed := dateutil.SystemNow() - st
 	if elapsed < 0 {
 		elapsed = 0
@@ -259,22 +228,14 @@ func (c WrapConn) Begin() (tx driver.Tx, err error) {
 		trace.Error(c.ctx, err)
 		return nil, err
 	}
-	return WrapTx{tx, c.ctx, c.dataSourceName}, nil
+	return WrapTx{tx, c.ctx, c.dataSourceName, c.state, c.state.begin(c.ctx, c.dataSourceName)}, nil
 }
 
 func (c WrapConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
 	wCtx := selectContext(ctx, c.ctx)
 	if connBeginTx, ok := c.Conn.(driver.ConnBeginTx); ok {
 		st := dateutil.SystemNow()
-		for i := 0; i < 5; i++ {
-			tx, err = connBeginTx.BeginTx(ctx, opts)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
+		tx, err = connBeginTx.BeginTx(ctx, opts)
 		elapsed := dateutil.SystemNow() - st
 		if elapsed < 0 {
 			elapsed = 0
@@ -284,21 +245,13 @@ func (c WrapConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver
 			trace.Error(wCtx, err)
 			return nil, err
 		}
-		return WrapTx{tx, wCtx, c.dataSourceName}, nil
-	}
-	for i := 0; i < 5; i++ {
-		tx, err = c.Conn.Begin()
-		if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-			fmt.Println("[mysql] retrying", err)
-			time.Sleep(time.Second * 5)
-			continue
-		}
-		break
+		return WrapTx{tx, wCtx, c.dataSourceName, c.state, c.state.begin(wCtx, c.dataSourceName)}, nil
 	}
+	tx, err = c.Conn.Begin()
 	if err != nil {
 		return nil, err
 	}
-	return WrapTx{tx, wCtx, c.dataSourceName}, nil
+	return WrapTx{tx, wCtx, c.dataSourceName, c.state, c.state.begin(wCtx, c.dataSourceName)}, nil
 }
 
 type WrapStmt struct {
@@ -306,37 +259,22 @@ type WrapStmt struct {
 	ctx            context.Context
 	dataSourceName string
 	preparedSql    string
+	state          *connState
 }
 
 func (s WrapStmt) Exec(args []driver.Value) (res driver.Result, err error) {
-	sqlCtx, _ := whatapsql.StartWithParam(s.ctx, s.dataSourceName, s.preparedSql, convertDriverValue(args)...)
-	for i := 0; i < 5; i++ {
-		res, err = s.Stmt.Exec(args)
-		if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-			fmt.Println("[mysql] retrying", err)
-			time.Sleep(time.Second * 5)
-			continue
-		}
-		break
-	}
-	whatapsql.End(sqlCtx, err)
+	sqlCtx, _ := whatapsql.StartWithParam(s.state.withTx(s.ctx), s.dataSourceName, s.preparedSql, convertDriverValue(args)...)
+	res, err = s.Stmt.Exec(args)
+	whatapsql.EndWithRows(sqlCtx, rowsAffected(res, err), err)
 	return res, err
 }
 
 func (s WrapStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
 	wCtx := selectContext(ctx, s.ctx)
 	if execCtx, ok := s.Stmt.(driver.StmtExecContext); ok {
-		sqlCtx, _ := whatapsql.StartWithParam(wCtx, s.dataSourceName, s.preparedSql, convertDriverNamedValue(args)...)
-		for i := 0; i < 5; i++ {
-			res, err = execCtx.ExecContext(ctx, args)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
-		whatapsql.End(sqlCtx, err)
+		sqlCtx, _ := whatapsql.StartWithParam(s.state.withTx(wCtx), s.dataSourceName, s.preparedSql, convertDriverNamedValue(args)...)
+		res, err = execCtx.ExecContext(ctx, args)
+		whatapsql.EndWithRows(sqlCtx, rowsAffected(res, err), err)
 		return res, err
 	}
 	dargs, err := namedValueToValue(args)
@@ -347,35 +285,17 @@ func (s WrapStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (re
 }
 
 func (s WrapStmt) Query(args []driver.Value) (rows driver.Rows, err error) {
-	sqlCtx, _ := whatapsql.StartWithParam(s.ctx, s.dataSourceName, s.preparedSql, convertDriverValue(args)...)
-	for i := 0; i < 5; i++ {
-		rows, err = s.Stmt.Query(args)
-		if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-			fmt.Println("[mysql] retrying", err)
-			time.Sleep(time.Second * 5)
-			continue
-		}
-		break
-	}
-	whatapsql.End(sqlCtx, err)
-	return
+	sqlCtx, _ := whatapsql.StartWithParam(s.state.withTx(s.ctx), s.dataSourceName, s.preparedSql, convertDriverValue(args)...)
+	rows, err = s.Stmt.Query(args)
+	return wrapRows(rows, whatapsql.StartFetch(sqlCtx, err)), err
 }
 
 func (s WrapStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
 	wCtx := selectContext(ctx, s.ctx)
 	if queryerCtx, ok := s.Stmt.(driver.StmtQueryContext); ok {
-		sqlCtx, _ := whatapsql.StartWithParam(wCtx, s.dataSourceName, s.preparedSql, convertDriverNamedValue(args)...)
-		for i := 0; i < 5; i++ {
-			rows, err = queryerCtx.QueryContext(ctx, args)
-			if errors.Is(err, mysql.ErrInvalidConn) || strings.Contains(err.Error(), "No connection could be made because the target machine actively refused it") {
-				fmt.Println("[mysql] retrying", err)
-				time.Sleep(time.Second * 5)
-				continue
-			}
-			break
-		}
-		whatapsql.End(sqlCtx, err)
-		return
+		sqlCtx, _ := whatapsql.StartWithParam(s.state.withTx(wCtx), s.dataSourceName, s.preparedSql, convertDriverNamedValue(args)...)
+		rows, err = queryerCtx.QueryContext(ctx, args)
+		return wrapRows(rows, whatapsql.StartFetch(sqlCtx, err)), err
 	}
 	dargs, err := namedValueToValue(args)
 	if err != nil {
@@ -388,6 +308,8 @@ type WrapTx struct {
 	driver.Tx
 	ctx            context.Context
 	dataSourceName string
+	state          *connState
+	txCtx          *whatapsql.TxCtx
 }
 
 func (t WrapTx) Commit() (err error) {
@@ -401,6 +323,7 @@ func (t WrapTx) Commit() (err error) {
 	if err != nil {
 		trace.Error(t.ctx, err)
 	}
+	t.state.end(t.txCtx, whatapsql.TX_COMMIT, err)
 	return err
 }
 
@@ -415,9 +338,103 @@ func (t WrapTx) Rollback() (err error) {
 	if err != nil {
 		trace.Error(t.ctx, err)
 	}
+	t.state.end(t.txCtx, whatapsql.TX_ROLLBACK, err)
+	return err
+}
+
+// WrapRows counts the fetched rows and records the fetch count and the fetch time when the rows are closed.
+type WrapRows struct {
+	driver.Rows
+	fetchCtx *whatapsql.FetchCtx
+	// io.EOF 를 제외한 fetch 오류
+	err error
+}
+
+func wrapRows(rows driver.Rows, fetchCtx *whatapsql.FetchCtx) driver.Rows {
+	if rows == nil || fetchCtx == nil {
+		return rows
+	}
+	return &WrapRows{Rows: rows, fetchCtx: fetchCtx}
+}
+
+func (r *WrapRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.fetchCtx.Fetch()
+	} else if err != io.EOF {
+		r.err = err
+	}
 	return err
 }
 
+func (r *WrapRows) Close() error {
+	err := r.Rows.Close()
+	whatapsql.EndFetch(r.fetchCtx, r.err)
+	return err
+}
+
+func (r *WrapRows) HasNextResultSet() bool {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *WrapRows) NextResultSet() error {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *WrapRows) ColumnTypeScanType(index int) reflect.Type {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return ct.ColumnTypeScanType(index)
+	}
+	// database/sql 의 기본값
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *WrapRows) ColumnTypeDatabaseTypeName(index int) string {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return ct.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *WrapRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return ct.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *WrapRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return ct.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *WrapRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return ct.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+// rowsAffected returns the affected rows of res, or -1 if it is not supported by the driver.
+func rowsAffected(res driver.Result, err error) int64 {
+	if err != nil || res == nil {
+		return -1
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
 func convertDriverValue(args []driver.Value) []interface{} {
 	iArgs := make([]interface{}, 0)
 	for _, it := range args {
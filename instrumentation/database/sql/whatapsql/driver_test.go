@@ -0,0 +1,257 @@
+package whatapsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/lang/step"
+)
+
+var errFake = errors.New("fake error")
+
+// fakeConn is the driver.Conn which returns rows rows for the query and affected rows for the exec.
+type fakeConn struct {
+	rows     int
+	affected int64
+	// rows 번째 행 이후 Next 에서 반환
+	nextErr error
+	// 첫 Next 에서 대기
+	nextDelay time.Duration
+	err       error
+	// Commit, Rollback 의 오류
+	txErr error
+
+	queries int32
+	execs   int32
+	begins  int32
+	commits int32
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	atomic.AddInt32(&c.begins, 1)
+	return &fakeTx{c: c, err: c.txErr}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt32(&c.queries, 1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &fakeRows{c: c}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	atomic.AddInt32(&c.execs, 1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return driver.RowsAffected(c.affected), nil
+}
+
+type fakeTx struct {
+	c   *fakeConn
+	err error
+}
+
+func (t *fakeTx) Commit() error {
+	atomic.AddInt32(&t.c.commits, 1)
+	return t.err
+}
+func (t *fakeTx) Rollback() error { return t.err }
+
+type fakeRows struct {
+	c *fakeConn
+	n int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.n == 0 && r.c.nextDelay > 0 {
+		time.Sleep(r.c.nextDelay)
+	}
+	if r.n >= r.c.rows {
+		if r.c.nextErr != nil {
+			return r.c.nextErr
+		}
+		return io.EOF
+	}
+	r.n++
+	dest[0] = int64(r.n)
+	return nil
+}
+
+type fakeConnector struct {
+	c *fakeConn
+}
+
+func (f fakeConnector) Connect(context.Context) (driver.Conn, error) { return f.c, nil }
+func (f fakeConnector) Driver() driver.Driver                        { return nil }
+
+func openFakeDB(t *testing.T, c *fakeConn) *sql.DB {
+	db, err := OpenDB(context.Background(), "fake://user:pw@localhost/test", fakeConnector{c})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func startTx(t *testing.T) (context.Context, *trace.TraceCtx) {
+	ctx, _ := trace.Start(context.Background(), "/whatapsql")
+	_, traceCtx := trace.GetTraceContext(ctx)
+	if !assert.NotNil(t, traceCtx) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { trace.End(ctx, nil) })
+	return ctx, traceCtx
+}
+
+func setCursor(t *testing.T, b bool) {
+	conf := agentconfig.GetConfig()
+	old := conf.GoSqlProfileCursorEnabled
+	conf.GoSqlProfileCursorEnabled = b
+	t.Cleanup(func() { conf.GoSqlProfileCursorEnabled = old })
+}
+
+func getSqlSteps(traceCtx *trace.TraceCtx) ([]*step.SqlStepX, []*step.ResultSetStep) {
+	sqls := make([]*step.SqlStepX, 0)
+	rss := make([]*step.ResultSetStep, 0)
+	for _, it := range traceCtx.Ctx.Profile.GetSteps() {
+		switch st := it.(type) {
+		case *step.SqlStepX:
+			sqls = append(sqls, st)
+		case *step.ResultSetStep:
+			rss = append(rss, st)
+		}
+	}
+	return sqls, rss
+}
+
+func queryAll(ctx context.Context, db *sql.DB) (int, error) {
+	rows, err := db.QueryContext(ctx, "select id from t")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err()
+}
+
+func TestRowsFetch(t *testing.T) {
+	setCursor(t, false)
+	c := &fakeConn{rows: 3}
+	db := openFakeDB(t, c)
+	ctx, traceCtx := startTx(t)
+
+	n, err := queryAll(ctx, db)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	// Rows.Close 에서 fetch 수와 시간을 기록
+	sqls, rss := getSqlSteps(traceCtx)
+	assert.Equal(t, 1, len(sqls))
+	if assert.Equal(t, 1, len(rss)) {
+		assert.Equal(t, int32(3), rss[0].Fetch)
+		assert.Equal(t, sqls[0].Hash, rss[0].SqlHash)
+	}
+	assert.Equal(t, int32(3), traceCtx.Ctx.RsCount)
+	assert.Equal(t, int64(0), traceCtx.Ctx.Error)
+}
+
+func TestRowsAffected(t *testing.T) {
+	c := &fakeConn{affected: 5}
+	db := openFakeDB(t, c)
+	ctx, traceCtx := startTx(t)
+
+	res, err := db.ExecContext(ctx, "update t set v = 1")
+	assert.Nil(t, err)
+	n, _ := res.RowsAffected()
+	assert.Equal(t, int64(5), n)
+
+	// RowsAffected 는 update 수로 기록
+	assert.Equal(t, int32(1), traceCtx.Ctx.JdbcUpdated)
+	assert.Equal(t, int32(5), traceCtx.Ctx.JdbcUpdateRecord)
+}
+
+func TestRowsCursor(t *testing.T) {
+	c := &fakeConn{rows: 2, nextDelay: 50 * time.Millisecond}
+
+	// go.sql_profile_cursor_enabled 이면 sql step 은 Rows.Close 까지의 시간
+	setCursor(t, true)
+	db := openFakeDB(t, c)
+	ctx, traceCtx := startTx(t)
+	_, err := queryAll(ctx, db)
+	assert.Nil(t, err)
+	sqls, rss := getSqlSteps(traceCtx)
+	if assert.Equal(t, 1, len(sqls)) && assert.Equal(t, 1, len(rss)) {
+		assert.True(t, sqls[0].Elapsed >= 50, "elapsed %d", sqls[0].Elapsed)
+		assert.True(t, rss[0].Elapsed >= 50, "fetch elapsed %d", rss[0].Elapsed)
+	}
+
+	setCursor(t, false)
+	ctx, traceCtx = startTx(t)
+	_, err = queryAll(ctx, db)
+	assert.Nil(t, err)
+	sqls, rss = getSqlSteps(traceCtx)
+	if assert.Equal(t, 1, len(sqls)) && assert.Equal(t, 1, len(rss)) {
+		assert.True(t, sqls[0].Elapsed < 50, "elapsed %d", sqls[0].Elapsed)
+		assert.True(t, rss[0].Elapsed >= 50, "fetch elapsed %d", rss[0].Elapsed)
+	}
+}
+
+func TestNoRetryOnError(t *testing.T) {
+	c := &fakeConn{err: errFake}
+	db := openFakeDB(t, c)
+	ctx, traceCtx := startTx(t)
+
+	_, err := db.QueryContext(ctx, "select id from t")
+	assert.Equal(t, errFake, err)
+	_, err = db.ExecContext(ctx, "update t set v = 1")
+	assert.Equal(t, errFake, err)
+
+	// 오류가 발생해도 다시 실행하지 않음
+	assert.Equal(t, int32(1), atomic.LoadInt32(&c.queries))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&c.execs))
+	sqls, _ := getSqlSteps(traceCtx)
+	if assert.Equal(t, 2, len(sqls)) {
+		assert.NotEqual(t, int64(0), sqls[0].Error)
+		assert.NotEqual(t, int64(0), sqls[1].Error)
+	}
+}
+
+func TestRowsNextError(t *testing.T) {
+	for _, cursor := range []bool{true, false} {
+		setCursor(t, cursor)
+		c := &fakeConn{rows: 1, nextErr: errFake}
+		db := openFakeDB(t, c)
+		ctx, traceCtx := startTx(t)
+
+		n, err := queryAll(ctx, db)
+		assert.Equal(t, errFake, err)
+		assert.Equal(t, 1, n)
+
+		// cursor 를 사용하지 않아도 Next 의 오류는 트랜잭션의 오류로 기록
+		assert.NotEqual(t, int64(0), traceCtx.Ctx.Error, "cursor=%v", cursor)
+		_, rss := getSqlSteps(traceCtx)
+		if assert.Equal(t, 1, len(rss), "cursor=%v", cursor) {
+			assert.Equal(t, int32(1), rss[0].Fetch)
+		}
+	}
+}
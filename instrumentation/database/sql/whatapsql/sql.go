@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/whatap/go-api/agent/agent/config"
+	whatapsql "github.com/whatap/go-api/sql"
 )
 
 var (
@@ -14,6 +15,8 @@ var (
 	whatapDrivers = make(map[string]driver.Driver)
 )
 
+// Open opens the database of which the connections and the statements are traced.
+// The statistics of the connection pool (DBStats) are reported by the dataSourceName of which the password is hidden.
 func Open(driverName, dataSourceName string) (*sql.DB, error) {
 	return OpenWithRegister(context.Background(), driverName, dataSourceName)
 }
@@ -24,7 +27,11 @@ func OpenContext(ctx context.Context, driverName, dataSourceName string) (*sql.D
 func OpenWithRegister(ctx context.Context, driverName, dataSourceName string) (*sql.DB, error) {
 	conf := config.GetConfig()
 	if !conf.GoSqlProfileEnabled {
-		return sql.Open(driverName, dataSourceName)
+		db, err := sql.Open(driverName, dataSourceName)
+		if err == nil {
+			whatapsql.RegisterDB(dataSourceName, db)
+		}
+		return db, err
 	}
 	regName := "whatap-" + driverName
 	regMux.Lock()
@@ -33,10 +40,12 @@ func OpenWithRegister(ctx context.Context, driverName, dataSourceName string) (*
 	if !ok {
 		db, err := sql.Open(driverName, dataSourceName)
 		if err != nil {
+			regMux.Unlock()
 			return nil, err
 		}
 		dri := db.Driver()
 		if err = db.Close(); err != nil {
+			regMux.Unlock()
 			return nil, err
 		}
 		whatapDriver = driver.Driver(WhatapDriver{dri, ctx})
@@ -50,10 +59,14 @@ func OpenWithRegister(ctx context.Context, driverName, dataSourceName string) (*
 		return nil, err
 	}
 
-	return sql.OpenDB(connector), nil
+	db := sql.OpenDB(connector)
+	whatapsql.RegisterDB(dataSourceName, db)
+	return db, nil
 }
 
 func OpenDB(ctx context.Context, dataSourceName string, connector driver.Connector) (*sql.DB, error) {
 	connector = WrapConnector{connector, ctx, dataSourceName}
-	return sql.OpenDB(connector), nil
+	db := sql.OpenDB(connector)
+	whatapsql.RegisterDB(dataSourceName, db)
+	return db, nil
 }
@@ -0,0 +1,134 @@
+package whatapsql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/httpc"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/lang/step"
+	"github.com/whatap/golib/util/hash"
+)
+
+func getMessages(traceCtx *trace.TraceCtx, title string) []string {
+	rt := make([]string, 0)
+	h := hash.HashStr(title)
+	for _, it := range traceCtx.Ctx.Profile.GetSteps() {
+		if st, ok := it.(*step.MessageStep); ok && st.Hash == h {
+			rt = append(rt, st.Desc)
+		}
+	}
+	return rt
+}
+
+func getTxStep(traceCtx *trace.TraceCtx) *step.MethodStepX {
+	for _, it := range traceCtx.Ctx.Profile.GetSteps() {
+		if st, ok := it.(*step.MethodStepX); ok {
+			return st
+		}
+	}
+	return nil
+}
+
+func TestTxOutcome(t *testing.T) {
+	tests := []struct {
+		name    string
+		commit  bool
+		txErr   error
+		outcome string
+	}{
+		{"commit", true, nil, "commit"},
+		{"rollback", false, nil, "rollback"},
+		{"commit error", true, errFake, "error"},
+		{"rollback error", false, errFake, "error"},
+	}
+	for _, tt := range tests {
+		c := &fakeConn{rows: 1, affected: 1, txErr: tt.txErr}
+		db := openFakeDB(t, c)
+		ctx, traceCtx := startTx(t)
+
+		tx, err := db.BeginTx(ctx, nil)
+		assert.Nil(t, err, tt.name)
+		_, err = tx.ExecContext(ctx, "update t set v = 1")
+		assert.Nil(t, err, tt.name)
+		rows, err := tx.QueryContext(ctx, "select id from t")
+		if assert.Nil(t, err, tt.name) {
+			rows.Close()
+		}
+		if tt.commit {
+			assert.Equal(t, tt.txErr, tx.Commit(), tt.name)
+		} else {
+			assert.Equal(t, tt.txErr, tx.Rollback(), tt.name)
+		}
+
+		assert.Equal(t, 1, len(getMessages(traceCtx, "DB Transaction Begin")), tt.name)
+		msgs := getMessages(traceCtx, "DB Transaction")
+		if assert.Equal(t, 1, len(msgs), tt.name) {
+			assert.True(t, strings.Contains(msgs[0], ", "+tt.outcome+", statements=2,"), "%s: %s", tt.name, msgs[0])
+		}
+		assert.Equal(t, 0, len(getMessages(traceCtx, "DB Transaction Warning")), tt.name)
+
+		// sql step 은 sql.Tx(dbhost) step 의 하위 step
+		txStep := getTxStep(traceCtx)
+		sqls, _ := getSqlSteps(traceCtx)
+		if assert.NotNil(t, txStep, tt.name) && assert.Equal(t, 2, len(sqls), tt.name) {
+			for _, st := range sqls {
+				assert.Equal(t, txStep.GetIndex(), st.GetParent(), tt.name)
+			}
+		}
+		if tt.txErr != nil {
+			assert.NotEqual(t, int64(0), traceCtx.Ctx.Error, tt.name)
+		}
+	}
+}
+
+func TestTxOutside(t *testing.T) {
+	c := &fakeConn{affected: 1}
+	db := openFakeDB(t, c)
+	ctx, traceCtx := startTx(t)
+
+	tx, err := db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	// 트랜잭션 종료 후 같은 커넥션의 sql 은 최상위 step
+	_, err = db.ExecContext(ctx, "update t set v = 1")
+	assert.Nil(t, err)
+
+	sqls, _ := getSqlSteps(traceCtx)
+	if assert.Equal(t, 1, len(sqls)) {
+		assert.Equal(t, int32(-1), sqls[0].GetParent())
+	}
+	msgs := getMessages(traceCtx, "DB Transaction")
+	if assert.Equal(t, 1, len(msgs)) {
+		assert.True(t, strings.Contains(msgs[0], "statements=0"), msgs[0])
+	}
+}
+
+func TestTxHttpcWarning(t *testing.T) {
+	c := &fakeConn{affected: 1}
+	db := openFakeDB(t, c)
+	ctx, traceCtx := startTx(t)
+
+	tx, err := db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	_, err = tx.ExecContext(ctx, "update t set v = 1")
+	assert.Nil(t, err)
+	// 트랜잭션 중 원격 호출
+	httpcCtx, _ := httpc.Start(ctx, "http://remote/api")
+	httpc.End(httpcCtx, 200, "", nil)
+	assert.Nil(t, tx.Commit())
+
+	msgs := getMessages(traceCtx, "DB Transaction Warning")
+	if assert.Equal(t, 1, len(msgs)) {
+		assert.True(t, strings.Contains(msgs[0], "across 1 remote calls"), msgs[0])
+	}
+
+	// 트랜잭션 밖의 원격 호출은 경고하지 않음
+	httpcCtx, _ = httpc.Start(ctx, "http://remote/api")
+	httpc.End(httpcCtx, 200, "", nil)
+	tx, err = db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+	assert.Equal(t, 1, len(getMessages(traceCtx, "DB Transaction Warning")))
+}
@@ -0,0 +1,297 @@
+package whatapsarama
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/httpc"
+	"github.com/whatap/go-api/trace"
+)
+
+// 처리 중인 message 의 트랜잭션 context. ContextFromMessage 에서 조회
+var messageContexts sync.Map
+
+// ContextFromMessage returns the context of the transaction which consumes msg. (WrapConsumerGroupHandler)
+// If msg is not traced, context.Background() is returned.
+func ContextFromMessage(msg *sarama.ConsumerMessage) context.Context {
+	if v, ok := messageContexts.Load(msg); ok {
+		return v.(*consumeCtx).context()
+	}
+	return context.Background()
+}
+
+// consumeCtx is the transaction of the consumed message or the batch of messages.
+// The transaction starts when the first message is passed to the handler, so the time waiting for the handler is not included.
+type consumeCtx struct {
+	first *sarama.ConsumerMessage
+	once  sync.Once
+	ctx   context.Context
+
+	lock sync.Mutex
+	msgs []*sarama.ConsumerMessage
+	// 기록된 message 수
+	recorded int
+	// 더 이상 message 가 추가되지 않는 batch
+	closed bool
+	ended  bool
+	// handler 에 전달되었지만 아직 기록되지 않은 message
+	pending sync.WaitGroup
+}
+
+func newConsumeCtx(msg *sarama.ConsumerMessage) *consumeCtx {
+	return &consumeCtx{first: msg}
+}
+
+// context starts the transaction consumeTransaction/{topic} with the multi trace headers of the first message, if not started.
+// It is called by the consumer after the message is passed to the handler, or by the handler (ContextFromMessage) whichever is first.
+func (c *consumeCtx) context() context.Context {
+	c.once.Do(func() {
+		name := fmt.Sprintf("consumeTransaction/%s", c.first.Topic)
+		c.ctx, _ = trace.StartWithHeader(context.Background(), name, GetHeaders(c.first.Headers))
+	})
+	return c.ctx
+}
+
+// register makes the transaction of msg found by ContextFromMessage. It is called before msg is passed to the handler.
+// If last is true, no more message is added to the transaction.
+func (c *consumeCtx) register(msg *sarama.ConsumerMessage, last bool) {
+	c.lock.Lock()
+	c.msgs = append(c.msgs, msg)
+	c.closed = last
+	c.pending.Add(1)
+	c.lock.Unlock()
+	messageContexts.Store(msg, c)
+}
+
+// unregister removes msg which is not passed to the handler.
+func (c *consumeCtx) unregister(msg *sarama.ConsumerMessage) {
+	messageContexts.Delete(msg)
+	c.lock.Lock()
+	if n := len(c.msgs); n > 0 && c.msgs[n-1] == msg {
+		c.msgs = c.msgs[:n-1]
+	}
+	c.lock.Unlock()
+	c.pending.Done()
+}
+
+func (c *consumeCtx) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed
+}
+
+// processed reports whether every message of the closed transaction is passed to the handler and recorded.
+func (c *consumeCtx) processed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed && c.recorded == len(c.msgs)
+}
+
+// add records msg as the httpc step with the topic, partition and offset.
+func (c *consumeCtx) add(brokers []string, group string, msg *sarama.ConsumerMessage, highWaterMark int64) {
+	c.register(msg, true)
+	c.record(brokers, group, msg, highWaterMark)
+}
+
+// record records msg as the httpc step with the topic, partition and offset. The lag is recorded if highWaterMark >= 0.
+func (c *consumeCtx) record(brokers []string, group string, msg *sarama.ConsumerMessage, highWaterMark int64) {
+	defer c.pending.Done()
+	ctx := c.context()
+	httpcCtx, _ := httpc.Start(ctx, fmt.Sprintf("kafka://%s/%s?partition=%d&offset=%d", strings.Join(brokers, ","), msg.Topic, msg.Partition, msg.Offset))
+	httpc.End(httpcCtx, 0, "", nil)
+	profileBody(ctx, msg.Value)
+
+	if highWaterMark >= 0 {
+		// high water mark 는 다음에 추가될 message 의 offset
+		meter.GetInstanceMeterKafka().SetLag(group, msg.Topic, msg.Partition, highWaterMark-msg.Offset-1)
+	}
+	c.lock.Lock()
+	c.recorded++
+	c.lock.Unlock()
+}
+
+// mark ends the transaction if msg is the last message of the closed transaction. (session.MarkMessage)
+func (c *consumeCtx) mark(msg *sarama.ConsumerMessage) {
+	c.lock.Lock()
+	last := c.closed && len(c.msgs) > 0 && c.msgs[len(c.msgs)-1] == msg
+	c.lock.Unlock()
+	if last {
+		c.end(nil)
+	}
+}
+
+// end ends the transaction once. The message passed to the handler is recorded before the transaction ends.
+func (c *consumeCtx) end(err error) {
+	c.pending.Wait()
+	c.lock.Lock()
+	if c.ended {
+		c.lock.Unlock()
+		return
+	}
+	c.ended = true
+	msgs := c.msgs
+	c.lock.Unlock()
+
+	for _, msg := range msgs {
+		messageContexts.Delete(msg)
+	}
+	meter.GetInstanceMeterKafka().AddConsume(int32(len(msgs)), err != nil)
+	trace.End(c.context(), err)
+}
+
+type consumerGroupHandler struct {
+	sarama.ConsumerGroupHandler
+	brokers []string
+	group   string
+}
+
+// WrapConsumerGroupHandler returns the sarama.ConsumerGroupHandler which starts a transaction for each consumed message,
+// or for each batch of the messages fetched together if go.kafka_consume_batch_enabled is true.
+// The transaction ends when the handler marks the (last) message with session.MarkMessage, calls claim.Messages() again,
+// receives the next message or returns from ConsumeClaim, whichever is first.
+// The context of the transaction is found by ContextFromMessage(msg). The consumer lag of each partition is counted. (category go_kafka_lag)
+//
+//	err := group.Consume(ctx, topics, whatapsarama.WrapConsumerGroupHandler(brokers, groupID, handler))
+func WrapConsumerGroupHandler(brokers []string, group string, h sarama.ConsumerGroupHandler) sarama.ConsumerGroupHandler {
+	return &consumerGroupHandler{ConsumerGroupHandler: h, brokers: brokers, group: group}
+}
+
+func (h *consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	conf := agentconfig.GetConfig()
+	if !conf.GoKafkaProfileEnabled {
+		return h.ConsumerGroupHandler.ConsumeClaim(sess, claim)
+	}
+	c := &wrapClaim{
+		ConsumerGroupClaim: claim,
+		handler:            h,
+		batch:              conf.GoKafkaConsumeBatchEnabled,
+		messages:           make(chan *sarama.ConsumerMessage),
+		done:               make(chan struct{}),
+		exited:             make(chan struct{}),
+	}
+	if sess != nil {
+		sess = &wrapSession{ConsumerGroupSession: sess}
+	}
+	go c.run()
+	err := h.ConsumerGroupHandler.ConsumeClaim(sess, c)
+	c.err = err
+	close(c.done)
+	<-c.exited
+	return err
+}
+
+// wrapSession ends the transaction of the message marked by the handler.
+type wrapSession struct {
+	sarama.ConsumerGroupSession
+}
+
+func (s *wrapSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.ConsumerGroupSession.MarkMessage(msg, metadata)
+	if v, ok := messageContexts.Load(msg); ok {
+		v.(*consumeCtx).mark(msg)
+	}
+}
+
+type wrapClaim struct {
+	sarama.ConsumerGroupClaim
+	handler *consumerGroupHandler
+	batch   bool
+
+	messages chan *sarama.ConsumerMessage
+	// ConsumeClaim 이 종료되면 close
+	done   chan struct{}
+	exited chan struct{}
+	// ConsumeClaim 의 오류. done 이 close 된 후 읽음
+	err error
+
+	lock sync.Mutex
+	// message 를 추가하는 트랜잭션
+	cur *consumeCtx
+	// 마지막으로 handler 에 전달되어 기록된 message 의 트랜잭션
+	delivered *consumeCtx
+}
+
+func (c *wrapClaim) Messages() <-chan *sarama.ConsumerMessage {
+	// handler 가 다시 읽으면 전달된 message 의 처리가 끝난 것으로 판단
+	c.lock.Lock()
+	d := c.delivered
+	c.lock.Unlock()
+	if d != nil && d.processed() {
+		d.end(nil)
+	}
+	return c.messages
+}
+
+func (c *wrapClaim) current() *consumeCtx {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.cur
+}
+
+func (c *wrapClaim) run() {
+	defer close(c.exited)
+
+	in := c.ConsumerGroupClaim.Messages()
+	for c.next(in) {
+	}
+	// handler 가 마지막 message 를 처리하고 ConsumeClaim 을 종료할 때까지 대기
+	close(c.messages)
+	<-c.done
+	if cur := c.current(); cur != nil {
+		cur.end(c.err)
+	}
+}
+
+// next passes the next message to the handler. It returns false if the claim or ConsumeClaim is done.
+func (c *wrapClaim) next(in <-chan *sarama.ConsumerMessage) bool {
+	var msg *sarama.ConsumerMessage
+	select {
+	case msg = <-in:
+	case <-c.done:
+	}
+	if msg == nil {
+		return false
+	}
+	// 함께 가져온 message 가 더 없으면 batch 의 마지막 message
+	last := !c.batch || len(in) == 0
+
+	c.lock.Lock()
+	prev := c.cur
+	if prev == nil || prev.isClosed() {
+		c.cur = newConsumeCtx(msg)
+	} else {
+		prev = nil
+	}
+	cur := c.cur
+	c.lock.Unlock()
+	// handler 가 message 를 받은 직후 ContextFromMessage 로 조회할 수 있도록 전달 전에 등록
+	cur.register(msg, last)
+
+	select {
+	case c.messages <- msg:
+	case <-c.done:
+		// 전달하지 못한 message 는 기록하지 않음. 이전 트랜잭션은 run 에서 종료
+		cur.unregister(msg)
+		if prev != nil {
+			c.lock.Lock()
+			c.cur = prev
+			c.lock.Unlock()
+		}
+		return false
+	}
+	// 트랜잭션과 step 은 handler 에 전달된 후 기록
+	cur.record(c.handler.brokers, c.handler.group, msg, c.HighWaterMarkOffset())
+	c.lock.Lock()
+	c.delivered = cur
+	c.lock.Unlock()
+	// handler 가 다음 message 를 받았으므로 이전 트랜잭션은 처리 완료
+	if prev != nil {
+		prev.end(nil)
+	}
+	return true
+}
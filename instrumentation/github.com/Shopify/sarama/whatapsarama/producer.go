@@ -0,0 +1,198 @@
+package whatapsarama
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/httpc"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/util/dateutil"
+)
+
+// end ends the httpc step with the produce latency until ack and counts the message.
+func (p *produceCtx) end(msg *sarama.ProducerMessage, err error) {
+	meter.GetInstanceMeterKafka().AddProduce(dateutil.SystemNow()-p.startTime, err != nil)
+	if err == nil {
+		trace.Step(p.ctx, "Kafka Produce", fmt.Sprintf("topic=%s, partition=%d, offset=%d", msg.Topic, msg.Partition, msg.Offset), 0, 0)
+	}
+	httpc.End(p.httpcCtx, 0, "", err)
+	if p.started {
+		trace.End(p.ctx, err)
+	}
+}
+
+type syncProducer struct {
+	sarama.SyncProducer
+	brokers []string
+}
+
+// WrapSyncProducer returns the sarama.SyncProducer which records each message as the httpc step until ack.
+// The transaction is found from msg.Metadata (context.Context). If not, the transaction produceTransaction/{topic} is started.
+//
+//	p, err := sarama.NewSyncProducer(brokers, cfg)
+//	p = whatapsarama.WrapSyncProducer(brokers, p)
+//	p.SendMessage(&sarama.ProducerMessage{Topic: "topic", Value: sarama.StringEncoder("value"), Metadata: ctx})
+func WrapSyncProducer(brokers []string, p sarama.SyncProducer) sarama.SyncProducer {
+	return &syncProducer{SyncProducer: p, brokers: brokers}
+}
+
+func (p *syncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	conf := agentconfig.GetConfig()
+	if !conf.GoKafkaProfileEnabled {
+		return p.SyncProducer.SendMessage(msg)
+	}
+	pCtx := startProduce(p.brokers, msg)
+	partition, offset, err = p.SyncProducer.SendMessage(msg)
+	pCtx.end(msg, err)
+	return partition, offset, err
+}
+
+func (p *syncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	conf := agentconfig.GetConfig()
+	if !conf.GoKafkaProfileEnabled {
+		return p.SyncProducer.SendMessages(msgs)
+	}
+	pCtxs := make([]*produceCtx, len(msgs))
+	for i, msg := range msgs {
+		pCtxs[i] = startProduce(p.brokers, msg)
+	}
+	err := p.SyncProducer.SendMessages(msgs)
+
+	// 실패한 message 의 오류
+	errs := make(map[*sarama.ProducerMessage]error)
+	if pErrs, ok := err.(sarama.ProducerErrors); ok {
+		for _, it := range pErrs {
+			errs[it.Msg] = it.Err
+		}
+	} else if err != nil {
+		for _, msg := range msgs {
+			errs[msg] = err
+		}
+	}
+	for i, msg := range msgs {
+		pCtxs[i].end(msg, errs[msg])
+	}
+	return err
+}
+
+type asyncProducer struct {
+	sarama.AsyncProducer
+	brokers []string
+	// Return.Successes 를 사용하지 않으면 ack 를 알 수 없음
+	returnSuccesses bool
+
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+	// ack 를 기다리는 message 의 전송 시작 시간
+	pending sync.Map
+
+	inputDone chan struct{}
+	closeOnce sync.Once
+}
+
+// WrapAsyncProducer returns the sarama.AsyncProducer which records each message as the httpc step when it is sent to Input().
+// The produce latency until ack (cfg.Producer.Return.Successes) and the errors from Errors() are counted. (category go_kafka)
+// Successes() and Errors() of the returned producer must be read as the underlying producer.
+//
+//	p, err := sarama.NewAsyncProducer(brokers, cfg)
+//	p = whatapsarama.WrapAsyncProducer(brokers, cfg, p)
+func WrapAsyncProducer(brokers []string, cfg *sarama.Config, p sarama.AsyncProducer) sarama.AsyncProducer {
+	w := &asyncProducer{
+		AsyncProducer: p,
+		brokers:       brokers,
+		input:         make(chan *sarama.ProducerMessage),
+		successes:     make(chan *sarama.ProducerMessage),
+		errors:        make(chan *sarama.ProducerError),
+		inputDone:     make(chan struct{}),
+	}
+	if cfg != nil {
+		w.returnSuccesses = cfg.Producer.Return.Successes
+	}
+	go w.dispatch()
+	go func() {
+		for msg := range p.Successes() {
+			if v, ok := w.pending.LoadAndDelete(msg); ok {
+				meter.GetInstanceMeterKafka().AddProduce(dateutil.SystemNow()-v.(int64), false)
+			}
+			w.successes <- msg
+		}
+		close(w.successes)
+	}()
+	go func() {
+		for e := range p.Errors() {
+			if v, ok := w.pending.LoadAndDelete(e.Msg); ok {
+				meter.GetInstanceMeterKafka().AddProduce(dateutil.SystemNow()-v.(int64), true)
+			} else {
+				meter.GetInstanceMeterKafka().AddProduceError()
+			}
+			w.errors <- e
+		}
+		close(w.errors)
+	}()
+	return w
+}
+
+func (w *asyncProducer) dispatch() {
+	defer close(w.inputDone)
+	for msg := range w.input {
+		conf := agentconfig.GetConfig()
+		if conf.GoKafkaProfileEnabled {
+			pCtx := startProduce(w.brokers, msg)
+			// 사용자 트랜잭션이 ack 전에 종료될 수 있으므로 step 은 전송 요청까지만 기록
+			httpc.End(pCtx.httpcCtx, 0, "", nil)
+			if pCtx.started {
+				trace.End(pCtx.ctx, nil)
+			}
+			if w.returnSuccesses {
+				w.pending.Store(msg, pCtx.startTime)
+			} else {
+				meter.GetInstanceMeterKafka().AddProduce(0, false)
+			}
+		}
+		w.AsyncProducer.Input() <- msg
+	}
+}
+
+func (w *asyncProducer) Input() chan<- *sarama.ProducerMessage {
+	return w.input
+}
+
+func (w *asyncProducer) Successes() <-chan *sarama.ProducerMessage {
+	return w.successes
+}
+
+func (w *asyncProducer) Errors() <-chan *sarama.ProducerError {
+	return w.errors
+}
+
+// AsyncClose closes the producer after the messages of Input() are sent to the underlying producer.
+func (w *asyncProducer) AsyncClose() {
+	w.closeOnce.Do(func() {
+		close(w.input)
+		go func() {
+			<-w.inputDone
+			w.AsyncProducer.AsyncClose()
+		}()
+	})
+}
+
+// Close closes the producer and returns the errors of the remaining messages like sarama.AsyncProducer.
+func (w *asyncProducer) Close() error {
+	w.AsyncClose()
+	go func() {
+		for range w.successes {
+		}
+	}()
+	var errs sarama.ProducerErrors
+	for e := range w.errors {
+		errs = append(errs, e)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
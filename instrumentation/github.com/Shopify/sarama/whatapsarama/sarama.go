@@ -6,85 +6,158 @@ import (
 	"encoding/gob"
 	"fmt"
 	"net/http"
-	"reflect"
 	"strings"
 
 	"github.com/Shopify/sarama"
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/httpc"
 	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/util/dateutil"
 )
 
 const (
+	// Deprecated: gob 으로 인코딩된 이전 버전의 header. consume 할 때만 사용
 	saramaTraceCtx = "whatapTraceCtx"
 )
 
+// Interceptor is the sarama.ProducerInterceptor and sarama.ConsumerInterceptor.
+// It records the messages as the httpc steps and propagates the multi trace headers of the messages.
 type Interceptor struct {
 	Brokers []string
 }
 
+// OnSend records msg as the httpc step and adds the multi trace headers to msg.
+// OnSend is called before msg is queued to be sent, so the ack of the broker can't be observed and the elapsed time of the step is about 0ms.
+// Use WrapSyncProducer or WrapAsyncProducer to record the produce latency until ack.
 func (in *Interceptor) OnSend(msg *sarama.ProducerMessage) {
-	name := fmt.Sprintf("produceTransaction/%s", msg.Topic)
-
-	var produceCtx context.Context
-	var ok bool
-	metadata := msg.Metadata
+	conf := agentconfig.GetConfig()
+	if !conf.GoKafkaProfileEnabled {
+		return
+	}
+	p := startProduce(in.Brokers, msg)
+	// interceptor 는 ack 를 알 수 없으므로 전송 요청까지만 기록. elapsed 는 약 0ms
+	httpc.End(p.httpcCtx, 0, "", nil)
+	if p.started {
+		trace.End(p.ctx, nil)
+	}
+	msg.Metadata = p.mt
+}
 
-	if metadata != nil {
-		switch reflect.TypeOf(metadata).String() {
-		case "http.Header":
-			header, ok := metadata.(http.Header)
-			if ok != true {
-				fmt.Println("http.Header Covert Error!")
-			}
+// OnConsume records msg as the transaction consumeTransaction/{topic} which continues the trace of the producer.
+func (in *Interceptor) OnConsume(msg *sarama.ConsumerMessage) {
+	conf := agentconfig.GetConfig()
+	if !conf.GoKafkaProfileEnabled {
+		return
+	}
+	c := newConsumeCtx(msg)
+	c.add(in.Brokers, "", msg, -1)
+	c.end(nil)
+}
 
-			produceCtx, _ = trace.Start(context.Background(), name)
-			defer trace.End(produceCtx, nil)
+// produceCtx is the httpc step of the produced message.
+type produceCtx struct {
+	ctx context.Context
+	// 트랜잭션을 생성한 경우 true
+	started   bool
+	httpcCtx  *httpc.HttpcCtx
+	mt        http.Header
+	startTime int64
+}
 
-			trace.UpdateMtraceWithContext(produceCtx, header)
+// startProduce starts the httpc step in the transaction of msg.Metadata (context.Context) and sets the multi trace headers of msg.
+// If msg.Metadata is not a context, the transaction produceTransaction/{topic} is started.
+func startProduce(brokers []string, msg *sarama.ProducerMessage) *produceCtx {
+	p := &produceCtx{startTime: dateutil.SystemNow()}
+	name := fmt.Sprintf("produceTransaction/%s", msg.Topic)
+	switch m := msg.Metadata.(type) {
+	case http.Header:
+		p.ctx, _ = trace.StartWithHeader(context.Background(), name, m)
+		p.started = true
+	case context.Context:
+		// 사용자 트랜잭션의 context. mtrace, baggage 를 그대로 전달
+		p.ctx = m
+	default:
+		p.ctx, _ = trace.Start(context.Background(), name)
+		p.started = true
+	}
 
-		case "*context.emptyCtx":
-			produceCtx, ok = metadata.(context.Context)
-			if ok != true {
-				fmt.Println("context Covert Error!")
-			}
-		default:
-			produceCtx, _ = trace.Start(context.Background(), name)
-			defer trace.End(produceCtx, nil)
+	// GetMTrace 에서 생성된 step id 를 httpc step 에 사용
+	p.mt = trace.GetMTrace(p.ctx)
+	p.httpcCtx, _ = httpc.Start(p.ctx, fmt.Sprintf("kafka://%s/%s", strings.Join(brokers, ","), msg.Topic))
+	SetHeaders(msg, p.mt)
+	if msg.Value != nil && agentconfig.GetConfig().GoKafkaProfileBodyEnabled {
+		if b, err := msg.Value.Encode(); err == nil {
+			profileBody(p.ctx, b)
 		}
-	} else {
-		produceCtx, _ = trace.Start(context.Background(), name)
-		defer trace.End(produceCtx, nil)
 	}
+	return p
+}
 
-	buf := &bytes.Buffer{}
-	gob.NewEncoder(buf).Encode(trace.GetMTrace(produceCtx))
-	msg.Metadata = trace.GetMTrace(produceCtx)
-
-	record := sarama.RecordHeader{Key: []byte(saramaTraceCtx), Value: buf.Bytes()}
-	msg.Headers = append(msg.Headers, record)
-
-	text := fmt.Sprintf("Topic : %s, Key : %s, Value : %s, Brokers : %s", msg.Topic, msg.Key, msg.Value, strings.Join(in.Brokers, " "))
-
-	trace.Step(produceCtx, "Producer Interceptor Message", text, 1, 1)
+// profileBody records the message body truncated to go.kafka_profile_body_max_size. (go.kafka_profile_body_enabled)
+func profileBody(ctx context.Context, body []byte) {
+	conf := agentconfig.GetConfig()
+	if !conf.GoKafkaProfileBodyEnabled || len(body) == 0 {
+		return
+	}
+	text := string(body)
+	if max := int(conf.GoKafkaProfileBodyMaxSize); max > 0 && len(body) > max {
+		text = string(body[:max]) + "..."
+	}
+	trace.Step(ctx, "Kafka Message", text, 0, len(body))
 }
 
-func (in *Interceptor) OnConsume(msg *sarama.ConsumerMessage) {
-	name := fmt.Sprintf("consumeTransaction/%s", msg.Topic)
-	ctx, _ := trace.Start(context.Background(), name)
-	defer trace.End(ctx, nil)
-	text := fmt.Sprintf("Topic : %s, Key : %s, Vaslue : %s, Offset : %d, Time : %s", msg.Topic, string(msg.Key), string(msg.Value), msg.Offset, msg.Timestamp.String())
+// SetHeaders sets the multi trace headers (traceparent, x-wtap-mst, x-wtap-po, x-wtap-sp1 ...) as the record headers of msg.
+// Each header is a plain string value so that the consumers of other languages can continue the trace.
+func SetHeaders(msg *sarama.ProducerMessage, mt http.Header) {
+	conf := agentconfig.GetConfig()
+	keys := []string{conf.TraceMtraceTraceparentKey, conf.TraceMtraceTracestateKey, conf.TraceMtraceCallerKey,
+		conf.TraceMtracePoidKey, conf.TraceMtraceSpecKey1, conf.TraceMtraceBaggageKey}
+
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+len(keys))
+	// 재전송 등으로 이미 있는 header 는 교체
+	for _, it := range msg.Headers {
+		if !inKeys(string(it.Key), keys) {
+			headers = append(headers, it)
+		}
+	}
+	for _, k := range keys {
+		if v := mt.Get(k); v != "" {
+			headers = append(headers, sarama.RecordHeader{Key: []byte(strings.ToLower(k)), Value: []byte(v)})
+		}
+	}
+	msg.Headers = headers
+}
 
+// GetHeaders returns the record headers as http.Header for trace.StartWithHeader.
+// The gob encoded header of the previous version (whatapTraceCtx) is also decoded.
+func GetHeaders(headers []*sarama.RecordHeader) http.Header {
 	h := make(http.Header)
-
-	for _, header := range msg.Headers {
+	for _, header := range headers {
+		if header == nil {
+			continue
+		}
 		key := string(header.Key)
 		if key == saramaTraceCtx {
-			buf := bytes.NewBuffer(header.Value)
-			gob.NewDecoder(buf).Decode(&h)
-			trace.UpdateMtraceWithContext(ctx, h)
-			break
+			old := make(http.Header)
+			if err := gob.NewDecoder(bytes.NewBuffer(header.Value)).Decode(&old); err == nil {
+				for k, v := range old {
+					if len(h.Values(k)) == 0 {
+						h[k] = v
+					}
+				}
+			}
+			continue
 		}
+		h.Set(key, string(header.Value))
 	}
+	return h
+}
 
-	trace.Step(ctx, "Consumer Interceptor Message", text, 1, 1)
-
+func inKeys(key string, keys []string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(key, k) {
+			return true
+		}
+	}
+	return false
 }
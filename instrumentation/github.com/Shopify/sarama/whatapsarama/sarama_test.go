@@ -3,17 +3,39 @@ package whatapsarama
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
 	"net/http"
 	"testing"
 
 	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/assert"
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
 	"github.com/whatap/go-api/trace"
 	"golang.org/x/net/context"
 )
 
+func hasHeader(msg *sarama.ProducerMessage, key string) bool {
+	for _, header := range msg.Headers {
+		if string(header.Key) == key && len(header.Value) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// enableMtrace enables the multi trace and returns the func which restores the config.
+func enableMtrace() func() {
+	conf := agentconfig.GetConfig()
+	mtraceEnabled, mtraceRate := conf.MtraceEnabled, conf.MtraceRate
+	conf.MtraceEnabled, conf.MtraceRate = true, 100
+	return func() {
+		conf.MtraceEnabled, conf.MtraceRate = mtraceEnabled, mtraceRate
+	}
+}
+
 func TestOnSendWithoutMetadata(t *testing.T) {
 	assert := assert.New(t)
+	defer enableMtrace()()
 
 	brokers := []string{"1.1.1.1:9092"}
 	interceptor := Interceptor{Brokers: brokers}
@@ -26,15 +48,10 @@ func TestOnSendWithoutMetadata(t *testing.T) {
 
 	interceptor.OnSend(msg)
 
-	check := false
-	for _, header := range msg.Headers {
-		key := string(header.Key)
-		if key == saramaTraceCtx {
-			check = true
-		}
-	}
-
-	assert.True(check)
+	assert.True(hasHeader(msg, "traceparent"))
+	assert.True(hasHeader(msg, "x-wtap-mst"))
+	assert.True(hasHeader(msg, "x-wtap-po"))
+	assert.True(hasHeader(msg, "x-wtap-sp1"))
 
 	assert.Contains(msg.Topic, "Topic")
 	assert.Contains(msg.Key, "Key")
@@ -56,15 +73,8 @@ func TestOnSendWithContext(t *testing.T) {
 
 	interceptor.OnSend(msg)
 
-	check := false
-	for _, header := range msg.Headers {
-		key := string(header.Key)
-		if key == saramaTraceCtx {
-			check = true
-		}
-	}
-
-	assert.True(check)
+	// 트랜잭션이 없는 context 는 전달하지 않음
+	assert.False(hasHeader(msg, "traceparent"))
 
 	assert.Contains(msg.Topic, "Topic")
 	assert.Contains(msg.Key, "Key")
@@ -74,6 +84,7 @@ func TestOnSendWithContext(t *testing.T) {
 
 func TestOnSendWithHeader(t *testing.T) {
 	assert := assert.New(t)
+	defer enableMtrace()()
 
 	ctx, _ := trace.Start(context.Background(), "TEST")
 	defer trace.End(ctx, nil)
@@ -89,15 +100,10 @@ func TestOnSendWithHeader(t *testing.T) {
 
 	interceptor.OnSend(msg)
 
-	check := false
-	for _, header := range msg.Headers {
-		key := string(header.Key)
-		if key == saramaTraceCtx {
-			check = true
-		}
-	}
-
-	assert.True(check)
+	assert.True(hasHeader(msg, "traceparent"))
+	assert.True(hasHeader(msg, "x-wtap-mst"))
+	assert.True(hasHeader(msg, "x-wtap-po"))
+	assert.True(hasHeader(msg, "x-wtap-sp1"))
 	assert.Contains(msg.Topic, "Topic")
 	assert.Contains(msg.Key, "Key")
 	assert.Contains(msg.Value, "Value")
@@ -106,6 +112,7 @@ func TestOnSendWithHeader(t *testing.T) {
 
 func TestOnSendWithError(t *testing.T) {
 	assert := assert.New(t)
+	defer enableMtrace()()
 
 	meta := "inputError"
 
@@ -123,15 +130,10 @@ func TestOnSendWithError(t *testing.T) {
 
 	interceptor.OnSend(msg)
 
-	check := false
-	for _, header := range msg.Headers {
-		key := string(header.Key)
-		if key == saramaTraceCtx {
-			check = true
-		}
-	}
-
-	assert.True(check)
+	assert.True(hasHeader(msg, "traceparent"))
+	assert.True(hasHeader(msg, "x-wtap-mst"))
+	assert.True(hasHeader(msg, "x-wtap-po"))
+	assert.True(hasHeader(msg, "x-wtap-sp1"))
 	assert.Contains(msg.Topic, "Topic")
 	assert.Contains(msg.Key, "Key")
 	assert.Contains(msg.Value, "Value")
@@ -165,3 +167,56 @@ func TestOnConsumer(t *testing.T) {
 	assert.Equal(msg.Partition, int32(1))
 	assert.Equal(msg.Offset, int64(2))
 }
+
+func TestOnConsumeWithHeaders(t *testing.T) {
+	assert := assert.New(t)
+	defer enableMtrace()()
+
+	ctx, _ := trace.Start(context.Background(), "TEST")
+	defer trace.End(ctx, nil)
+	_, traceCtx := trace.GetTraceContext(ctx)
+
+	interceptor := Interceptor{Brokers: []string{"1.1.1.1:9092"}}
+	pmsg := &sarama.ProducerMessage{Topic: "Topic", Value: sarama.StringEncoder("Value"), Metadata: ctx,
+		Headers: []sarama.RecordHeader{{Key: []byte("traceparent"), Value: []byte("old")}, {Key: []byte("user"), Value: []byte("value")}}}
+	interceptor.OnSend(pmsg)
+	// 이미 있는 traceparent 는 교체
+	assert.Equal(6, len(pmsg.Headers))
+	assert.True(hasHeader(pmsg, "user"))
+
+	msg := &sarama.ConsumerMessage{Topic: "Topic", Value: []byte("Value"), Partition: 1, Offset: 2}
+	for i := range pmsg.Headers {
+		msg.Headers = append(msg.Headers, &pmsg.Headers[i])
+	}
+	h := GetHeaders(msg.Headers)
+	assert.Equal(fmt.Sprintf("00-0000000000000000%016x-%016x-01", uint64(traceCtx.MTid), uint64(traceCtx.MStepId)), h.Get("traceparent"))
+
+	// consumer 트랜잭션은 producer 의 mtid 로 시작
+	cCtx, _ := trace.StartWithHeader(context.Background(), "consumeTransaction/Topic", h)
+	_, cTraceCtx := trace.GetTraceContext(cCtx)
+	if assert.NotNil(cTraceCtx) {
+		assert.NotEqual(int64(0), cTraceCtx.MTid)
+		assert.Equal(traceCtx.MTid, cTraceCtx.MTid)
+		assert.Equal(traceCtx.MStepId, cTraceCtx.MCallerStepId)
+		assert.Equal(traceCtx.Txid, cTraceCtx.MCallerTxid)
+	}
+	trace.End(cCtx, nil)
+
+	interceptor.OnConsume(msg)
+}
+
+func TestGetHeadersGob(t *testing.T) {
+	assert := assert.New(t)
+
+	old := http.Header{}
+	old.Set("x-wtap-mst", "1,2,3,4")
+	buf := &bytes.Buffer{}
+	gob.NewEncoder(buf).Encode(old)
+
+	h := GetHeaders([]*sarama.RecordHeader{{Key: []byte(saramaTraceCtx), Value: buf.Bytes()}, nil})
+	assert.Equal("1,2,3,4", h.Get("x-wtap-mst"))
+
+	// per-key header 를 우선 사용
+	h = GetHeaders([]*sarama.RecordHeader{{Key: []byte("x-wtap-mst"), Value: []byte("5,6,7,8")}, {Key: []byte(saramaTraceCtx), Value: buf.Bytes()}})
+	assert.Equal("5,6,7,8", h.Get("x-wtap-mst"))
+}
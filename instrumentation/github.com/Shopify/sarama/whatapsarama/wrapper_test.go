@@ -0,0 +1,285 @@
+package whatapsarama
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/lang/step"
+	"github.com/whatap/golib/util/dateutil"
+)
+
+func getHttpcSteps(ctx context.Context) []*step.HttpcStepX {
+	rt := make([]*step.HttpcStepX, 0)
+	if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
+		for _, it := range traceCtx.Ctx.Profile.GetSteps() {
+			if st, ok := it.(*step.HttpcStepX); ok {
+				rt = append(rt, st)
+			}
+		}
+	}
+	return rt
+}
+
+func TestSyncProducer(t *testing.T) {
+	assert := assert.New(t)
+	defer enableMtrace()()
+	meter.GetInstanceMeterKafka().GetBucketReset()
+
+	ctx, _ := trace.Start(context.Background(), "TEST")
+	defer trace.End(ctx, nil)
+
+	mp := mocks.NewSyncProducer(t, nil)
+	mp.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if !hasHeader(msg, "traceparent") {
+			return errors.New("traceparent not found")
+		}
+		return nil
+	})
+	mp.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	p := WrapSyncProducer([]string{"1.1.1.1:9092"}, mp)
+	defer p.Close()
+
+	_, _, err := p.SendMessage(&sarama.ProducerMessage{Topic: "Topic", Value: sarama.StringEncoder("Value"), Metadata: ctx})
+	assert.Nil(err)
+	_, _, err = p.SendMessage(&sarama.ProducerMessage{Topic: "Topic", Value: sarama.StringEncoder("Value"), Metadata: ctx})
+	assert.Equal(sarama.ErrOutOfBrokers, err)
+
+	steps := getHttpcSteps(ctx)
+	if assert.Equal(2, len(steps)) {
+		errCount := 0
+		for _, st := range steps {
+			if st.Error != 0 {
+				errCount++
+			}
+		}
+		assert.Equal(1, errCount)
+	}
+
+	b, _ := meter.GetInstanceMeterKafka().GetBucketReset()
+	assert.Equal(int32(2), b.Produced)
+	assert.Equal(int32(1), b.ProduceErrors)
+}
+
+func TestAsyncProducer(t *testing.T) {
+	assert := assert.New(t)
+	meter.GetInstanceMeterKafka().GetBucketReset()
+
+	cfg := mocks.NewTestConfig()
+	cfg.Producer.Return.Successes = true
+	mp := mocks.NewAsyncProducer(t, cfg)
+	mp.ExpectInputAndSucceed()
+	mp.ExpectInputAndFail(sarama.ErrOutOfBrokers)
+	p := WrapAsyncProducer([]string{"1.1.1.1:9092"}, cfg, mp)
+
+	msg := &sarama.ProducerMessage{Topic: "Topic", Value: sarama.StringEncoder("Value")}
+	p.Input() <- msg
+	assert.Equal(msg, <-p.Successes())
+
+	msg = &sarama.ProducerMessage{Topic: "Topic", Value: sarama.StringEncoder("Value")}
+	p.Input() <- msg
+	e := <-p.Errors()
+	assert.Equal(msg, e.Msg)
+	assert.Equal(sarama.ErrOutOfBrokers, e.Err)
+
+	assert.Nil(p.Close())
+
+	b, _ := meter.GetInstanceMeterKafka().GetBucketReset()
+	assert.Equal(int32(2), b.Produced)
+	assert.Equal(int32(1), b.ProduceErrors)
+}
+
+type testClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *testClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+func (c *testClaim) HighWaterMarkOffset() int64               { return 10 }
+
+type testHandler struct {
+	txids []int64
+	err   error
+}
+
+func (h *testHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (h *testHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+func (h *testHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		if _, traceCtx := trace.GetTraceContext(ContextFromMessage(msg)); traceCtx != nil {
+			h.txids = append(h.txids, traceCtx.Txid)
+		}
+	}
+	return h.err
+}
+
+func newTestClaim() *testClaim {
+	c := &testClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	for i := 0; i < 3; i++ {
+		c.messages <- &sarama.ConsumerMessage{Topic: "Topic", Partition: 1, Offset: int64(i), Value: []byte("Value")}
+	}
+	close(c.messages)
+	return c
+}
+
+func TestConsumerGroupHandler(t *testing.T) {
+	assert := assert.New(t)
+	meter.GetInstanceMeterKafka().GetBucketReset()
+
+	h := &testHandler{}
+	err := WrapConsumerGroupHandler([]string{"1.1.1.1:9092"}, "group", h).ConsumeClaim(nil, newTestClaim())
+	assert.Nil(err)
+	// message 마다 트랜잭션 생성
+	if assert.Equal(3, len(h.txids)) {
+		assert.NotEqual(h.txids[0], h.txids[1])
+		assert.NotEqual(h.txids[1], h.txids[2])
+	}
+
+	b, lags := meter.GetInstanceMeterKafka().GetBucketReset()
+	assert.Equal(int32(3), b.Consumed)
+	if assert.Equal(1, len(lags)) {
+		assert.Equal("group", lags[0].Group)
+		assert.Equal(int32(1), lags[0].Partition)
+		assert.Equal(int64(7), lags[0].Lag)
+	}
+}
+
+func TestConsumerGroupHandlerBatch(t *testing.T) {
+	assert := assert.New(t)
+	conf := agentconfig.GetConfig()
+	batch := conf.GoKafkaConsumeBatchEnabled
+	conf.GoKafkaConsumeBatchEnabled = true
+	defer func() {
+		conf.GoKafkaConsumeBatchEnabled = batch
+	}()
+	meter.GetInstanceMeterKafka().GetBucketReset()
+
+	h := &testHandler{err: errors.New("consume error")}
+	err := WrapConsumerGroupHandler([]string{"1.1.1.1:9092"}, "group", h).ConsumeClaim(nil, newTestClaim())
+	assert.NotNil(err)
+	// 한 번에 가져온 message 는 하나의 트랜잭션
+	if assert.Equal(3, len(h.txids)) {
+		assert.Equal(h.txids[0], h.txids[1])
+		assert.Equal(h.txids[1], h.txids[2])
+	}
+
+	b, _ := meter.GetInstanceMeterKafka().GetBucketReset()
+	assert.Equal(int32(3), b.Consumed)
+}
+
+type slowHandler struct {
+	testHandler
+	waits []int64
+}
+
+func (h *slowHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		now := dateutil.SystemNow()
+		if _, traceCtx := trace.GetTraceContext(ContextFromMessage(msg)); traceCtx != nil {
+			h.waits = append(h.waits, now-traceCtx.StartTime)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestConsumerGroupHandlerStartTime(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &slowHandler{}
+	err := WrapConsumerGroupHandler([]string{"1.1.1.1:9092"}, "group", h).ConsumeClaim(nil, newTestClaim())
+	assert.Nil(err)
+	// 트랜잭션은 handler 에 전달된 후 시작. handler 의 이전 message 처리 시간은 포함하지 않음
+	if assert.Equal(3, len(h.waits)) {
+		for _, it := range h.waits {
+			assert.True(it < 40, "wait %dms", it)
+		}
+	}
+}
+
+type testSession struct {
+	sarama.ConsumerGroupSession
+	marked []*sarama.ConsumerMessage
+}
+
+func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg)
+}
+
+// markHandler marks each message and reports whether its transaction is ended.
+type markHandler struct {
+	testHandler
+	ended []bool
+}
+
+func (h *markHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		sess.MarkMessage(msg, "")
+		h.ended = append(h.ended, ContextFromMessage(msg) == context.Background())
+	}
+	return nil
+}
+
+func TestConsumerGroupHandlerMarkMessage(t *testing.T) {
+	assert := assert.New(t)
+	meter.GetInstanceMeterKafka().GetBucketReset()
+
+	claim := &testClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "Topic", Partition: 1, Offset: 0, Value: []byte("Value")}
+	sess := &testSession{}
+	h := &markHandler{}
+	done := make(chan error)
+	go func() {
+		done <- WrapConsumerGroupHandler([]string{"1.1.1.1:9092"}, "group", h).ConsumeClaim(sess, claim)
+	}()
+
+	// 다음 message 가 없어도 MarkMessage 에서 트랜잭션 종료
+	assert.Eventually(func() bool {
+		b, _ := meter.GetInstanceMeterKafka().GetBucketReset()
+		return b.Consumed == 1
+	}, time.Second, 10*time.Millisecond)
+	close(claim.messages)
+	assert.Nil(<-done)
+	assert.Equal(1, len(sess.marked))
+	assert.Equal([]bool{true}, h.ended)
+}
+
+// selectHandler reads claim.Messages() in each loop and reports whether the transaction of the previous message is ended.
+type selectHandler struct {
+	testHandler
+	ended []bool
+}
+
+func (h *selectHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	var prev *sarama.ConsumerMessage
+	for {
+		// step 이 기록될 때까지 처리
+		time.Sleep(20 * time.Millisecond)
+		msgs := claim.Messages()
+		if prev != nil {
+			h.ended = append(h.ended, ContextFromMessage(prev) == context.Background())
+		}
+		msg, ok := <-msgs
+		if !ok {
+			return nil
+		}
+		prev = msg
+	}
+}
+
+func TestConsumerGroupHandlerMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &selectHandler{}
+	err := WrapConsumerGroupHandler([]string{"1.1.1.1:9092"}, "group", h).ConsumeClaim(nil, newTestClaim())
+	assert.Nil(err)
+	// 다시 읽으면 이전 message 의 트랜잭션 종료
+	assert.Equal([]bool{true, true, true}, h.ended)
+}
@@ -20,6 +20,9 @@ func Middleware() gin.HandlerFunc {
 		}
 		ctx, _ := trace.StartWithRequest(c.Request)
 		c.Request = c.Request.WithContext(ctx)
+		// 라우팅된 경로 템플릿으로 서비스 이름 지정. ex) /users/:id
+		// sampling, ignore 를 판단하도록 handler 실행 전에 변경. 라우팅되지 않은 요청은 c.FullPath() 가 ""
+		trace.UpdateServiceName(ctx, c.FullPath())
 
 		defer func() {
 			x := recover()
@@ -38,11 +41,14 @@ func Middleware() gin.HandlerFunc {
 			status := c.Writer.Status()
 			if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
 				traceCtx.Status = int32(status)
+				traceCtx.RequestBytes = c.Request.ContentLength
+				if size := c.Writer.Size(); size > 0 {
+					traceCtx.ResponseBytes = int64(size)
+				}
 			}
 			if status >= 400 {
 				err = fmt.Errorf("Status: %d,%s", status, http.StatusText(status))
 			}
-
 			// trace http parameter
 			if conf.ProfileHttpParameterEnabled && strings.HasPrefix(c.Request.RequestURI, conf.ProfileHttpParameterUrlPrefix) {
 				if c.Request.Form != nil {
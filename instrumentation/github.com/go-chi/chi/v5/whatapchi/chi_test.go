@@ -0,0 +1,45 @@
+package whatapchi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutePattern(t *testing.T) {
+	patterns := make([]string, 0)
+	record := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			patterns = append(patterns, routePattern(r))
+			next.ServeHTTP(w, r)
+		})
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	r := chi.NewRouter()
+	r.Use(record)
+	r.Get("/users/{id}", ok)
+	r.Route("/api", func(r chi.Router) {
+		r.Use(record)
+		r.Get("/orders/{id}/items", ok)
+	})
+
+	tests := []struct {
+		method  string
+		url     string
+		pattern []string
+	}{
+		{"GET", "/users/10", []string{"/users/{id}"}},
+		{"GET", "/api/orders/7/items", []string{"/api/orders/{id}/items", "/api/orders/{id}/items"}},
+		{"GET", "/not/found", []string{""}},
+		{"POST", "/users/10", []string{""}},
+	}
+	for _, tt := range tests {
+		patterns = patterns[:0]
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.url, nil))
+		assert.Equal(t, tt.pattern, patterns, tt.url)
+	}
+}
@@ -3,11 +3,35 @@ package whatapchi
 import (
 	"net/http"
 
+	"github.com/go-chi/chi"
 	"github.com/whatap/go-api/trace"
 )
 
 func Middleware(next http.Handler) http.Handler {
 	return trace.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// sampling, ignore 를 판단하도록 handler 실행 전에 경로 템플릿으로 서비스 이름 지정. ex) /users/{id}
+		if pattern := routePattern(r); pattern != "" {
+			trace.UpdateServiceName(r.Context(), pattern)
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// routePattern returns the route pattern which the request will be routed to. It returns "" if no route matches.
+// The middleware of chi runs before routing, so the routes of the root router are matched with a new routing context.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+	// Mount 된 sub router 에서도 rctx.Routes 는 root router 이므로 전체 경로로 확인
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return ""
+	}
+	return tctx.RoutePattern()
+}
@@ -4,53 +4,101 @@ import (
 	"context"
 
 	"github.com/whatap/go-api/sql"
+	"github.com/whatap/golib/util/dateutil"
 	"gorm.io/gorm"
 )
 
 const (
-	gormContextStart    = "whatapGormContext"
 	gormSQLContextStart = "whatapSQLGormContext"
 )
 
 type callbackFunc func(*gorm.DB)
 
+type plugin struct{}
+
+// NewPlugin returns a gorm.Plugin which traces the sql of Create, Query, Update, Delete, Row, Raw as sql steps.
+// The transaction is found from the context of db.WithContext(ctx).
+//
+//	db, err := gorm.Open(dialector, cfg)
+//	db.Use(whatapgorm.NewPlugin())
+//	db.WithContext(ctx).Find(&products)
+func NewPlugin() gorm.Plugin {
+	return &plugin{}
+}
+
+func (p *plugin) Name() string {
+	return "whatap"
+}
+
+func (p *plugin) Initialize(db *gorm.DB) error {
+	return registerCallback(db, before, after)
+}
+
+// before 에서는 시작 시간만 기록. Statement.SQL 은 gorm 의 callback 에서 생성됨
 func before(db *gorm.DB) {
-	if db == nil {
+	if db == nil || db.Statement == nil || db.DryRun {
 		return
 	}
-	ctx := GetContext(db)
-	if db.Statement != nil {
-		sqlCtx, _ := sql.StartWithParamArray(ctx, db.Name(), db.Statement.SQL.String(), db.Statement.Vars)
-		db.Set(gormSQLContextStart, sqlCtx)
-	}
+	db.InstanceSet(gormSQLContextStart, dateutil.SystemNow())
 }
 
+// after 에서 최종 Statement.SQL, Vars 와 RowsAffected 를 기록
 func after(db *gorm.DB) {
-	if db == nil {
+	if db == nil || db.Statement == nil || db.DryRun {
+		return
+	}
+	v, ok := db.InstanceGet(gormSQLContextStart)
+	if !ok {
 		return
 	}
-	v, ok := db.Get(gormSQLContextStart)
-	if ok {
-		sqlCtx := v.(*sql.SqlCtx)
-		sql.End(sqlCtx, nil)
+	startTime, ok := v.(int64)
+	if !ok {
+		return
 	}
+	// Row, Rows 는 RowsAffected 가 -1 로 fetch 건수를 알 수 없음
+	sql.TraceWithRows(GetContext(db), startTime, db.Name(), db.Statement.SQL.String(), db.Statement.Vars, db.RowsAffected, db.Error)
 }
 
-func withCallback(db *gorm.DB, beforeFunc, afterFunc callbackFunc) *gorm.DB {
+func registerCallback(db *gorm.DB, beforeFunc, afterFunc callbackFunc) error {
 	cb := db.Callback()
-	cb.Create().Before("beforeCreate").Register("whatapBeforeCreate", beforeFunc)
-	cb.Update().Before("beforeUpdate").Register("whatapBeforeUpdate", beforeFunc)
-	cb.Delete().Before("beforeDelete").Register("whatapBeforeDelete", beforeFunc)
-	cb.Query().Before("beforeQuery").Register("whatapBeforeQuery", beforeFunc)
-	cb.Row().Before("beforeRow").Register("whatapBeforeRow", beforeFunc)
-
-	cb.Create().After("afterCreate").Register("whatapAfterCreate", afterFunc)
-	cb.Update().After("afterUpdate").Register("whatapAfterUpdate", afterFunc)
-	cb.Delete().After("afterDelete").Register("whatapAfterDelete", afterFunc)
-	cb.Query().After("afterQuery").Register("whatapAfterQuery", afterFunc)
-	cb.Row().After("afterRow").Register("whatapAfterRow", afterFunc)
-
-	return db
+	if err := cb.Create().Before("gorm:create").Register("whatap:before_create", beforeFunc); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("whatap:before_query", beforeFunc); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("whatap:before_update", beforeFunc); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("whatap:before_delete", beforeFunc); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("whatap:before_row", beforeFunc); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("whatap:before_raw", beforeFunc); err != nil {
+		return err
+	}
+
+	if err := cb.Create().After("gorm:create").Register("whatap:after_create", afterFunc); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("whatap:after_query", afterFunc); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("whatap:after_update", afterFunc); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("whatap:after_delete", afterFunc); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("whatap:after_row", afterFunc); err != nil {
+		return err
+	}
+	if err := cb.Raw().After("gorm:raw").Register("whatap:after_raw", afterFunc); err != nil {
+		return err
+	}
+	return nil
 }
 
 func Open(dialector gorm.Dialector, cfg *gorm.Config) (*gorm.DB, error) {
@@ -58,29 +106,30 @@ func Open(dialector gorm.Dialector, cfg *gorm.Config) (*gorm.DB, error) {
 	if err != nil {
 		return db, err
 	}
-	return withCallback(db, before, after), nil
+	if err = db.Use(NewPlugin()); err != nil {
+		return db, err
+	}
+	return db, nil
 }
 
+// OpenWithContext opens the db with the plugin and returns the session of db.WithContext(ctx).
 func OpenWithContext(dialector gorm.Dialector, cfg *gorm.Config, ctx context.Context) (*gorm.DB, error) {
-	db, err := gorm.Open(dialector, cfg)
+	db, err := Open(dialector, cfg)
 	if err != nil {
 		return db, err
 	}
-
-	return withCallback(db.Set(gormContextStart, ctx), before, after), nil
+	return db.WithContext(ctx), nil
 }
 
+// GetContext returns the context of the statement. (db.WithContext)
 func GetContext(db *gorm.DB) context.Context {
-	v, ok := db.Get(gormContextStart)
-	var ctx context.Context
-	if ok {
-		ctx, _ = v.(context.Context)
-	} else {
-		ctx = nil
-	}
-	return ctx
+	if db.Statement != nil {
+		return db.Statement.Context
+	}
+	return nil
 }
 
+// Deprecated: use db.WithContext(ctx)
 func WithContext(ctx context.Context, db *gorm.DB) *gorm.DB {
-	return db.Set(gormContextStart, ctx)
+	return db.WithContext(ctx)
 }
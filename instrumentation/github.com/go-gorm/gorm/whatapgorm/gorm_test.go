@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/lang/step"
 	_ "gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -42,7 +44,10 @@ func TestWithCallback(t *testing.T) {
 		return
 	}
 
-	db = withCallback(db, beforeTest, afterFunc())
+	err = registerCallback(db, beforeTest, afterFunc())
+	if assert.Nil(err) != true {
+		return
+	}
 
 	err = db.AutoMigrate(&Product{})
 	if assert.Nil(err) != true {
@@ -129,12 +134,76 @@ func TestWithContext(t *testing.T) {
 		return
 	}
 
-	dbWithContext := WithContext(context.Background(), db)
+	type testKey struct{}
+	dbWithContext := db.WithContext(context.WithValue(context.Background(), testKey{}, "TEST"))
 
 	ctx := GetContext(dbWithContext)
-	assert.NotNil(ctx)
+	if assert.NotNil(ctx) {
+		assert.Equal("TEST", ctx.Value(testKey{}))
+	}
 
+	// gorm 의 기본 context
 	notCtx := GetContext(db)
-	assert.Nil(notCtx)
+	assert.Nil(notCtx.Value(testKey{}))
+
+}
+
+func TestAfterError(t *testing.T) {
+	whatapConfig := make(map[string]string)
+	trace.Init(whatapConfig)
+	defer trace.Shutdown()
+
+	assert := assert.New(t)
+	ctx, err := trace.Start(context.Background(), "TEST")
+	if assert.Nil(err) != true {
+		return
+	}
+	db, err := Open(sqlite.Open("test.db"), &gorm.Config{})
+	if assert.Nil(err) != true {
+		return
+	}
+	err = db.AutoMigrate(&Product{})
+	if assert.Nil(err) != true {
+		return
+	}
+
+	tx := db.WithContext(ctx).Create(&Product{Code: 1, Price: 2})
+	assert.Nil(tx.Error)
+
+	var products []Product
+	tx = db.WithContext(ctx).Find(&products)
+	assert.Nil(tx.Error)
+
+	var product Product
+	// gorm.ErrRecordNotFound 는 오류가 아님
+	tx = db.WithContext(ctx).First(&product, "code = ?", 100)
+	assert.Equal(gorm.ErrRecordNotFound, tx.Error)
+
+	tx = db.WithContext(ctx).Find(&product, "not_exists = ?", 1)
+	assert.NotNil(tx.Error)
+
+	tx = db.WithContext(ctx).Unscoped().Delete(&Product{}, "1 = 1")
+	assert.Nil(tx.Error)
+
+	_, traceCtx := trace.GetTraceContext(ctx)
+	count, errorCount := 0, 0
+	fetch := int32(0)
+	for _, it := range traceCtx.Ctx.Profile.GetSteps() {
+		switch st := it.(type) {
+		case *step.SqlStepX:
+			count++
+			if st.Error != 0 {
+				errorCount++
+			}
+		case *step.ResultSetStep:
+			fetch += st.Fetch
+		}
+	}
+	// record not found 를 제외한 no such column 만 오류
+	assert.Equal(5, count)
+	assert.Equal(1, errorCount)
+	assert.Equal(int32(len(products)), fetch)
+	assert.True(traceCtx.Ctx.JdbcUpdateRecord >= 2)
 
+	trace.End(ctx, nil)
 }
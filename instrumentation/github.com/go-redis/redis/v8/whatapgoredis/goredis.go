@@ -0,0 +1,146 @@
+package whatapgoredis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/whatap/go-api/sql"
+	"github.com/whatap/go-api/trace"
+)
+
+const (
+	// max count of command names in the pipeline step
+	PIPELINE_COMMAND_MAX_COUNT = 10
+)
+
+type sqlCtxKey struct{}
+
+type hookCtx struct {
+	sqlCtx *sql.SqlCtx
+	// 트랜잭션 밖에서 실행된 command 는 connection 이름으로 트랜잭션 생성
+	traceCtx context.Context
+}
+
+type hook struct {
+	connection string
+}
+
+// NewHook returns a redis.Hook which traces the commands and pipelines as sql steps of the connection.
+// The connection is recorded as the db host, e.g. redis://localhost:6379/0
+func NewHook(connection string) redis.Hook {
+	return &hook{connection: connection}
+}
+
+// Instrument adds the whatap hook to the client. The address and the DB index of the client are recorded.
+func Instrument(rdb redis.UniversalClient) {
+	rdb.AddHook(NewHook(getConnection(rdb)))
+}
+
+func getConnection(rdb redis.UniversalClient) string {
+	switch c := rdb.(type) {
+	case *redis.Client:
+		opt := c.Options()
+		return fmt.Sprintf("redis://%s/%d", opt.Addr, opt.DB)
+	case *redis.ClusterClient:
+		return fmt.Sprintf("redis://%s", strings.Join(c.Options().Addrs, ","))
+	}
+	return "redis"
+}
+
+func (h *hook) start(ctx context.Context, cmd string, args ...interface{}) context.Context {
+	p := &hookCtx{}
+	if _, traceCtx := trace.GetTraceContext(ctx); traceCtx == nil {
+		p.traceCtx, _ = trace.Start(ctx, h.connection)
+		ctx = p.traceCtx
+	}
+	// Redis는SQL은 아니지만 같은 DB 계열임.  통계 처리를 위해 SQL로 처리
+	p.sqlCtx, _ = sql.StartWithParam(ctx, h.connection, cmd, args...)
+	return context.WithValue(ctx, sqlCtxKey{}, p)
+}
+
+func (h *hook) end(ctx context.Context, err error) {
+	p, ok := ctx.Value(sqlCtxKey{}).(*hookCtx)
+	if !ok {
+		return
+	}
+	sql.End(p.sqlCtx, err)
+	if p.traceCtx != nil {
+		trace.End(p.traceCtx, err)
+	}
+}
+
+func (h *hook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.start(ctx, getCommandString(cmd), cmdParams(cmd)...), nil
+}
+
+func (h *hook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.end(ctx, cmdError(cmd))
+	return nil
+}
+
+func (h *hook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return h.start(ctx, getPipelineString(cmds)), nil
+}
+
+func (h *hook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if err = cmdError(cmd); err != nil {
+			break
+		}
+	}
+	h.end(ctx, err)
+	return nil
+}
+
+// cmdError returns the error of the command. redis.Nil (key does not exist) is not an error.
+func cmdError(cmd redis.Cmder) error {
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		return err
+	}
+	return nil
+}
+
+func cmdParams(cmd redis.Cmder) []interface{} {
+	args := cmd.Args()
+	if len(args) > 1 {
+		return args[1:]
+	}
+	return nil
+}
+
+func getCommandString(cmd redis.Cmder) string {
+	prepared := make([]string, 0)
+	for range cmdParams(cmd) {
+		prepared = append(prepared, "?")
+	}
+
+	name := strings.ToUpper(cmd.Name())
+	if len(prepared) > 0 {
+		return fmt.Sprintf("%s (%s)", name, strings.Join(prepared, ", "))
+	} else {
+		return name
+	}
+}
+
+// getPipelineString returns PIPELINE or TX_PIPELINE with the command count and the command names.
+// MULTI, EXEC of the TxPipeline are not counted.
+func getPipelineString(cmds []redis.Cmder) string {
+	kind := "PIPELINE"
+	if len(cmds) >= 2 && cmds[0].Name() == "multi" && cmds[len(cmds)-1].Name() == "exec" {
+		kind = "TX_PIPELINE"
+		cmds = cmds[1 : len(cmds)-1]
+	}
+
+	names := make([]string, 0)
+	for i, cmd := range cmds {
+		if i >= PIPELINE_COMMAND_MAX_COUNT {
+			names = append(names, "...")
+			break
+		}
+		names = append(names, strings.ToUpper(cmd.Name()))
+	}
+	return fmt.Sprintf("%s [%d] %s", kind, len(cmds), strings.Join(names, ", "))
+}
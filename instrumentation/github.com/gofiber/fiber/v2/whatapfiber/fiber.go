@@ -64,11 +64,19 @@ func Middleware() func(c *fiber.Ctx) error {
 			status := fiberCtx.Response().StatusCode()
 			if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
 				traceCtx.Status = int32(status)
+				traceCtx.RequestBytes = int64(fiberCtx.Request().Header.ContentLength())
+				traceCtx.ResponseBytes = int64(len(fiberCtx.Response().Body()))
 			}
 
 			if status >= 400 {
 				err = fmt.Errorf("Status: %d,%s", status, http.StatusText(status))
 			}
+			// 라우팅된 경로 템플릿으로 서비스 이름 지정. ex) /users/:id
+			// fiber 는 handler 실행 후에만 라우팅된 경로를 알 수 있으므로 sampling 은 변경된 이름으로 다시 판단
+			// 라우팅되지 않은 요청은 Use 로 등록된 middleware 의 경로
+			if status != http.StatusNotFound {
+				trace.UpdateServiceName(ctx, fiberCtx.Route().Path)
+			}
 
 			traceParams(fiberCtx, ctx)
 
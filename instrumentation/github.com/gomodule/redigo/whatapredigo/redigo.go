@@ -111,7 +111,7 @@ func DoRun(ctx context.Context, conn redis.Conn, connection, commandName string,
 
 	sqlCtx, _ := sql.StartWithParam(ctx, connection, cmd, args...)
 	ret, err := conn.Do(commandName, args...)
-	sql.End(sqlCtx, nil)
+	sql.End(sqlCtx, err)
 	return ret, err
 }
 
@@ -124,6 +124,6 @@ func SendRun(ctx context.Context, conn redis.Conn, connection, commandName strin
 
 	sqlCtx, _ := sql.StartWithParam(ctx, connection, cmd, args...)
 	err := conn.Send(commandName, args...)
-	sql.End(sqlCtx, nil)
+	sql.End(sqlCtx, err)
 	return err
 }
@@ -11,6 +11,12 @@ import (
 func Middleware() mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return trace.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 라우팅된 경로 템플릿으로 서비스 이름 지정. ex) /users/{id}
+			if route := mux.CurrentRoute(r); route != nil {
+				if tpl, err := route.GetPathTemplate(); err == nil {
+					trace.UpdateServiceName(r.Context(), tpl)
+				}
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -31,7 +31,12 @@ func after(scope *gorm.Scope) {
 	v, ok := scope.Get(gormSQLContextStart)
 	if ok {
 		sqlCtx := v.(*sql.SqlCtx)
-		sql.End(sqlCtx, nil)
+		rows := scope.DB().RowsAffected
+		// RowQuery 는 fetch 건수를 알 수 없음
+		if _, isRowQuery := scope.InstanceGet("row_query_result"); isRowQuery {
+			rows = -1
+		}
+		sql.EndWithRows(sqlCtx, rows, scope.DB().Error)
 	}
 }
 
@@ -21,6 +21,9 @@ func Middleware() echo.MiddlewareFunc {
 			r := c.Request()
 			ctx, _ := trace.StartWithRequest(r)
 			c.SetRequest(r.WithContext(ctx))
+			// 라우팅된 경로 템플릿으로 서비스 이름 지정. ex) /users/:id
+			// sampling, ignore 를 판단하도록 handler 실행 전에 변경. 라우팅되지 않은 요청은 c.Path() 가 ""
+			trace.UpdateServiceName(ctx, c.Path())
 			var err error = nil
 			defer func() {
 				x := recover()
@@ -61,6 +64,10 @@ func Middleware() echo.MiddlewareFunc {
 						err = fmt.Errorf("Status: %d,%s", status, http.StatusText(status))
 					}
 				}
+				if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
+					traceCtx.RequestBytes = c.Request().ContentLength
+					traceCtx.ResponseBytes = c.Response().Size
+				}
 				// trace http parameter
 				if conf.ProfileHttpParameterEnabled && strings.HasPrefix(c.Request().RequestURI, conf.ProfileHttpParameterUrlPrefix) {
 					if c.Request().Form != nil {
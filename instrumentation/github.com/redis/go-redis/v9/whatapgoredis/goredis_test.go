@@ -0,0 +1,86 @@
+package whatapgoredis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/instrumentation/internal/redistest"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, *redis.Client, redistest.Client) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr(), DB: 0})
+	Instrument(rdb)
+	c := redistest.Client{
+		Set: func(ctx context.Context, key, value string) error {
+			return rdb.Set(ctx, key, value, 0).Err()
+		},
+		Get: func(ctx context.Context, key string) (string, error) {
+			return rdb.Get(ctx, key).Result()
+		},
+		Do: func(ctx context.Context, args ...interface{}) error {
+			return rdb.Do(ctx, args...).Err()
+		},
+		Pipelined: func(ctx context.Context) error {
+			_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, "KEY", "VALUE", 0)
+				pipe.Incr(ctx, "COUNT")
+				pipe.Get(ctx, "NOT_EXISTS")
+				return nil
+			})
+			return err
+		},
+		TxPipelined: func(ctx context.Context) error {
+			_, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Incr(ctx, "COUNT")
+				pipe.Get(ctx, "KEY")
+				return nil
+			})
+			return err
+		},
+		Nil: redis.Nil,
+	}
+	return s, rdb, c
+}
+
+func TestHook(t *testing.T) {
+	s, rdb, c := newClient(t)
+	defer s.Close()
+	defer rdb.Close()
+	redistest.RunHook(t, c)
+}
+
+func TestPipeline(t *testing.T) {
+	s, rdb, c := newClient(t)
+	defer s.Close()
+	defer rdb.Close()
+	redistest.RunPipeline(t, s, c)
+}
+
+func TestPipelineString(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	assert.Equal("PIPELINE [3] SET, INCR, GET", getPipelineString([]redis.Cmder{
+		redis.NewStatusCmd(ctx, "set", "KEY", "VALUE"), redis.NewIntCmd(ctx, "incr", "COUNT"), redis.NewStringCmd(ctx, "get", "KEY")}))
+	assert.Equal("TX_PIPELINE [1] INCR", getPipelineString([]redis.Cmder{
+		redis.NewStatusCmd(ctx, "multi"), redis.NewIntCmd(ctx, "incr", "COUNT"), redis.NewSliceCmd(ctx, "exec")}))
+}
+
+func TestWithoutTransaction(t *testing.T) {
+	s, rdb, c := newClient(t)
+	defer s.Close()
+	defer rdb.Close()
+	redistest.RunWithoutTransaction(t, c)
+}
+
+func TestConnection(t *testing.T) {
+	assert := assert.New(t)
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 2})
+	assert.Equal("redis://localhost:6379/2", getConnection(rdb))
+}
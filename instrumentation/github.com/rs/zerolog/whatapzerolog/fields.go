@@ -0,0 +1,23 @@
+package whatapzerolog
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/whatap/go-api/trace"
+)
+
+// FieldsHook is the zerolog.Hook which adds the fields linking the log to the transaction of the context
+// given by Event.Ctx. (@txid, @mtid, trace_id) The log written to the file watched by logsink is linked to the trace.
+//
+//	logger := zerolog.New(file).Hook(whatapzerolog.FieldsHook{})
+//	logger.Info().Ctx(ctx).Msg("hello")
+type FieldsHook struct {
+}
+
+func (h FieldsHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if !e.Enabled() {
+		return
+	}
+	for k, v := range trace.LogFields(e.GetCtx()) {
+		e.Str(k, v)
+	}
+}
@@ -0,0 +1,34 @@
+// github.com/whatap/go-api/instrumentation/github.com/rs/zerolog/whatapzerolog
+package whatapzerolog
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/whatap/go-api/logsink"
+)
+
+// Hook is the zerolog.Hook which sends the message of the event to whatap.
+// The txid of the transaction of the context given by Event.Ctx is added to the @txid tag.
+// The fields of the event are not sent because zerolog does not expose them to the hook.
+//
+//	logger := zerolog.New(os.Stdout).Hook(whatapzerolog.NewHook("app"))
+//	logger.Info().Ctx(ctx).Msg("hello")
+type Hook struct {
+	category string
+}
+
+// NewHook returns the Hook of the category.
+func NewHook(category string) Hook {
+	return Hook{category: category}
+}
+
+func (h Hook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if !e.Enabled() {
+		return
+	}
+	send(e.GetCtx(), h.category, time.Now(), level.String(), msg, nil)
+}
+
+// Hook 이 전송하는 함수. 테스트에서 변경
+var send = logsink.Send
@@ -0,0 +1,72 @@
+package whatapzerolog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/trace"
+)
+
+type sentLog struct {
+	ctx      context.Context
+	category string
+	time     time.Time
+	level    string
+	msg      string
+	fields   map[string]string
+}
+
+func capture(t *testing.T) *[]sentLog {
+	sent := make([]sentLog, 0)
+	old := send
+	send = func(ctx context.Context, category string, tm time.Time, level string, msg string, fields map[string]string) {
+		sent = append(sent, sentLog{ctx, category, tm, level, msg, fields})
+	}
+	t.Cleanup(func() { send = old })
+	return &sent
+}
+
+func TestHook(t *testing.T) {
+	sent := capture(t)
+	ctx, _ := trace.Start(context.Background(), "/whatapzerolog")
+	defer trace.End(ctx, nil)
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Level(zerolog.InfoLevel).Hook(NewHook("app"))
+	start := time.Now()
+	logger.Warn().Ctx(ctx).Str("a", "1").Msg("hello")
+	// 레벨이 낮은 로그
+	logger.Debug().Ctx(ctx).Msg("debug")
+
+	if assert.Equal(t, 1, len(*sent)) {
+		it := (*sent)[0]
+		assert.Equal(t, "app", it.category)
+		assert.Equal(t, "warn", it.level)
+		assert.Equal(t, "hello", it.msg)
+		assert.False(t, it.time.Before(start))
+		// zerolog 는 hook 에 필드를 전달하지 않음
+		assert.Nil(t, it.fields)
+		assert.NotEqual(t, "", trace.GetTxidString(it.ctx))
+		assert.Equal(t, trace.GetTxidString(ctx), trace.GetTxidString(it.ctx))
+	}
+	// 원래 출력은 그대로
+	assert.Contains(t, buf.String(), `"a":"1"`)
+}
+
+func TestHookWithoutContext(t *testing.T) {
+	sent := capture(t)
+	logger := zerolog.New(nil).Hook(NewHook(""))
+	logger.Error().Msg("no context")
+	logger.Log().Msg("no level")
+
+	if assert.Equal(t, 2, len(*sent)) {
+		assert.Equal(t, "", (*sent)[0].category)
+		assert.Equal(t, "error", (*sent)[0].level)
+		assert.Equal(t, context.Background(), (*sent)[0].ctx)
+		assert.Equal(t, "", (*sent)[1].level)
+	}
+}
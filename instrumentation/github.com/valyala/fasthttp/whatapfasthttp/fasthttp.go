@@ -111,14 +111,14 @@ func StartWithFastHttpRequest(r *fasthttp.RequestCtx) (context.Context, error) {
 		return r, nil
 	}
 
+	// *fasthttp.RequestCtx 는 string key 만 조회되므로 NewTraceContext 에서 UserValue 로 저장
 	_, traceCtx := trace.NewTraceContext(r)
-	r.SetUserValue("whatap", traceCtx)
 
 	traceCtx.Name = string(r.RequestURI())
 	traceCtx.Host = string(r.Host())
 	traceCtx.StartTime = dateutil.SystemNow()
 	// update multi trace info
-	UpdateFastHttpMtrace(traceCtx, r.Request.Header)
+	updateFastHttpMtrace(traceCtx, &r.Request.Header)
 
 	wCtx := traceCtx.Ctx
 	wCtx.StartTime = traceCtx.StartTime
@@ -145,6 +145,11 @@ func SetFastHttpHeader(ctx context.Context, header *fasthttp.RequestHeader) {
 }
 
 func UpdateFastHttpMtrace(traceCtx *trace.TraceCtx, header fasthttp.RequestHeader) {
+	updateFastHttpMtrace(traceCtx, &header)
+}
+
+// updateFastHttpMtrace reads the header without copying it. fasthttp.RequestHeader must not be copied.
+func updateFastHttpMtrace(traceCtx *trace.TraceCtx, header *fasthttp.RequestHeader) {
 	conf := config.GetConfig()
 	if !conf.MtraceEnabled {
 		return
@@ -162,7 +167,7 @@ func UpdateFastHttpMtrace(traceCtx *trace.TraceCtx, header fasthttp.RequestHeade
 }
 
 func GetClientId(ctx *fasthttp.RequestCtx, remoteIP string) string {
-	r := ctx.Request
+	r := &ctx.Request
 	clientID := remoteIP
 	conf := config.GetConfig()
 	if !conf.Enabled || !conf.TraceUserEnabled {
@@ -171,7 +176,7 @@ func GetClientId(ctx *fasthttp.RequestCtx, remoteIP string) string {
 	if conf.TraceUserUsingIp {
 		return clientID
 	}
-	header := r.Header
+	header := &r.Header
 	if conf.TraceUserHeaderTicketEnabled {
 		header.VisitAll(func(keyRaw, valueRaw []byte) {
 			if len(valueRaw) <= 0 {
@@ -0,0 +1,102 @@
+// github.com/whatap/go-api/instrumentation/go.uber.org/zap/whatapzap
+package whatapzap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/whatap/go-api/logsink"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const contextKey = "whatap.context"
+
+// Core is the zapcore.Core which sends the entries to whatap.
+// The txid of the transaction of the context given by Context field is added to the @txid tag.
+//
+//	logger := zap.NewExample(whatapzap.WrapCore("app"))
+//	logger.Info("hello", whatapzap.Context(ctx), zap.String("user", id))
+type Core struct {
+	zapcore.LevelEnabler
+	category string
+	ctx      context.Context
+	fields   map[string]string
+}
+
+// NewCore returns the Core of the category which sends the entries enabled by enab.
+func NewCore(enab zapcore.LevelEnabler, category string) *Core {
+	c := new(Core)
+	c.LevelEnabler = enab
+	c.category = category
+	c.ctx = context.Background()
+	c.fields = make(map[string]string)
+	return c
+}
+
+// WrapCore returns the zap.Option which sends the entries of the logger to whatap in addition to the core of the logger.
+func WrapCore(category string) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, NewCore(core, category))
+	})
+}
+
+// Context returns the field which passes ctx to the Core. The encoders of zap skip the field.
+func Context(ctx context.Context) zap.Field {
+	return zap.Field{Key: contextKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+func (c *Core) With(fields []zap.Field) zapcore.Core {
+	c2 := NewCore(c.LevelEnabler, c.category)
+	c2.ctx = c.ctx
+	for k, v := range c.fields {
+		c2.fields[k] = v
+	}
+	c2.ctx = addFields(c2.fields, c2.ctx, fields)
+	return c2
+}
+
+func (c *Core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *Core) Write(ent zapcore.Entry, fields []zap.Field) error {
+	tags := make(map[string]string, len(c.fields)+len(fields)+1)
+	for k, v := range c.fields {
+		tags[k] = v
+	}
+	ctx := addFields(tags, c.ctx, fields)
+	if ent.LoggerName != "" {
+		tags["logger"] = ent.LoggerName
+	}
+	send(ctx, c.category, ent.Time, ent.Level.String(), ent.Message, tags)
+	return nil
+}
+
+// Core 가 전송하는 함수. 테스트에서 변경
+var send = logsink.Send
+
+func (c *Core) Sync() error {
+	return nil
+}
+
+// addFields adds the fields to the tags and returns the context of the Context field.
+func addFields(tags map[string]string, ctx context.Context, fields []zap.Field) context.Context {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		if f.Key == contextKey && f.Type == zapcore.SkipType {
+			if v, ok := f.Interface.(context.Context); ok && v != nil {
+				ctx = v
+			}
+			continue
+		}
+		f.AddTo(enc)
+	}
+	for k, v := range enc.Fields {
+		tags[k] = fmt.Sprint(v)
+	}
+	return ctx
+}
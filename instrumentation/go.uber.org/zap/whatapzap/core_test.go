@@ -0,0 +1,94 @@
+package whatapzap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type sentLog struct {
+	ctx      context.Context
+	category string
+	time     time.Time
+	level    string
+	msg      string
+	fields   map[string]string
+}
+
+func capture(t *testing.T) *[]sentLog {
+	sent := make([]sentLog, 0)
+	old := send
+	send = func(ctx context.Context, category string, tm time.Time, level string, msg string, fields map[string]string) {
+		sent = append(sent, sentLog{ctx, category, tm, level, msg, fields})
+	}
+	t.Cleanup(func() { send = old })
+	return &sent
+}
+
+func TestCore(t *testing.T) {
+	sent := capture(t)
+	ctx, _ := trace.Start(context.Background(), "/whatapzap")
+	defer trace.End(ctx, nil)
+
+	start := time.Now()
+	logger := zap.New(NewCore(zapcore.InfoLevel, "app")).Named("order").With(zap.String("a", "1"))
+	logger.Warn("hello", Context(ctx), zap.Int("n", 2), zap.Bool("ok", true))
+	// 레벨이 낮은 로그
+	logger.Debug("debug", Context(ctx))
+
+	if assert.Equal(t, 1, len(*sent)) {
+		it := (*sent)[0]
+		assert.Equal(t, "app", it.category)
+		assert.Equal(t, "warn", it.level)
+		assert.Equal(t, "hello", it.msg)
+		assert.False(t, it.time.Before(start))
+		assert.Equal(t, map[string]string{"a": "1", "n": "2", "ok": "true", "logger": "order"}, it.fields)
+		// Context 필드는 태그에서 제외하고 @txid 로 추가
+		assert.NotEqual(t, "", trace.GetTxidString(it.ctx))
+		assert.Equal(t, trace.GetTxidString(ctx), trace.GetTxidString(it.ctx))
+	}
+}
+
+func TestCoreWithContext(t *testing.T) {
+	sent := capture(t)
+	ctx, _ := trace.Start(context.Background(), "/whatapzap")
+	defer trace.End(ctx, nil)
+
+	logger := zap.New(NewCore(zapcore.DebugLevel, "app"))
+	child := logger.With(Context(ctx), zap.String("a", "1"))
+	child.Info("with context")
+	child.With(zap.String("b", "2")).Error("child")
+	logger.Info("without context")
+
+	if assert.Equal(t, 3, len(*sent)) {
+		assert.Equal(t, trace.GetTxidString(ctx), trace.GetTxidString((*sent)[0].ctx))
+		assert.Equal(t, map[string]string{"a": "1"}, (*sent)[0].fields)
+		assert.Equal(t, trace.GetTxidString(ctx), trace.GetTxidString((*sent)[1].ctx))
+		assert.Equal(t, map[string]string{"a": "1", "b": "2"}, (*sent)[1].fields)
+		assert.Equal(t, "error", (*sent)[1].level)
+		// With 는 원래 logger 의 필드를 변경하지 않음
+		assert.Equal(t, context.Background(), (*sent)[2].ctx)
+		assert.Equal(t, map[string]string{}, (*sent)[2].fields)
+	}
+}
+
+func TestWrapCore(t *testing.T) {
+	sent := capture(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core, WrapCore("app"))
+	logger.Info("hello", zap.String("a", "1"))
+	logger.Debug("debug")
+
+	// 원래 core 와 같은 레벨의 로그를 함께 전송
+	assert.Equal(t, 1, logs.Len())
+	if assert.Equal(t, 1, len(*sent)) {
+		assert.Equal(t, "info", (*sent)[0].level)
+		assert.Equal(t, map[string]string{"a": "1"}, (*sent)[0].fields)
+	}
+}
@@ -0,0 +1,21 @@
+package whatapzap
+
+import (
+	"context"
+
+	"github.com/whatap/go-api/trace"
+	"go.uber.org/zap"
+)
+
+// Fields returns the fields which link the log to the transaction of ctx. (@txid, @mtid, trace_id)
+// It is used for the cores other than Core which write the log to the file watched by logsink.
+//
+//	logger.Info("hello", whatapzap.Fields(ctx)...)
+func Fields(ctx context.Context) []zap.Field {
+	m := trace.LogFields(ctx)
+	rt := make([]zap.Field, 0, len(m))
+	for k, v := range m {
+		rt = append(rt, zap.String(k, v))
+	}
+	return rt
+}
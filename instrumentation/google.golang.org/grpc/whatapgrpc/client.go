@@ -18,7 +18,7 @@ import (
 func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		conf := config.GetConfig()
-		if !conf.GoGrpcProfileEnabled {
+		if !conf.GoGrpcProfileEnabled || isIgnoreMethod(method, conf) {
 			return invoker(ctx, method, req, reply, cc, opts...)
 		}
 
@@ -39,7 +39,11 @@ func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
 
 		err := invoker(ctx, method, req, reply, cc, opts...)
 
-		httpc.End(httpcCtx, 0, "", err)
+		code, status, traceErr := traceError(err, conf)
+		if reqSize, respSize := messageSize(req), messageSize(reply); reqSize > 0 || respSize > 0 {
+			httpcCtx.SetParam(fmt.Sprintf("request %d bytes, response %d bytes", reqSize, respSize))
+		}
+		httpc.End(httpcCtx, status, code.String(), traceErr)
 		return err
 	}
 }
@@ -63,19 +67,19 @@ func (w *wrapClientStream) CloseSend() error {
 }
 
 func (w *wrapClientStream) RecvMsg(m interface{}) (err error) {
-	return w.TraceStream("/RecvMsg", func() error {
+	return w.TraceStream("/RecvMsg", m, func() error {
 		return w.ClientStream.RecvMsg(m)
 	})
 }
 
 func (w *wrapClientStream) SendMsg(m interface{}) (err error) {
-	return w.TraceStream("/SendMsg", func() error {
+	return w.TraceStream("/SendMsg", m, func() error {
 		return w.ClientStream.SendMsg(m)
 	})
 }
 
-func (w *wrapClientStream) TraceStream(div string, callFunc func() error) (err error) {
-	if !w.conf.GoGrpcProfileStreamClientEnabled {
+func (w *wrapClientStream) TraceStream(div string, m interface{}, callFunc func() error) (err error) {
+	if !w.conf.GoGrpcProfileStreamClientEnabled || !isStreamSampled(w.conf) {
 		return callFunc()
 	}
 	if w.conf.GoGrpcProfileStreamIdentify {
@@ -85,13 +89,14 @@ func (w *wrapClientStream) TraceStream(div string, callFunc func() error) (err e
 	if config.InArray(w.Method, w.conf.GoGrpcProfileStreamMethod) {
 		traceCtx, _ := trace.Start(w.ClientStream.Context(), path.Join(div, w.Target, w.Method))
 		err = callFunc()
-		trace.End(traceCtx, err)
+		trace.End(traceCtx, streamError(err, w.conf))
 	} else {
 		if _, traceCtx := trace.GetTraceContext(w.ctx); traceCtx != nil {
 			st := dateutil.SystemNow()
 			err = callFunc()
-			trace.Step(w.ctx, path.Join(div, w.Target, w.Method), "", int(dateutil.SystemNow()-st), 0)
-			if err != nil {
+			size := messageSize(m)
+			trace.Step(w.ctx, path.Join(div, w.Target, w.Method), sizeMessage("", size), int(dateutil.SystemNow()-st), size)
+			if streamError(err, w.conf) != nil {
 				trace.Step(w.ctx, path.Join(div, w.Target, w.Method), fmt.Sprintf("Error %s", err.Error()), 0, 0)
 			}
 		} else {
@@ -109,6 +114,9 @@ func StreamClientInterceptor() grpc.StreamClientInterceptor {
 		streamer grpc.Streamer, opts ...grpc.CallOption) (s grpc.ClientStream, err error) {
 
 		conf := config.GetConfig()
+		if !conf.GoGrpcProfileEnabled || isIgnoreMethod(method, conf) {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
 
 		div := "/Start"
 		if conf.GoGrpcProfileStreamIdentify {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"path"
+	"strings"
 
 	"github.com/whatap/go-api/agent/agent/config"
 	"github.com/whatap/go-api/trace"
@@ -17,7 +18,7 @@ import (
 func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ interface{}, err error) {
 		conf := config.GetConfig()
-		if !conf.GoGrpcProfileEnabled {
+		if !conf.GoGrpcProfileEnabled || isIgnoreMethod(info.FullMethod, conf) {
 			// handler
 			return handler(ctx, req)
 		}
@@ -26,7 +27,7 @@ func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 		// handler
 		resp, err := handler(ctx, req)
 
-		trace.End(ctx, err)
+		endServer(ctx, err, messageSize(req), messageSize(resp), conf)
 
 		return resp, err
 	}
@@ -54,19 +55,19 @@ func (w *wrapServerStream) Context() context.Context {
 }
 
 func (w *wrapServerStream) RecvMsg(m interface{}) (err error) {
-	return w.TraceStream("/RecvMsg", func() error {
+	return w.TraceStream("/RecvMsg", m, func() error {
 		return w.ServerStream.RecvMsg(m)
 	})
 }
 
 func (w *wrapServerStream) SendMsg(m interface{}) (err error) {
-	return w.TraceStream("/SendMsg", func() error {
+	return w.TraceStream("/SendMsg", m, func() error {
 		return w.ServerStream.SendMsg(m)
 	})
 }
 
-func (w *wrapServerStream) TraceStream(div string, callFunc func() error) (err error) {
-	if !w.conf.GoGrpcProfileStreamServerEnabled {
+func (w *wrapServerStream) TraceStream(div string, m interface{}, callFunc func() error) (err error) {
+	if !w.conf.GoGrpcProfileStreamServerEnabled || !isStreamSampled(w.conf) {
 		return callFunc()
 	}
 	if w.conf.GoGrpcProfileStreamIdentify {
@@ -76,13 +77,14 @@ func (w *wrapServerStream) TraceStream(div string, callFunc func() error) (err e
 	if config.InArray(w.Method, w.conf.GoGrpcProfileStreamMethod) {
 		wCtx, _ := trace.Start(w.ServerStream.Context(), path.Join(div, w.Method))
 		err = callFunc()
-		trace.End(wCtx, err)
+		trace.End(wCtx, streamError(err, w.conf))
 	} else {
 		if _, traceCtx := trace.GetTraceContext(w.ctx); traceCtx != nil {
 			st := dateutil.SystemNow()
 			err = callFunc()
-			trace.Step(w.ctx, path.Join(div, w.Method), div, int(dateutil.SystemNow()-st), 0)
-			if err != nil {
+			size := messageSize(m)
+			trace.Step(w.ctx, path.Join(div, w.Method), sizeMessage(div, size), int(dateutil.SystemNow()-st), size)
+			if streamError(err, w.conf) != nil {
 				trace.Step(w.ctx, path.Join(div, w.Method), fmt.Sprintf("Error %s", err.Error()), 0, 0)
 			}
 		} else {
@@ -99,6 +101,9 @@ func newWrapServerStream(s grpc.ServerStream, ctx context.Context, method string
 func StreamServerInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
 		conf := config.GetConfig()
+		if !conf.GoGrpcProfileEnabled || isIgnoreMethod(info.FullMethod, conf) {
+			return handler(srv, ss)
+		}
 		if config.InArray(info.FullMethod, conf.GoGrpcProfileStreamMethod) {
 			ctx, _ := StartWithGrpcServerStream(ss.Context(), "/Start", info.FullMethod)
 			trace.End(ctx, nil)
@@ -106,18 +111,45 @@ func StreamServerInterceptor() grpc.StreamServerInterceptor {
 			err = handler(srv, newWrapServerStream(ss, ctx, info.FullMethod, conf))
 
 			ctx, _ = StartWithGrpcServerStream(ss.Context(), "/End", info.FullMethod)
-			trace.End(ctx, err)
+			endServer(ctx, err, 0, 0, conf)
 		} else {
 			ctx, _ := StartWithGrpcServerStream(ss.Context(), "", info.FullMethod)
 
 			err = handler(srv, newWrapServerStream(ss, ctx, info.FullMethod, conf))
 
-			trace.End(ctx, err)
+			endServer(ctx, err, 0, 0, conf)
 		}
 		return err
 	}
 }
 
+// endServer ends the transaction with the http status of the grpc status code and the message sizes.
+// The business error codes are recorded as a message step, not as the error of the transaction.
+func endServer(ctx context.Context, err error, reqSize, respSize int, conf *config.Config) {
+	code, status, traceErr := traceError(err, conf)
+	if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
+		traceCtx.Status = int32(status)
+		if reqSize > 0 {
+			traceCtx.RequestBytes = int64(reqSize)
+		}
+		if respSize > 0 {
+			traceCtx.ResponseBytes = int64(respSize)
+		}
+	}
+	if err != nil && traceErr == nil {
+		trace.Step(ctx, fmt.Sprintf("grpc-status %s", code), err.Error(), 0, int(code))
+	}
+	trace.End(ctx, traceErr)
+}
+
+// sizeMessage returns the message of the stream step with the message size.
+func sizeMessage(div string, size int) string {
+	if size <= 0 {
+		return div
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %d bytes", div, size))
+}
+
 func StartWithGrpcServerStream(ctx context.Context, div string, fullMethod string) (context.Context, error) {
 	conf := config.GetConfig()
 	ctx, traceCtx := trace.NewTraceContext(ctx)
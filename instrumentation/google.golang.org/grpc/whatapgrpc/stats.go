@@ -0,0 +1,300 @@
+package whatapgrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"sync"
+	"sync/atomic"
+
+	"github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/httpc"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/util/dateutil"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+const (
+	sideServer = "server"
+	sideClient = "client"
+)
+
+type connCtxKey struct{}
+type rpcCtxKey struct{}
+
+type connInfo struct {
+	side       string
+	localAddr  string
+	remoteAddr string
+}
+
+// rpcInfo 는 stream(RPC) 단위 상태. HandleRPC 는 송신, 수신 goroutine 에서 동시에 호출될 수 있음
+type rpcInfo struct {
+	method    string
+	startTime int64
+	// GoGrpcProfileStreamMethod 에 포함된 method 는 message 별로 트랜잭션 생성
+	streamMethod bool
+
+	// 아래 값은 lock 안에서 변경
+	lock   sync.Mutex
+	conn   *connInfo
+	stream bool
+	// client 의 httpc step. OutHeader 에서 시작
+	httpcCtx *httpc.HttpcCtx
+	// RPC 시작부터 첫 header, 마지막 trailer 까지의 시간
+	headerTime   int32
+	headerBytes  int
+	trailerTime  int32
+	trailerBytes int
+	wroteHeader  bool
+
+	messageIn  int32
+	messageOut int32
+	bytesIn    int64
+	bytesOut   int64
+}
+
+func (r *rpcInfo) getConn() *connInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.conn
+}
+
+func (r *rpcInfo) isStream() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.stream
+}
+
+func (r *rpcInfo) addHeader(wireLength int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.wroteHeader {
+		r.headerTime = int32(dateutil.SystemNow() - r.startTime)
+		r.wroteHeader = true
+	}
+	r.headerBytes += wireLength
+}
+
+func (r *rpcInfo) addTrailer(wireLength int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.trailerTime = int32(dateutil.SystemNow() - r.startTime)
+	r.trailerBytes += wireLength
+}
+
+// summary returns the header/trailer timings and the message counts of the RPC.
+func (r *rpcInfo) summary() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return fmt.Sprintf("header %dms %d bytes, trailer %dms %d bytes, in %d messages %d bytes, out %d messages %d bytes",
+		r.headerTime, r.headerBytes, r.trailerTime, r.trailerBytes,
+		atomic.LoadInt32(&r.messageIn), atomic.LoadInt64(&r.bytesIn), atomic.LoadInt32(&r.messageOut), atomic.LoadInt64(&r.bytesOut))
+}
+
+type statsHandler struct {
+	side string
+}
+
+// NewServerHandler returns the stats.Handler of the grpc server. It starts the transaction of each RPC like
+// UnaryServerInterceptor and StreamServerInterceptor, and records the payload sizes, the header/trailer timings,
+// the message counts of the stream and the counters of each connection.
+//
+//	s := grpc.NewServer(grpc.StatsHandler(whatapgrpc.NewServerHandler()))
+func NewServerHandler() stats.Handler {
+	return &statsHandler{side: sideServer}
+}
+
+// NewClientHandler returns the stats.Handler of the grpc client. It records the RPC as the httpc step of the transaction in ctx
+// like UnaryClientInterceptor, with the payload sizes, the header/trailer timings, the message counts of the stream and the counters of each connection.
+//
+//	conn, err := grpc.Dial(target, grpc.WithStatsHandler(whatapgrpc.NewClientHandler()))
+func NewClientHandler() stats.Handler {
+	return &statsHandler{side: sideClient}
+}
+
+func (h *statsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connCtxKey{}, &connInfo{side: h.side, localAddr: addrString(info.LocalAddr), remoteAddr: addrString(info.RemoteAddr)})
+}
+
+func (h *statsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	c, ok := ctx.Value(connCtxKey{}).(*connInfo)
+	if !ok {
+		return
+	}
+	switch s.(type) {
+	case *stats.ConnBegin:
+		meter.GetInstanceMeterGrpc().OpenConn(c.side, c.localAddr, c.remoteAddr)
+	case *stats.ConnEnd:
+		meter.GetInstanceMeterGrpc().CloseConn(c.side, c.localAddr, c.remoteAddr)
+	}
+}
+
+func (h *statsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	conf := config.GetConfig()
+	if !conf.GoGrpcProfileEnabled || isIgnoreMethod(info.FullMethodName, conf) {
+		return ctx
+	}
+	r := &rpcInfo{method: info.FullMethodName, startTime: dateutil.SystemNow()}
+	r.streamMethod = config.InArray(info.FullMethodName, conf.GoGrpcProfileStreamMethod)
+
+	if h.side == sideServer {
+		// server 의 stream context 는 connection context 에서 생성됨
+		if c, ok := ctx.Value(connCtxKey{}).(*connInfo); ok {
+			r.conn = c
+			meter.GetInstanceMeterGrpc().StartStream(c.side, c.localAddr, c.remoteAddr)
+		}
+		if r.streamMethod {
+			sCtx, _ := StartWithGrpcServerStream(ctx, "/Start", info.FullMethodName)
+			trace.End(sCtx, nil)
+		} else {
+			ctx, _ = StartWithGrpcServerStream(ctx, "", info.FullMethodName)
+		}
+	} else {
+		if r.streamMethod {
+			sCtx, _ := trace.Start(ctx, path.Join("/Start", info.FullMethodName))
+			trace.End(sCtx, nil)
+		}
+		ctx = appendOutgoingMTrace(ctx)
+	}
+	return context.WithValue(ctx, rpcCtxKey{}, r)
+}
+
+func (h *statsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	r, ok := ctx.Value(rpcCtxKey{}).(*rpcInfo)
+	if !ok {
+		return
+	}
+	conf := config.GetConfig()
+	switch st := s.(type) {
+	case *stats.Begin:
+		r.lock.Lock()
+		r.stream = st.IsClientStream || st.IsServerStream
+		r.lock.Unlock()
+	case *stats.OutHeader:
+		if st.Client {
+			// client 는 connection context 를 알 수 없으므로 주소로 connection 을 구분
+			c := &connInfo{side: sideClient, localAddr: addrString(st.LocalAddr), remoteAddr: addrString(st.RemoteAddr)}
+			meter.GetInstanceMeterGrpc().StartStream(c.side, c.localAddr, c.remoteAddr)
+			httpcCtx, _ := httpc.Start(ctx, fmt.Sprintf("grpc://%s%s", c.remoteAddr, r.method))
+			r.lock.Lock()
+			r.conn = c
+			r.httpcCtx = httpcCtx
+			r.lock.Unlock()
+		}
+		r.addHeader(0)
+	case *stats.InHeader:
+		r.addHeader(st.WireLength)
+	case *stats.OutTrailer:
+		r.addTrailer(st.WireLength)
+	case *stats.InTrailer:
+		r.addTrailer(st.WireLength)
+	case *stats.InPayload:
+		atomic.AddInt32(&r.messageIn, 1)
+		atomic.AddInt64(&r.bytesIn, int64(st.WireLength))
+		if c := r.getConn(); c != nil {
+			meter.GetInstanceMeterGrpc().AddMessageIn(c.side, c.localAddr, c.remoteAddr, int64(st.WireLength))
+		}
+		h.message(ctx, r, "/RecvMsg", st.WireLength, conf)
+	case *stats.OutPayload:
+		atomic.AddInt32(&r.messageOut, 1)
+		atomic.AddInt64(&r.bytesOut, int64(st.WireLength))
+		if c := r.getConn(); c != nil {
+			meter.GetInstanceMeterGrpc().AddMessageOut(c.side, c.localAddr, c.remoteAddr, int64(st.WireLength))
+		}
+		h.message(ctx, r, "/SendMsg", st.WireLength, conf)
+	case *stats.End:
+		h.end(ctx, r, st, conf)
+	}
+}
+
+// message records the message of the stream as a step, or as a transaction if the method is in go.grpc_profile_stream_method.
+func (h *statsHandler) message(ctx context.Context, r *rpcInfo, div string, wireLength int, conf *config.Config) {
+	if !r.isStream() && !r.streamMethod {
+		return
+	}
+	if h.side == sideServer && !conf.GoGrpcProfileStreamServerEnabled || h.side == sideClient && !conf.GoGrpcProfileStreamClientEnabled {
+		return
+	}
+	if !isStreamSampled(conf) {
+		return
+	}
+	if conf.GoGrpcProfileStreamIdentify {
+		if h.side == sideServer {
+			div = fmt.Sprintf("/%s%s", "StreamServer", div)
+		} else {
+			div = fmt.Sprintf("/%s%s", "StreamClient", div)
+		}
+	}
+	if r.streamMethod {
+		mCtx, _ := trace.Start(ctx, path.Join(div, r.method))
+		trace.Step(mCtx, path.Join(div, r.method), sizeMessage(div, wireLength), 0, wireLength)
+		trace.End(mCtx, nil)
+		return
+	}
+	trace.Step(ctx, path.Join(div, r.method), sizeMessage(div, wireLength), 0, wireLength)
+}
+
+func (h *statsHandler) end(ctx context.Context, r *rpcInfo, st *stats.End, conf *config.Config) {
+	r.lock.Lock()
+	c, httpcCtx := r.conn, r.httpcCtx
+	r.httpcCtx = nil
+	r.lock.Unlock()
+	if c != nil {
+		meter.GetInstanceMeterGrpc().EndStream(c.side, c.localAddr, c.remoteAddr)
+	}
+	messages := int(atomic.LoadInt32(&r.messageIn) + atomic.LoadInt32(&r.messageOut))
+	bytesIn, bytesOut := int(atomic.LoadInt64(&r.bytesIn)), int(atomic.LoadInt64(&r.bytesOut))
+
+	// header, trailer, message 는 RPC 의 step 하나로 기록
+	if h.side == sideServer {
+		if r.streamMethod {
+			eCtx, _ := StartWithGrpcServerStream(ctx, "/End", r.method)
+			trace.Step(eCtx, path.Join("/RPC", r.method), r.summary(), int(dateutil.SystemNow()-r.startTime), messages)
+			endServer(eCtx, st.Error, bytesIn, bytesOut, conf)
+			return
+		}
+		trace.Step(ctx, path.Join("/RPC", r.method), r.summary(), int(dateutil.SystemNow()-r.startTime), messages)
+		endServer(ctx, st.Error, bytesIn, bytesOut, conf)
+		return
+	}
+
+	code, status, traceErr := traceError(st.Error, conf)
+	if httpcCtx == nil {
+		// 전송 전에 실패한 경우
+		httpcCtx, _ = httpc.Start(ctx, fmt.Sprintf("grpc://%s", r.method))
+	}
+	httpcCtx.SetParam(r.summary())
+	httpc.End(httpcCtx, status, code.String(), traceErr)
+	if r.streamMethod {
+		eCtx, _ := trace.Start(ctx, path.Join("/End", r.method))
+		trace.End(eCtx, traceErr)
+	}
+}
+
+// appendOutgoingMTrace adds the multi trace headers of the transaction in ctx to the outgoing metadata.
+func appendOutgoingMTrace(ctx context.Context) context.Context {
+	if _, traceCtx := trace.GetTraceContext(ctx); traceCtx == nil {
+		return ctx
+	}
+	kv := make([]string, 0)
+	for k, v := range trace.GetMTrace(ctx) {
+		for _, it := range v {
+			kv = append(kv, k, it)
+		}
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
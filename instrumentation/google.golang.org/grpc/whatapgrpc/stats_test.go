@@ -0,0 +1,147 @@
+package whatapgrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/lang/step"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	// 트랜잭션은 RPC 종료 시 pool 에 반환되므로 이름만 전달
+	name chan string
+}
+
+func (s *testHealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	name := ""
+	if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
+		name = traceCtx.Name
+	}
+	s.name <- name
+	if req.Service == "unknown" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func startStatsServer(t *testing.T) (*testHealthServer, *grpc.ClientConn, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.StatsHandler(NewServerHandler()))
+	hs := &testHealthServer{name: make(chan string, 1)}
+	grpc_health_v1.RegisterHealthServer(server, hs)
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithStatsHandler(NewClientHandler()))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	return hs, conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func httpcSteps(traceCtx *trace.TraceCtx) []*step.HttpcStepX {
+	rt := make([]*step.HttpcStepX, 0)
+	for _, it := range traceCtx.Ctx.Profile.GetSteps() {
+		if st, ok := it.(*step.HttpcStepX); ok {
+			rt = append(rt, st)
+		}
+	}
+	return rt
+}
+
+func TestStatsHandlerUnary(t *testing.T) {
+	meter.GetInstanceMeterGrpc().GetBucketReset()
+	hs, conn, stop := startStatsServer(t)
+
+	ctx, _ := trace.Start(context.Background(), "/client")
+	_, clientCtx := trace.GetTraceContext(ctx)
+	if !assert.NotNil(t, clientCtx) {
+		return
+	}
+	client := grpc_health_v1.NewHealthClient(conn)
+	resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "order"})
+	assert.Nil(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	// server 는 RPC 마다 트랜잭션을 시작
+	name := <-hs.name
+	assert.True(t, strings.HasSuffix(name, "/grpc.health.v1.Health/Check"), name)
+
+	// client 는 httpc step 하나에 header, trailer, message 를 기록
+	steps := httpcSteps(clientCtx)
+	if assert.Equal(t, 1, len(steps)) {
+		assert.Equal(t, int32(200), steps[0].Status)
+		assert.True(t, strings.HasPrefix(steps[0].Param, "header "), steps[0].Param)
+		assert.True(t, strings.Contains(steps[0].Param, "in 1 messages"), steps[0].Param)
+		assert.True(t, strings.Contains(steps[0].Param, "out 1 messages"), steps[0].Param)
+	}
+	assert.Equal(t, 1, len(clientCtx.Ctx.Profile.GetSteps()))
+	trace.End(ctx, nil)
+	stop()
+
+	// connection 별 counter
+	var server, clnt *meter.GrpcConnBucket
+	assert.Eventually(t, func() bool {
+		conns, _, _ := meter.GetInstanceMeterGrpc().GetBucketReset()
+		for _, b := range conns {
+			if b.Side == sideServer && b.Streams > 0 {
+				server = b
+			}
+			if b.Side == sideClient && b.Streams > 0 {
+				clnt = b
+			}
+		}
+		return server != nil && clnt != nil
+	}, time.Second, 10*time.Millisecond)
+	if assert.NotNil(t, server) && assert.NotNil(t, clnt) {
+		assert.Equal(t, int32(1), server.Streams)
+		assert.Equal(t, int32(0), server.ActiveStreams)
+		assert.Equal(t, int32(1), server.MessageIn)
+		assert.Equal(t, int32(1), server.MessageOut)
+		assert.Equal(t, int32(1), clnt.Streams)
+		assert.Equal(t, int32(1), clnt.MessageIn)
+		assert.Equal(t, int32(1), clnt.MessageOut)
+		assert.Equal(t, server.BytesIn, clnt.BytesOut)
+		assert.Equal(t, server.BytesOut, clnt.BytesIn)
+	}
+}
+
+func TestStatsHandlerError(t *testing.T) {
+	hs, conn, stop := startStatsServer(t)
+	defer stop()
+
+	ctx, _ := trace.Start(context.Background(), "/client")
+	defer trace.End(ctx, nil)
+	_, clientCtx := trace.GetTraceContext(ctx)
+	if !assert.NotNil(t, clientCtx) {
+		return
+	}
+	client := grpc_health_v1.NewHealthClient(conn)
+	_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	<-hs.name
+
+	steps := httpcSteps(clientCtx)
+	if assert.Equal(t, 1, len(steps)) {
+		assert.Equal(t, int32(404), steps[0].Status)
+		assert.NotEqual(t, int64(0), steps[0].Error)
+	}
+}
@@ -0,0 +1,100 @@
+package whatapgrpc
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/whatap/go-api/agent/agent/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/runtime/protoimpl"
+)
+
+// grpc status code 에 대응하는 http status. (grpc-gateway 와 같은 매핑)
+var httpStatusOfCode = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+}
+
+// HttpStatus returns the http status of the grpc status code.
+func HttpStatus(code codes.Code) int {
+	if st, ok := httpStatusOfCode[code]; ok {
+		return st
+	}
+	return http.StatusInternalServerError
+}
+
+// IsBizErrorCode returns true if the code is in go.grpc_biz_error_codes. The code is the name (NotFound) or the number (5).
+func IsBizErrorCode(code codes.Code, conf *config.Config) bool {
+	if code == codes.OK {
+		return false
+	}
+	return config.InArray(code.String(), conf.GoGrpcBizErrorCodes) || config.InArray(strconv.Itoa(int(code)), conf.GoGrpcBizErrorCodes)
+}
+
+// traceError returns the grpc status code and the http status of err, and the error to be recorded.
+// The business error codes are not recorded as the error of the transaction.
+func traceError(err error, conf *config.Config) (codes.Code, int, error) {
+	if err == nil {
+		return codes.OK, http.StatusOK, nil
+	}
+	code := status.Code(err)
+	if IsBizErrorCode(code, conf) {
+		return code, HttpStatus(code), nil
+	}
+	return code, HttpStatus(code), err
+}
+
+// streamError returns nil for io.EOF which is the normal end of the stream.
+func streamError(err error, conf *config.Config) error {
+	if err == io.EOF {
+		return nil
+	}
+	_, _, err = traceError(err, conf)
+	return err
+}
+
+func isIgnoreMethod(method string, conf *config.Config) bool {
+	return config.InArray(method, conf.GoGrpcProfileIgnoreMethod)
+}
+
+// isStreamSampled returns true at the rate(%) of go.grpc_profile_stream_rate
+func isStreamSampled(conf *config.Config) bool {
+	if conf.GoGrpcProfileStreamRate >= 100 {
+		return true
+	}
+	return rand.Float32()*100 < float32(conf.GoGrpcProfileStreamRate)
+}
+
+// messageSize returns the encoded size of the proto message, or 0 if unknown.
+func messageSize(m interface{}) int {
+	switch v := m.(type) {
+	case proto.Message:
+		return proto.Size(v)
+	case protoiface.MessageV1:
+		// github.com/golang/protobuf 로 생성된 message
+		return proto.Size(protoimpl.X.ProtoMessageV2Of(v))
+	case interface{ Size() int }:
+		return v.Size()
+	}
+	return 0
+}
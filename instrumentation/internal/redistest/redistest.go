@@ -0,0 +1,107 @@
+// Package redistest has the tests shared by whatapgoredis of go-redis v8 and v9.
+package redistest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/lang/step"
+)
+
+// Client is the instrumented client of each go-redis version.
+type Client struct {
+	Set func(ctx context.Context, key, value string) error
+	Get func(ctx context.Context, key string) (string, error)
+	Do  func(ctx context.Context, args ...interface{}) error
+	// SET KEY VALUE, INCR COUNT, GET NOT_EXISTS
+	Pipelined func(ctx context.Context) error
+	// INCR COUNT, GET KEY
+	TxPipelined func(ctx context.Context) error
+	// redis.Nil
+	Nil error
+}
+
+// GetSqlSteps returns the sql steps of the transaction of ctx.
+func GetSqlSteps(ctx context.Context) []*step.SqlStepX {
+	rt := make([]*step.SqlStepX, 0)
+	if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
+		for _, it := range traceCtx.Ctx.Profile.GetSteps() {
+			if st, ok := it.(*step.SqlStepX); ok {
+				rt = append(rt, st)
+			}
+		}
+	}
+	return rt
+}
+
+// RunHook checks that each command is a sql step and redis.Nil is not an error.
+func RunHook(t *testing.T, c Client) {
+	trace.Init(make(map[string]string))
+	defer trace.Shutdown()
+
+	assert := assert.New(t)
+	ctx, err := trace.Start(context.Background(), "TEST")
+	if assert.Nil(err) != true {
+		return
+	}
+
+	assert.Nil(c.Set(ctx, "KEY", "VALUE"))
+	v, err := c.Get(ctx, "KEY")
+	assert.Nil(err)
+	assert.Equal("VALUE", v)
+
+	// redis.Nil 은 오류가 아님
+	_, err = c.Get(ctx, "NOT_EXISTS")
+	assert.Equal(c.Nil, err)
+
+	err = c.Do(ctx, "NOT_COMMAND")
+	assert.NotNil(err)
+
+	steps := GetSqlSteps(ctx)
+	if assert.Equal(4, len(steps)) {
+		assert.Equal(int64(0), steps[0].Error)
+		assert.Equal(int64(0), steps[1].Error)
+		assert.Equal(int64(0), steps[2].Error)
+		assert.NotEqual(int64(0), steps[3].Error)
+	}
+	trace.End(ctx, nil)
+}
+
+// RunPipeline checks that each pipeline is a sql step.
+func RunPipeline(t *testing.T, s *miniredis.Miniredis, c Client) {
+	trace.Init(make(map[string]string))
+	defer trace.Shutdown()
+
+	assert := assert.New(t)
+	ctx, err := trace.Start(context.Background(), "TEST")
+	if assert.Nil(err) != true {
+		return
+	}
+
+	assert.Equal(c.Nil, c.Pipelined(ctx))
+	assert.Nil(c.TxPipelined(ctx))
+	v, _ := s.Get("COUNT")
+	assert.Equal("2", v)
+
+	steps := GetSqlSteps(ctx)
+	if assert.Equal(2, len(steps)) {
+		assert.Equal(int64(0), steps[0].Error)
+		assert.Equal(int64(0), steps[1].Error)
+	}
+	trace.End(ctx, nil)
+}
+
+// RunWithoutTransaction checks the commands executed outside of the transaction.
+func RunWithoutTransaction(t *testing.T, c Client) {
+	trace.Init(make(map[string]string))
+	defer trace.Shutdown()
+
+	assert := assert.New(t)
+	assert.Nil(c.Set(context.Background(), "KEY", "VALUE"))
+	v, err := c.Get(context.Background(), "KEY")
+	assert.Nil(err)
+	assert.Equal("VALUE", v)
+}
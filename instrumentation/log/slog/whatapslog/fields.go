@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package whatapslog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/whatap/go-api/trace"
+)
+
+// Attrs returns the attributes which link the log to the transaction of ctx. (@txid, @mtid, trace_id)
+// It is used for the handlers other than Handler which writes the log to the file watched by logsink.
+//
+//	logger.With(whatapslog.Attrs(ctx)...).Info("hello")
+func Attrs(ctx context.Context) []any {
+	m := trace.LogFields(ctx)
+	rt := make([]any, 0, len(m))
+	for k, v := range m {
+		rt = append(rt, slog.String(k, v))
+	}
+	return rt
+}
@@ -0,0 +1,112 @@
+//go:build go1.21
+// +build go1.21
+
+// github.com/whatap/go-api/instrumentation/log/slog/whatapslog
+package whatapslog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/whatap/go-api/logsink"
+)
+
+// Handler is the slog.Handler which sends the records to whatap and passes them to the next handler.
+// The txid of the transaction of the context given to the *Context methods of slog.Logger is added to the @txid tag.
+//
+//	logger := slog.New(whatapslog.NewHandler(slog.NewJSONHandler(os.Stdout, nil), "app"))
+//	logger.InfoContext(ctx, "hello", "user", id)
+type Handler struct {
+	next     slog.Handler
+	category string
+	// WithAttrs 로 추가한 속성
+	fields map[string]string
+	// WithGroup 의 prefix
+	group string
+}
+
+// NewHandler returns the Handler of the category. next may be nil if the records are sent to whatap only.
+func NewHandler(next slog.Handler, category string) *Handler {
+	h := new(Handler)
+	h.next = next
+	h.category = category
+	h.fields = make(map[string]string)
+	return h
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.next != nil {
+		return h.next.Enabled(ctx, level)
+	}
+	return level >= slog.LevelInfo
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make(map[string]string, len(h.fields)+r.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(fields, h.group, a)
+		return true
+	})
+	send(ctx, h.category, r.Time, r.Level.String(), r.Message, fields)
+	if h.next != nil {
+		return h.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+// Handler 가 전송하는 함수. 테스트에서 변경
+var send = logsink.Send
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := h.clone()
+	for _, a := range attrs {
+		addAttr(h2.fields, h.group, a)
+	}
+	if h.next != nil {
+		h2.next = h.next.WithAttrs(attrs)
+	}
+	return h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := h.clone()
+	h2.group = h.group + name + "."
+	if h.next != nil {
+		h2.next = h.next.WithGroup(name)
+	}
+	return h2
+}
+
+func (h *Handler) clone() *Handler {
+	h2 := NewHandler(h.next, h.category)
+	for k, v := range h.fields {
+		h2.fields[k] = v
+	}
+	h2.group = h.group
+	return h2
+}
+
+// addAttr adds the attribute to the fields. The attributes of the group are added with the dot.
+func addAttr(fields map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		// 이름이 없는 그룹은 상위에 추가
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		for _, it := range a.Value.Group() {
+			addAttr(fields, prefix, it)
+		}
+		return
+	}
+	fields[prefix+a.Key] = a.Value.String()
+}
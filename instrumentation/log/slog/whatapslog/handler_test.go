@@ -0,0 +1,93 @@
+//go:build go1.21
+// +build go1.21
+
+package whatapslog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/trace"
+)
+
+type sentLog struct {
+	ctx      context.Context
+	category string
+	time     time.Time
+	level    string
+	msg      string
+	fields   map[string]string
+}
+
+func capture(t *testing.T) *[]sentLog {
+	sent := make([]sentLog, 0)
+	old := send
+	send = func(ctx context.Context, category string, tm time.Time, level string, msg string, fields map[string]string) {
+		sent = append(sent, sentLog{ctx, category, tm, level, msg, fields})
+	}
+	t.Cleanup(func() { send = old })
+	return &sent
+}
+
+func TestHandler(t *testing.T) {
+	sent := capture(t)
+	ctx, _ := trace.Start(context.Background(), "/whatapslog")
+	defer trace.End(ctx, nil)
+
+	logger := slog.New(NewHandler(nil, "app"))
+	start := time.Now()
+	logger.WarnContext(ctx, "hello", "a", 1, slog.Group("user", "id", 7, "name", "kim"))
+	logger.Log(ctx, slog.LevelWarn+2, "warn+2")
+	// next 가 없으면 info 이상
+	logger.DebugContext(ctx, "debug")
+
+	if assert.Equal(t, 2, len(*sent)) {
+		it := (*sent)[0]
+		assert.Equal(t, "app", it.category)
+		assert.Equal(t, "WARN", it.level)
+		assert.Equal(t, "hello", it.msg)
+		assert.False(t, it.time.Before(start))
+		assert.Equal(t, map[string]string{"a": "1", "user.id": "7", "user.name": "kim"}, it.fields)
+		assert.NotEqual(t, "", trace.GetTxidString(it.ctx))
+		assert.Equal(t, trace.GetTxidString(ctx), trace.GetTxidString(it.ctx))
+
+		assert.Equal(t, "WARN+2", (*sent)[1].level)
+	}
+}
+
+func TestHandlerWithAttrsGroup(t *testing.T) {
+	sent := capture(t)
+	var buf bytes.Buffer
+	next := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := slog.New(NewHandler(next, "app"))
+
+	child := logger.With("a", "1").WithGroup("req").With("method", "GET").WithGroup("")
+	child.Debug("child", "n", 2, slog.Group("", "inline", true), slog.Any("empty", nil), slog.Attr{})
+	logger.Info("parent")
+
+	if assert.Equal(t, 2, len(*sent)) {
+		// WithGroup 이후의 속성은 그룹 이름을 prefix 로 추가
+		assert.Equal(t, "DEBUG", (*sent)[0].level)
+		assert.Equal(t, map[string]string{"a": "1", "req.method": "GET", "req.n": "2", "req.inline": "true", "req.empty": "<nil>"}, (*sent)[0].fields)
+		// With, WithGroup 은 원래 handler 를 변경하지 않음
+		assert.Equal(t, map[string]string{}, (*sent)[1].fields)
+	}
+	// next handler 에도 같은 속성과 그룹을 전달
+	assert.Contains(t, buf.String(), `"a":"1","req":{"method":"GET","n":2,"inline":true`)
+	assert.Contains(t, buf.String(), `"msg":"parent"`)
+}
+
+func TestHandlerEnabled(t *testing.T) {
+	h := NewHandler(nil, "app")
+	assert.False(t, h.Enabled(context.Background(), slog.LevelDebug))
+	assert.True(t, h.Enabled(context.Background(), slog.LevelInfo))
+
+	var buf bytes.Buffer
+	h = NewHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError}), "app")
+	assert.False(t, h.Enabled(context.Background(), slog.LevelWarn))
+	assert.True(t, h.Enabled(context.Background(), slog.LevelError))
+}
@@ -0,0 +1,84 @@
+// github.com/whatap/go-api/logsink
+package logsink
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	"github.com/whatap/go-api/agent/logsink/watch"
+	"github.com/whatap/go-api/trace"
+)
+
+// category of the log which is not in the transaction
+const DefaultCategory = "AppLog"
+
+// Send sends the log to whatap without writing it to the file when logsink_enabled.
+// The ids of the transaction of ctx are added to the @txid, @mtid and trace_id tags so that the log is linked to the trace.
+// The log of lower level than logsink_level is not sent.
+func Send(ctx context.Context, category string, t time.Time, level string, msg string, fields map[string]string) {
+	conf := agentconfig.GetConfig()
+	if !conf.LogSinkEnabled || msg == "" {
+		return
+	}
+	lv := watch.ParseLevel(level)
+	if lv != watch.LEVEL_NONE && lv < watch.ParseLevel(conf.LogSinkLevel) {
+		return
+	}
+	if category == "" {
+		category = DefaultCategory
+	}
+	p := watch.NewLogSinkPack(category)
+	if !t.IsZero() {
+		p.Time = t.UnixMilli()
+	}
+	for k, v := range fields {
+		p.Tags.PutString(k, v)
+	}
+	if lv != watch.LEVEL_NONE {
+		p.Tags.PutString("level", watch.LevelName(lv))
+	}
+	if m := trace.LogFields(ctx); m != nil {
+		// AppLogParser 와 같이 트랜잭션의 로그는 AppLog 카테고리로 전송
+		p.Category = watch.AppLogCategory
+		for k, v := range m {
+			p.Tags.PutString(k, v)
+		}
+		if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
+			agenttrace.AddLogCount(traceCtx.Txid)
+		}
+	}
+	p.Content = msg
+	sendLogSinkPack(p)
+}
+
+// Send 가 전송하는 함수. 테스트에서 변경
+var sendLogSinkPack = watch.SendLogSinkPack
+
+// Writer is the io.Writer for the log package which sends each line to whatap.
+//
+//	log.SetOutput(logsink.NewWriter("app", os.Stderr))
+type Writer struct {
+	category string
+	out      io.Writer
+}
+
+// NewWriter returns the Writer of the category. The log is also written to out if out is not nil.
+func NewWriter(category string, out io.Writer) *Writer {
+	p := new(Writer)
+	p.category = category
+	p.out = out
+	return p
+}
+
+func (this *Writer) Write(b []byte) (int, error) {
+	// log 패키지는 한 번에 한 라인씩 씀. context 가 없으므로 go.use_goroutine_id_enabled 인 경우만 txid 를 추가
+	Send(context.Background(), this.category, time.Now(), "", strings.TrimRight(string(b), "\r\n"), nil)
+	if this.out != nil {
+		return this.out.Write(b)
+	}
+	return len(b), nil
+}
@@ -0,0 +1,122 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/logsink/watch"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/lang/pack"
+)
+
+// capture enables logsink and collects the packs sent by Send.
+func capture(t *testing.T, level string) *[]*pack.LogSinkPack {
+	conf := agentconfig.GetConfig()
+	oldEnabled, oldLevel := conf.LogSinkEnabled, conf.LogSinkLevel
+	conf.LogSinkEnabled, conf.LogSinkLevel = true, level
+
+	sent := make([]*pack.LogSinkPack, 0)
+	oldSend := sendLogSinkPack
+	sendLogSinkPack = func(p *pack.LogSinkPack) {
+		sent = append(sent, p)
+	}
+	t.Cleanup(func() {
+		conf.LogSinkEnabled, conf.LogSinkLevel = oldEnabled, oldLevel
+		sendLogSinkPack = oldSend
+	})
+	return &sent
+}
+
+func TestSend(t *testing.T) {
+	sent := capture(t, "")
+	tm := time.Unix(1700000000, 123000000)
+	Send(context.Background(), "app", tm, "WARN+2", "hello", map[string]string{"user": "kim"})
+	Send(context.Background(), "", time.Time{}, "", "no category", nil)
+	// 메시지가 없는 로그는 전송하지 않음
+	Send(context.Background(), "app", tm, "info", "", nil)
+
+	if assert.Equal(t, 2, len(*sent)) {
+		p := (*sent)[0]
+		assert.Equal(t, "app", p.Category)
+		assert.Equal(t, int64(1700000000123), p.Time)
+		assert.Equal(t, "hello", p.Content)
+		assert.Equal(t, "warn", p.Tags.GetString("level"))
+		assert.Equal(t, "kim", p.Tags.GetString("user"))
+
+		p = (*sent)[1]
+		assert.Equal(t, DefaultCategory, p.Category)
+		assert.NotEqual(t, int64(0), p.Time)
+		assert.Equal(t, "", p.Tags.GetString("level"))
+	}
+}
+
+func TestSendLevel(t *testing.T) {
+	sent := capture(t, "warn")
+	Send(context.Background(), "app", time.Now(), "info", "info", nil)
+	Send(context.Background(), "app", time.Now(), "error", "error", nil)
+	// 레벨이 없는 로그는 전송
+	Send(context.Background(), "app", time.Now(), "", "none", nil)
+
+	contents := make([]string, 0)
+	for _, p := range *sent {
+		contents = append(contents, p.Content)
+	}
+	assert.Equal(t, []string{"error", "none"}, contents)
+}
+
+func TestSendDisabled(t *testing.T) {
+	sent := capture(t, "")
+	agentconfig.GetConfig().LogSinkEnabled = false
+	Send(context.Background(), "app", time.Now(), "info", "hello", nil)
+	assert.Equal(t, 0, len(*sent))
+}
+
+func TestSendTransaction(t *testing.T) {
+	sent := capture(t, "")
+	ctx, _ := trace.Start(context.Background(), "/logsink")
+	_, traceCtx := trace.GetTraceContext(ctx)
+	txid := trace.GetTxidString(ctx)
+	Send(ctx, "app", time.Now(), "info", "in tx", map[string]string{"a": "1"})
+	// 전송한 로그 수를 트랜잭션에 기록
+	assert.Equal(t, int32(1), atomic.LoadInt32(&traceCtx.Ctx.LogCount))
+	// 전송하지 않은 로그는 기록하지 않음
+	Send(ctx, "app", time.Now(), "info", "", nil)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&traceCtx.Ctx.LogCount))
+	trace.End(ctx, nil)
+
+	// 트랜잭션의 로그는 AppLog 카테고리로 @txid 를 추가
+	if assert.Equal(t, 1, len(*sent)) {
+		p := (*sent)[0]
+		assert.NotEqual(t, "", txid)
+		assert.Equal(t, watch.AppLogCategory, p.Category)
+		assert.Equal(t, txid, p.Tags.GetString(watch.TxIdTag))
+		assert.Equal(t, "1", p.Tags.GetString("a"))
+	}
+}
+
+func TestWriter(t *testing.T) {
+	sent := capture(t, "")
+	var buf bytes.Buffer
+	w := NewWriter("std", &buf)
+	n, err := w.Write([]byte("line 1\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 8, n)
+	assert.Equal(t, "line 1\r\n", buf.String())
+
+	// out 이 없으면 전송만 함
+	n, err = NewWriter("", nil).Write([]byte("line 2\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 7, n)
+
+	if assert.Equal(t, 2, len(*sent)) {
+		assert.Equal(t, "std", (*sent)[0].Category)
+		assert.Equal(t, "line 1", (*sent)[0].Content)
+		assert.Equal(t, DefaultCategory, (*sent)[1].Category)
+		assert.Equal(t, "line 2", (*sent)[1].Content)
+	}
+}
@@ -46,10 +46,12 @@ const (
 	STEP_ERROR_MESSAGE_MAX_SIZE = 4 * 1024
 )
 
-func Start(ctx context.Context, name string) (*MethodCtx, error) {
+// Start starts the method step and returns a copy of ctx carrying the step as the current parent.
+// sql, httpc and method steps started with the returned context are recorded as children of the method step.
+func Start(ctx context.Context, name string) (context.Context, *MethodCtx, error) {
 	conf := agentconfig.GetConfig()
 	if !conf.ProfileMethodEnabled {
-		return PoolMethodContext(), nil
+		return ctx, PoolMethodContext(), nil
 	}
 	methodCtx := PoolMethodContext()
 
@@ -60,11 +62,16 @@ func Start(ctx context.Context, name string) (*MethodCtx, error) {
 		methodCtx.ctx = traceCtx
 		methodCtx.Txid = traceCtx.Txid
 		methodCtx.ServiceName = traceCtx.Name
-		methodCtx.step = agentapi.StartMethod(traceCtx.Ctx, methodCtx.StartTime, methodCtx.Method)
-		return methodCtx, nil
+		st := agentapi.StartMethod(traceCtx.Ctx, methodCtx.StartTime, methodCtx.Method)
+		if st == nil {
+			return ctx, methodCtx, nil
+		}
+		methodCtx.step = st
+		agentapi.PushStep(traceCtx.Ctx, trace.GetParentStep(ctx), st)
+		return trace.WithParentStep(ctx, st.GetIndex()), methodCtx, nil
 	}
 
-	return methodCtx, nil
+	return ctx, methodCtx, nil
 }
 func End(methodCtx *MethodCtx, err error) error {
 	conf := agentconfig.GetConfig()
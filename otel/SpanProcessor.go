@@ -0,0 +1,382 @@
+// github.com/whatap/go-api/otel
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	agentapi "github.com/whatap/go-api/agent/agent/trace/api"
+	"github.com/whatap/go-api/trace"
+	"github.com/whatap/golib/util/dateutil"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// SPAN_TTL is the default time after which the span not ended is removed from the SpanProcessor.
+const SPAN_TTL = 10 * time.Minute
+
+// interval of removing the spans of the ended transactions
+const sweepInterval = 10 * time.Second
+
+// spanCtx is the whatap transaction of a started span.
+type spanCtx struct {
+	ctx      context.Context
+	traceCtx *trace.TraceCtx
+	// span 시작 시점의 txid. TraceCtx 는 pool 에서 재사용되므로 종료 후에는 읽지 않음
+	txid int64
+	// processor 가 시작한 트랜잭션. 외부에서 시작된 트랜잭션이면 nil
+	root *rootCtx
+	// 트랜잭션을 시작한 root span
+	isRoot    bool
+	startTime time.Time
+}
+
+// alive reports whether the transaction of the span is not ended.
+// The transaction started by the processor is checked with rootCtx.ended and the others with the active transactions of the agent.
+// It is called with the lock of the SpanProcessor.
+func (this *spanCtx) alive() bool {
+	if this.root != nil {
+		return !this.root.ended
+	}
+	return agenttrace.ContainsTxid(this.txid)
+}
+
+// rootCtx is the whatap transaction started by a root span. Child spans ended after the root are ignored.
+type rootCtx struct {
+	// trace.End 와 하위 span 의 step 수집을 직렬화
+	lock sync.Mutex
+	// root.lock 과 SpanProcessor.lock 을 모두 잡고 변경. 둘 중 하나를 잡고 읽음
+	ended bool
+}
+
+// SpanProcessor is a sdktrace.SpanProcessor which sends OpenTelemetry spans to whatap.
+// A root span (no local parent) starts a transaction with trace.Start and ends it with trace.End.
+// Child spans are profiled as sql, httpc or method steps by their semantic convention attributes.
+// The spans are removed when their transaction ends or when they are not ended within TTL.
+type SpanProcessor struct {
+	// span 이 종료되지 않아도 제거하는 시간. 기본값 SPAN_TTL
+	TTL time.Duration
+
+	lock      sync.Mutex
+	spans     map[oteltrace.SpanID]*spanCtx
+	lastSweep time.Time
+}
+
+func NewSpanProcessor() *SpanProcessor {
+	p := new(SpanProcessor)
+	p.TTL = SPAN_TTL
+	p.spans = make(map[oteltrace.SpanID]*spanCtx)
+	p.lastSweep = time.Now()
+	return p
+}
+
+// NewTracerProvider returns a sdktrace.TracerProvider with the whatap SpanProcessor registered.
+func NewTracerProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append(opts, sdktrace.WithSpanProcessor(NewSpanProcessor()))
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+func (this *SpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled {
+		return
+	}
+	sc := s.SpanContext()
+
+	// 이 processor 가 시작한 트랜잭션의 하위 span. goroutine id 로 조회되는 트랜잭션보다 먼저 확인
+	if p := s.Parent(); p.IsValid() && !p.IsRemote() {
+		if c := this.getAlive(p.SpanID()); c != nil {
+			this.put(sc.SpanID(), &spanCtx{ctx: c.ctx, traceCtx: c.traceCtx, txid: c.txid, root: c.root, startTime: s.StartTime()})
+			return
+		}
+	}
+
+	// whatap 트랜잭션 안에서 시작된 span
+	if _, traceCtx := trace.GetTraceContext(parent); traceCtx != nil {
+		this.put(sc.SpanID(), &spanCtx{ctx: parent, traceCtx: traceCtx, txid: traceCtx.Txid, startTime: s.StartTime()})
+		return
+	}
+
+	ctx, err := trace.Start(context.Background(), s.Name())
+	_, traceCtx := trace.GetTraceContext(ctx)
+	if err != nil || traceCtx == nil {
+		return
+	}
+	// 원격 parent 의 traceparent, tracestate 를 multi transaction trace 로 연결
+	if p := s.Parent(); p.IsValid() && p.IsRemote() {
+		h := make(http.Header)
+		h.Set(conf.TraceMtraceTraceparentKey, fmt.Sprintf("00-%s-%s-%s", p.TraceID(), p.SpanID(), p.TraceFlags()))
+		if ts := p.TraceState().String(); ts != "" {
+			h.Set(conf.TraceMtraceTracestateKey, ts)
+		}
+		trace.UpdateMtraceWithContext(ctx, h)
+	}
+	if conf.Debug {
+		log.Println("[WA-OTEL-01001] start transaction: ", s.Name(), ", trace_id=", sc.TraceID(), ", span_id=", sc.SpanID())
+	}
+	this.put(sc.SpanID(), &spanCtx{ctx: ctx, traceCtx: traceCtx, txid: traceCtx.Txid, root: &rootCtx{}, isRoot: true, startTime: s.StartTime()})
+}
+
+func (this *SpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	c := this.remove(s.SpanContext().SpanID())
+	if c == nil {
+		return
+	}
+	err := spanError(s)
+	attrs := attributeMap(s.Attributes())
+
+	// root span
+	if c.isRoot {
+		c.root.lock.Lock()
+		defer c.root.lock.Unlock()
+		// TTL, Shutdown 으로 이미 종료된 트랜잭션
+		if c.root.ended {
+			return
+		}
+		if v, ok := attrs[semconv.HTTPStatusCodeKey]; ok {
+			c.traceCtx.Status = int32(v.AsInt64())
+		}
+		trace.End(c.ctx, err)
+		// 종료되지 않은 하위 span 제거
+		this.removeRoot(c.root)
+		return
+	}
+
+	var wCtx *agenttrace.TraceContext
+	if c.root != nil {
+		c.root.lock.Lock()
+		defer c.root.lock.Unlock()
+		if c.root.ended {
+			return
+		}
+		wCtx = c.traceCtx.Ctx
+	} else {
+		// 외부에서 시작된 트랜잭션은 종료 후 재사용된 TraceCtx 를 읽지 않도록 txid 로 조회
+		wCtx = agenttrace.GetContext(c.txid)
+	}
+	if wCtx == nil {
+		return
+	}
+	elapsed := int32(s.EndTime().Sub(s.StartTime()) / time.Millisecond)
+	startTime := dateutil.SystemNow() - int64(time.Since(s.StartTime())/time.Millisecond)
+
+	switch {
+	case has(attrs, semconv.DBSystemKey):
+		sql := attrs[semconv.DBStatementKey].AsString()
+		if sql == "" {
+			sql = s.Name()
+		}
+		agentapi.ProfileSql(wCtx, startTime, dbHost(attrs), sql, "", elapsed, 0, 0, err)
+
+	case has(attrs, semconv.HTTPMethodKey) && isClient(s):
+		status := int32(attrs[semconv.HTTPStatusCodeKey].AsInt64())
+		agentapi.ProfileHttpc(wCtx, startTime, httpUrl(attrs), elapsed, status, "", 0, 0, 0, err)
+
+	case has(attrs, semconv.RPCSystemKey) && isClient(s):
+		url := fmt.Sprintf("%s://%s/%s/%s", attrs[semconv.RPCSystemKey].AsString(), peer(attrs), attrs[semconv.RPCServiceKey].AsString(), attrs[semconv.RPCMethodKey].AsString())
+		agentapi.ProfileHttpc(wCtx, startTime, url, elapsed, 0, "", 0, 0, 0, err)
+
+	case has(attrs, semconv.MessagingSystemKey) && (s.SpanKind() == oteltrace.SpanKindProducer || isClient(s)):
+		url := fmt.Sprintf("%s://%s/%s", attrs[semconv.MessagingSystemKey].AsString(), peer(attrs), attrs[semconv.MessagingDestinationKey].AsString())
+		agentapi.ProfileHttpc(wCtx, startTime, url, elapsed, 0, "", 0, 0, 0, err)
+
+	default:
+		agentapi.ProfileMethod(wCtx, startTime, s.Name(), "", elapsed, 0, 0, err)
+		if err != nil {
+			agentapi.ProfileError(wCtx, err)
+		}
+	}
+}
+
+// Shutdown ends the transactions of the spans which are not ended.
+func (this *SpanProcessor) Shutdown(ctx context.Context) error {
+	this.lock.Lock()
+	spans := this.spans
+	this.spans = make(map[oteltrace.SpanID]*spanCtx)
+	this.lock.Unlock()
+
+	for _, c := range spans {
+		if !c.isRoot {
+			continue
+		}
+		this.endRoot(c)
+	}
+	return nil
+}
+
+// ForceFlush does nothing. Ended transactions are sent by the agent.
+func (this *SpanProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func (this *SpanProcessor) put(id oteltrace.SpanID, c *spanCtx) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.spans[id] = c
+	if time.Since(this.lastSweep) >= sweepInterval {
+		this.sweep()
+	}
+}
+
+// sweep removes the spans of the ended transactions and the spans older than TTL. It is called with the lock.
+func (this *SpanProcessor) sweep() {
+	now := time.Now()
+	this.lastSweep = now
+	for id, c := range this.spans {
+		if c.isRoot {
+			// root span 은 TTL 이 지나면 트랜잭션을 종료
+			if this.TTL > 0 && now.Sub(c.startTime) >= this.TTL {
+				delete(this.spans, id)
+				go this.endRoot(c)
+			}
+			continue
+		}
+		if !c.alive() || (this.TTL > 0 && now.Sub(c.startTime) >= this.TTL) {
+			delete(this.spans, id)
+		}
+	}
+}
+
+func (this *SpanProcessor) endRoot(c *spanCtx) {
+	c.root.lock.Lock()
+	defer c.root.lock.Unlock()
+	if !c.root.ended {
+		trace.End(c.ctx, nil)
+	}
+	this.removeRoot(c.root)
+}
+
+// removeRoot marks the root ended and removes its child spans. It is called with root.lock after trace.End.
+func (this *SpanProcessor) removeRoot(root *rootCtx) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	root.ended = true
+	for id, c := range this.spans {
+		if c.root == root {
+			delete(this.spans, id)
+		}
+	}
+}
+
+func (this *SpanProcessor) size() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return len(this.spans)
+}
+
+func (this *SpanProcessor) get(id oteltrace.SpanID) *spanCtx {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.spans[id]
+}
+
+// getAlive returns the span only if its transaction is not ended.
+func (this *SpanProcessor) getAlive(id oteltrace.SpanID) *spanCtx {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if c := this.spans[id]; c != nil && c.alive() {
+		return c
+	}
+	return nil
+}
+
+func (this *SpanProcessor) remove(id oteltrace.SpanID) *spanCtx {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	c := this.spans[id]
+	delete(this.spans, id)
+	return c
+}
+
+func spanError(s sdktrace.ReadOnlySpan) error {
+	if s.Status().Code != codes.Error {
+		return nil
+	}
+	msg := s.Status().Description
+	// exception event 의 메시지 사용
+	for _, e := range s.Events() {
+		if e.Name != semconv.ExceptionEventName {
+			continue
+		}
+		attrs := attributeMap(e.Attributes)
+		if msg == "" {
+			msg = attrs[semconv.ExceptionMessageKey].AsString()
+		}
+		if t := attrs[semconv.ExceptionTypeKey].AsString(); t != "" {
+			msg = t + ": " + msg
+		}
+		break
+	}
+	if msg == "" {
+		msg = s.Name() + " error"
+	}
+	return errors.New(msg)
+}
+
+func attributeMap(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func has(attrs map[attribute.Key]attribute.Value, key attribute.Key) bool {
+	_, ok := attrs[key]
+	return ok
+}
+
+func isClient(s sdktrace.ReadOnlySpan) bool {
+	return s.SpanKind() == oteltrace.SpanKindClient
+}
+
+func peer(attrs map[attribute.Key]attribute.Value) string {
+	host := attrs[semconv.NetPeerNameKey].AsString()
+	if host == "" {
+		host = attrs[semconv.NetPeerIPKey].AsString()
+	}
+	if port := attrs[semconv.NetPeerPortKey].AsInt64(); port > 0 {
+		host = fmt.Sprintf("%s:%d", host, port)
+	}
+	return host
+}
+
+// dbHost returns db.system://net.peer.name:port/db.name
+func dbHost(attrs map[attribute.Key]attribute.Value) string {
+	host := attrs[semconv.DBSystemKey].AsString() + "://" + peer(attrs)
+	if db := attrs[semconv.DBNameKey].AsString(); db != "" {
+		host += "/" + db
+	}
+	return host
+}
+
+func httpUrl(attrs map[attribute.Key]attribute.Value) string {
+	if url := attrs[semconv.HTTPURLKey].AsString(); url != "" {
+		return url
+	}
+	scheme := attrs[semconv.HTTPSchemeKey].AsString()
+	if scheme == "" {
+		scheme = "http"
+	}
+	host := attrs[semconv.HTTPHostKey].AsString()
+	if host == "" {
+		host = peer(attrs)
+	}
+	target := attrs[semconv.HTTPTargetKey].AsString()
+	if !strings.HasPrefix(target, "/") {
+		target = "/" + target
+	}
+	return scheme + "://" + host + target
+}
@@ -0,0 +1,173 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/trace"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracer() (*SpanProcessor, oteltrace.Tracer) {
+	p := NewSpanProcessor()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	return p, tp.Tracer("test")
+}
+
+func TestSpanProcessorRoot(t *testing.T) {
+	p, tracer := newTestTracer()
+
+	_, root := tracer.Start(context.Background(), "/order")
+	assert.Equal(t, 1, p.size())
+	c := p.get(root.SpanContext().SpanID())
+	assert.NotNil(t, c)
+	assert.True(t, c.isRoot)
+	assert.True(t, c.alive())
+
+	root.End()
+	assert.Equal(t, 0, p.size())
+	assert.False(t, c.alive())
+}
+
+func TestSpanProcessorChild(t *testing.T) {
+	p, tracer := newTestTracer()
+
+	ctx, root := tracer.Start(context.Background(), "/order")
+	_, child := tracer.Start(ctx, "select", oteltrace.WithAttributes(semconv.DBSystemKey.String("mysql"), semconv.DBStatementKey.String("select 1")))
+	_, leaked := tracer.Start(ctx, "leaked")
+	assert.Equal(t, 3, p.size())
+
+	// 하위 span 은 root 의 트랜잭션을 사용
+	rc := p.get(root.SpanContext().SpanID())
+	cc := p.get(child.SpanContext().SpanID())
+	assert.Equal(t, rc.txid, cc.txid)
+	assert.Equal(t, rc.root, cc.root)
+	assert.False(t, cc.isRoot)
+
+	child.End()
+	assert.Equal(t, 2, p.size())
+
+	// root 가 종료되면 종료되지 않은 하위 span 도 제거
+	root.End()
+	assert.Equal(t, 0, p.size())
+	leaked.End()
+	assert.Equal(t, 0, p.size())
+}
+
+func TestSpanProcessorInTransaction(t *testing.T) {
+	p, tracer := newTestTracer()
+
+	ctx, err := trace.Start(context.Background(), "/tx")
+	assert.Nil(t, err)
+	_, traceCtx := trace.GetTraceContext(ctx)
+	assert.NotNil(t, traceCtx)
+
+	_, s1 := tracer.Start(ctx, "method")
+	_, s2 := tracer.Start(ctx, "leaked")
+	c := p.get(s2.SpanContext().SpanID())
+	assert.Equal(t, traceCtx.Txid, c.txid)
+	assert.Nil(t, c.root)
+	s1.End()
+	assert.Equal(t, 1, p.size())
+
+	// 트랜잭션이 종료된 span 은 sweep 에서 제거
+	trace.End(ctx, nil)
+	assert.False(t, c.alive())
+	p.lock.Lock()
+	p.sweep()
+	p.lock.Unlock()
+	assert.Equal(t, 0, p.size())
+	s2.End()
+}
+
+func TestSpanProcessorTTL(t *testing.T) {
+	p, tracer := newTestTracer()
+	p.TTL = time.Millisecond
+
+	ctx, root := tracer.Start(context.Background(), "/long")
+	tracer.Start(ctx, "leaked")
+	assert.Equal(t, 2, p.size())
+	rc := p.get(root.SpanContext().SpanID())
+
+	time.Sleep(5 * time.Millisecond)
+	// 다음 put 에서 sweep
+	p.lock.Lock()
+	p.lastSweep = time.Time{}
+	p.lock.Unlock()
+	_, other := tracer.Start(context.Background(), "/other")
+
+	// TTL 이 지난 root span 은 트랜잭션을 종료
+	assert.Eventually(t, func() bool {
+		rc.root.lock.Lock()
+		defer rc.root.lock.Unlock()
+		return rc.root.ended
+	}, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return p.size() == 1 }, time.Second, 10*time.Millisecond)
+	assert.NotNil(t, p.get(other.SpanContext().SpanID()))
+
+	root.End()
+	other.End()
+	assert.Equal(t, 0, p.size())
+}
+
+func TestSpanProcessorShutdown(t *testing.T) {
+	p, tracer := newTestTracer()
+
+	_, root := tracer.Start(context.Background(), "/order")
+	rc := p.get(root.SpanContext().SpanID())
+	assert.Nil(t, p.Shutdown(context.Background()))
+	assert.Equal(t, 0, p.size())
+	assert.True(t, rc.root.ended)
+	assert.False(t, rc.alive())
+}
+
+func TestHttpUrl(t *testing.T) {
+	attrs := attributeMap([]attribute.KeyValue{semconv.HTTPURLKey.String("https://example.com/a?b=1")})
+	assert.Equal(t, "https://example.com/a?b=1", httpUrl(attrs))
+
+	attrs = attributeMap([]attribute.KeyValue{semconv.HTTPHostKey.String("example.com"), semconv.HTTPTargetKey.String("a")})
+	assert.Equal(t, "http://example.com/a", httpUrl(attrs))
+
+	attrs = attributeMap([]attribute.KeyValue{semconv.HTTPSchemeKey.String("https"), semconv.NetPeerNameKey.String("api"), semconv.NetPeerPortKey.Int(8443), semconv.HTTPTargetKey.String("/v1")})
+	assert.Equal(t, "https://api:8443/v1", httpUrl(attrs))
+}
+
+func TestDbHost(t *testing.T) {
+	attrs := attributeMap([]attribute.KeyValue{semconv.DBSystemKey.String("mysql"), semconv.NetPeerIPKey.String("10.0.0.1"), semconv.NetPeerPortKey.Int(3306), semconv.DBNameKey.String("shop")})
+	assert.Equal(t, "mysql://10.0.0.1:3306/shop", dbHost(attrs))
+
+	attrs = attributeMap([]attribute.KeyValue{semconv.DBSystemKey.String("redis"), semconv.NetPeerNameKey.String("cache")})
+	assert.Equal(t, "redis://cache", dbHost(attrs))
+}
+
+func TestSpanError(t *testing.T) {
+	_, tracer := newTestTracer()
+
+	_, s := tracer.Start(context.Background(), "ok")
+	s.End()
+	assert.Nil(t, spanError(s.(sdktrace.ReadOnlySpan)))
+
+	_, s = tracer.Start(context.Background(), "desc")
+	s.SetStatus(codes.Error, "failed")
+	s.End()
+	assert.Equal(t, errors.New("failed"), spanError(s.(sdktrace.ReadOnlySpan)))
+
+	_, s = tracer.Start(context.Background(), "exception")
+	s.RecordError(errors.New("boom"))
+	s.SetStatus(codes.Error, "")
+	s.End()
+	assert.Equal(t, "*errors.errorString: boom", spanError(s.(sdktrace.ReadOnlySpan)).Error())
+
+	_, s = tracer.Start(context.Background(), "noname")
+	s.SetStatus(codes.Error, "")
+	s.End()
+	assert.Equal(t, "noname error", spanError(s.(sdktrace.ReadOnlySpan)).Error())
+}
@@ -0,0 +1,54 @@
+//github.com/whatap/go-api/sql
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"log"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+)
+
+// RegisterDB registers the connection pool of db so that the statistics of the pool (DBStats) are reported periodically
+// by the dbhost of which the password is hidden. The pool is unregistered once db is closed.
+func RegisterDB(dbhost string, db *dbsql.DB) {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled || db == nil {
+		return
+	}
+	url := hidePwd(dbhost)
+	agenttrace.RegisterDBConnPool(db, url, func() *agenttrace.DBPoolStat {
+		if isClosed(db) {
+			return nil
+		}
+		st := db.Stats()
+		return &agenttrace.DBPoolStat{
+			MaxOpen:           int32(st.MaxOpenConnections),
+			Open:              int32(st.OpenConnections),
+			InUse:             int32(st.InUse),
+			Idle:              int32(st.Idle),
+			WaitCount:         st.WaitCount,
+			WaitTime:          st.WaitDuration.Milliseconds(),
+			MaxIdleClosed:     st.MaxIdleClosed,
+			MaxLifetimeClosed: st.MaxLifetimeClosed,
+		}
+	})
+	if conf.Debug {
+		log.Println("[WA-SQL-04009] RegisterDB dbhost: ", url)
+	}
+}
+
+// UnregisterDB stops reporting the statistics of the connection pool of db.
+func UnregisterDB(db *dbsql.DB) {
+	agenttrace.UnregisterDBConnPool(db)
+}
+
+// isClosed returns true if db is closed. The closed db returns the error before checking the context,
+// so the cancelled context never acquires the connection of the open db.
+func isClosed(db *dbsql.DB) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := db.PingContext(ctx)
+	return err != nil && err != context.Canceled
+}
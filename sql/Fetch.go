@@ -0,0 +1,84 @@
+//github.com/whatap/go-api/sql
+package sql
+
+import (
+	"log"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	agentapi "github.com/whatap/go-api/agent/agent/trace/api"
+	"github.com/whatap/go-api/trace"
+
+	"github.com/whatap/golib/lang/step"
+	"github.com/whatap/golib/util/dateutil"
+)
+
+// FetchCtx records the rows fetched from the cursor of the query. (driver.Rows)
+type FetchCtx struct {
+	ctx  *trace.TraceCtx
+	txid int64
+	step *step.SqlStepX
+	// go.sql_profile_cursor_enabled 이면 EndFetch 에서 sql step 을 종료
+	sqlCtx *SqlCtx
+
+	StartTime int64
+	Count     int32
+	closed    bool
+}
+
+// StartFetch ends the sql step of the query and returns the FetchCtx of the rows.
+// If go.sql_profile_cursor_enabled is true, the sql step ends in EndFetch so that the elapsed of the step covers the cursor.
+// If err is not nil, the sql step ends with err and nil is returned.
+func StartFetch(sqlCtx *SqlCtx, err error) *FetchCtx {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled || err != nil || sqlCtx == nil {
+		end(sqlCtx, -1, err)
+		return nil
+	}
+	st, ok := sqlCtx.step.(*step.SqlStepX)
+	if !ok {
+		end(sqlCtx, -1, nil)
+		return nil
+	}
+	f := &FetchCtx{ctx: sqlCtx.ctx, txid: sqlCtx.Txid, step: st}
+	if conf.GoSqlProfileCursorEnabled {
+		f.sqlCtx = sqlCtx
+	} else {
+		end(sqlCtx, -1, nil)
+	}
+	f.StartTime = dateutil.SystemNow()
+	return f
+}
+
+// Fetch counts the fetched row.
+func (f *FetchCtx) Fetch() {
+	if f != nil {
+		f.Count++
+	}
+}
+
+// EndFetch records the fetch count and the fetch time of the rows. err is the error of the fetch except io.EOF.
+// If the sql step is already ended (go.sql_profile_cursor_enabled is false), err is recorded as the error of the transaction.
+func EndFetch(f *FetchCtx, err error) {
+	if f == nil || f.closed {
+		return
+	}
+	f.closed = true
+	elapsed := int32(dateutil.SystemNow() - f.StartTime)
+
+	// Rows 를 닫기 전에 트랜잭션이 종료되어 다른 트랜잭션에서 재사용된 경우 통계만 수집
+	var wCtx *agenttrace.TraceContext
+	if f.ctx != nil && f.ctx.Txid == f.txid {
+		wCtx = f.ctx.Ctx
+	}
+	if f.sqlCtx != nil {
+		end(f.sqlCtx, -1, err)
+		f.sqlCtx = nil
+	} else if err != nil && !IsIgnoreError(err) {
+		agentapi.ProfileError(wCtx, err)
+	}
+	agentapi.ProfileSqlFetch(wCtx, f.step, f.StartTime, f.Count, elapsed)
+	if conf := agentconfig.GetConfig(); conf.Debug {
+		log.Println("[WA-SQL-04006] Fetch txid: ", f.txid, "\n fetch: ", f.Count, "\n time: ", elapsed, "ms ", "\n error: ", err)
+	}
+}
@@ -0,0 +1,64 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+)
+
+func TestHidePwd(t *testing.T) {
+	tests := []struct {
+		connStr string
+		hidden  string
+	}{
+		// mysql
+		{"user:pw@tcp(127.0.0.1:3306)/db?parseTime=true", "user:#@tcp(127.0.0.1:3306)/db?parseTime=true"},
+		{"user@tcp(127.0.0.1:3306)/db", "user@tcp(127.0.0.1:3306)/db"},
+		{"tcp(127.0.0.1:3306)/db", "tcp(127.0.0.1:3306)/db"},
+		// url
+		{"postgres://user:pw@localhost:5432/db?sslmode=disable", "postgres://user:#@localhost:5432/db?sslmode=disable"},
+		{"postgres://localhost:5432/db", "postgres://localhost:5432/db"},
+		{"sqlserver://sa:pw@localhost:1433?database=db", "sqlserver://sa:#@localhost:1433?database=db"},
+		// key=value
+		{"host=localhost user=u password=pw dbname=db", "host=localhost user=u password=# dbname=db"},
+		{"host=localhost:5432 user=u password=pw", "host=localhost:5432 user=u password=#"},
+		{"server=localhost;user id=sa;password=pw;database=db", "server=localhost;user id=sa;password=#;database=db"},
+		{"file:test.db?cache=shared", "file:test.db?cache=shared"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.hidden, hidePwd(tt.connStr), tt.connStr)
+	}
+}
+
+type closedConnector struct{}
+
+func (closedConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("not supported")
+}
+func (closedConnector) Driver() driver.Driver { return nil }
+
+func TestRegisterDB(t *testing.T) {
+	url := "pool://user:#@localhost/register"
+	db := dbsql.OpenDB(closedConnector{})
+	db.SetMaxOpenConns(3)
+	RegisterDB("pool://user:pw@localhost/register", db)
+	assert.False(t, isClosed(db))
+
+	st, ok := agenttrace.GetDBConnPoolStats()[url]
+	if assert.True(t, ok) {
+		assert.Equal(t, int32(3), st.MaxOpen)
+		assert.Equal(t, int32(0), st.InUse)
+	}
+
+	// 종료된 DB 는 등록 해제
+	db.Close()
+	assert.True(t, isClosed(db))
+	_, ok = agenttrace.GetDBConnPoolStats()[url]
+	assert.False(t, ok)
+}
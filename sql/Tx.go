@@ -0,0 +1,111 @@
+//github.com/whatap/go-api/sql
+package sql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	agentapi "github.com/whatap/go-api/agent/agent/trace/api"
+	"github.com/whatap/go-api/trace"
+
+	"github.com/whatap/golib/lang/step"
+	"github.com/whatap/golib/util/dateutil"
+)
+
+const (
+	TX_COMMIT   = "commit"
+	TX_ROLLBACK = "rollback"
+	TX_ERROR    = "error"
+)
+
+// DB 트랜잭션 id. 프로세스 내에서 증가
+var lastTxId int64
+
+// TxCtx is the DB transaction (driver.Tx) of the connection.
+// The sql steps executed in the transaction are recorded as the children of the transaction step sql.Tx(dbhost).
+type TxCtx struct {
+	ctx  *trace.TraceCtx
+	step *step.MethodStepX
+	// 트랜잭션 시작 시점의 httpc 수. 트랜잭션 중 원격 호출 확인
+	httpcCount int32
+
+	Id        int64
+	Txid      int64
+	StartTime int64
+	Dbc       string
+	// 트랜잭션에서 실행된 sql 수
+	Count int32
+}
+
+// StartTx starts the step of the DB transaction with a new transaction id.
+func StartTx(ctx context.Context, dbhost string) *TxCtx {
+	t := &TxCtx{Id: atomic.AddInt64(&lastTxId, 1), StartTime: dateutil.SystemNow(), Dbc: hidePwd(dbhost)}
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled {
+		return t
+	}
+	if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil {
+		t.ctx = traceCtx
+		t.Txid = traceCtx.Txid
+		t.httpcCount = atomic.LoadInt32(&traceCtx.Ctx.HttpcCount)
+		t.step = agentapi.StartMethod(traceCtx.Ctx, t.StartTime, fmt.Sprintf("sql.Tx(%s)", t.Dbc))
+		agentapi.PushStep(traceCtx.Ctx, trace.GetParentStep(ctx), t.step)
+		agentapi.ProfileMsg(traceCtx.Ctx, "DB Transaction Begin", fmt.Sprintf("id=%d", t.Id), 0, 0)
+	}
+	if conf.Debug {
+		log.Println("[WA-SQL-04007] Begin txid: ", t.Txid, "\n dbhost: ", t.Dbc, "\n id: ", t.Id)
+	}
+	return t
+}
+
+// WithTx counts the statement of the transaction and returns the context of which the sql step is recorded
+// as the child of the transaction step. If t is nil, ctx is returned.
+func WithTx(ctx context.Context, t *TxCtx) context.Context {
+	if t == nil {
+		return ctx
+	}
+	atomic.AddInt32(&t.Count, 1)
+	if t.step == nil {
+		return ctx
+	}
+	// 커넥션이 다른 트랜잭션의 context 로 사용된 경우 하위 step 으로 기록하지 않음
+	if _, traceCtx := trace.GetTraceContext(ctx); traceCtx != nil && traceCtx.Txid == t.Txid {
+		return trace.WithParentStep(ctx, t.step.GetIndex())
+	}
+	return ctx
+}
+
+// EndTx ends the step of the DB transaction with the outcome (TX_COMMIT, TX_ROLLBACK, TX_ERROR) and records
+// the duration and the count of the statements. The transaction which holds the connection across remote calls
+// (httpc steps in the transaction) is recorded as the DB Transaction Warning step.
+func EndTx(t *TxCtx, outcome string, err error) error {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled || t == nil {
+		return nil
+	}
+	elapsed := int32(dateutil.SystemNow() - t.StartTime)
+	count := atomic.LoadInt32(&t.Count)
+
+	var httpc int32
+	var wCtx *agenttrace.TraceContext
+	// 트랜잭션이 종료되어 다른 트랜잭션에서 재사용된 경우 기록하지 않음
+	if t.ctx != nil && t.ctx.Txid == t.Txid {
+		wCtx = t.ctx.Ctx
+	}
+	if wCtx != nil {
+		httpc = atomic.LoadInt32(&wCtx.HttpcCount) - t.httpcCount
+		agentapi.EndMethod(wCtx, t.step, "", elapsed, 0, 0, err)
+		agentapi.ProfileMsg(wCtx, "DB Transaction", fmt.Sprintf("id=%d, %s, statements=%d, elapsed=%dms", t.Id, outcome, count, elapsed), elapsed, count)
+		if httpc > 0 {
+			agentapi.ProfileMsg(wCtx, "DB Transaction Warning", fmt.Sprintf("id=%d held the connection across %d remote calls (httpc) for %dms", t.Id, httpc, elapsed), elapsed, httpc)
+		}
+	}
+	if conf.Debug {
+		log.Println("[WA-SQL-04008] End txid: ", t.Txid, "\n dbhost: ", t.Dbc, "\n id: ", t.Id, "\n outcome: ", outcome, "\n statements: ", count, "\n httpc: ", httpc, "\n time: ", elapsed, "ms ", "\n error: ", err)
+	}
+	return nil
+}
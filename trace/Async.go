@@ -0,0 +1,126 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	agentapi "github.com/whatap/go-api/agent/agent/trace/api"
+	"github.com/whatap/golib/util/dateutil"
+)
+
+// Fork returns a context for the async work (goroutine) of the transaction in ctx.
+// Steps started with the returned context are recorded under the async step of the transaction.
+// The async work must be ended with EndFork. If it ends after the transaction, it is sent as a child transaction.
+func Fork(ctx context.Context, name string) (context.Context, error) {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled {
+		return ctx, nil
+	}
+	_, parent := GetTraceContext(ctx)
+	if parent == nil {
+		return ctx, fmt.Errorf("Not found Txid ")
+	}
+
+	parent.asyncLock.Lock()
+	defer parent.asyncLock.Unlock()
+	if parent.asyncId == 0 {
+		return ctx, fmt.Errorf("Transaction is ended ")
+	}
+
+	startTime := dateutil.SystemNow()
+	wCtx, st := agentapi.StartAsync(parent.Ctx, startTime, name, GetParentStep(ctx))
+	if wCtx == nil {
+		return ctx, fmt.Errorf("Not found Txid ")
+	}
+
+	traceCtx := PoolTraceContext()
+	traceCtx.Ctx = wCtx
+	// async 작업도 부모 트랜잭션의 txid, multi transaction 정보를 사용
+	traceCtx.Txid = parent.Txid
+	traceCtx.Name = parent.Name
+	traceCtx.StartTime = parent.StartTime
+	traceCtx.MTid = parent.MTid
+	traceCtx.MDepth = parent.MDepth
+	traceCtx.MCallerTxid = parent.MCallerTxid
+	traceCtx.MCallerTraceId = parent.MCallerTraceId
+	traceCtx.TraceMtraceCallerValue = parent.TraceMtraceCallerValue
+	traceCtx.TraceMtracePoidValue = parent.TraceMtracePoidValue
+	traceCtx.TraceMtraceSpecValue = parent.TraceMtraceSpecValue
+	traceCtx.TraceMtraceMcallee = parent.TraceMtraceMcallee
+	traceCtx.TraceMtraceTraceparentValue = parent.TraceMtraceTraceparentValue
+	traceCtx.TraceMtraceTracestateValue = parent.TraceMtraceTracestateValue
+	traceCtx.TraceState = append([]string(nil), parent.TraceState...)
+	if b := Baggage(ctx); len(b) > 0 {
+		traceCtx.Baggage = b
+	}
+
+	traceCtx.asyncId = wCtx.Txid
+	traceCtx.asyncParent = parent
+	traceCtx.asyncParentId = parent.asyncId
+	traceCtx.asyncName = name
+	traceCtx.asyncStartTime = startTime
+	traceCtx.asyncStep = st
+
+	ctx = context.WithValue(ctx, traceCtxKey{}, traceCtx)
+	// async 작업의 step 은 async step 의 하위 step
+	ctx = context.WithValue(ctx, parentStepKey{}, (*parentStep)(nil))
+	if conf.Debug {
+		log.Println("[WA-TX-10001] Fork txid: ", parent.Txid, ", uri: ", parent.Name, "\n async: ", name)
+	}
+	return ctx, nil
+}
+
+// EndFork ends the async work started by Fork. The steps of the async work are merged into the transaction
+// if it is active, otherwise they are sent as a child transaction of the transaction.
+func EndFork(ctx context.Context, err error) error {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled {
+		return nil
+	}
+	_, traceCtx := GetTraceContext(ctx)
+	if traceCtx == nil || traceCtx.asyncParent == nil {
+		return fmt.Errorf("Not found async Txid ")
+	}
+	elapsed := int32(dateutil.SystemNow() - traceCtx.asyncStartTime)
+
+	// 이후에 종료되는 하위 async 작업은 child 트랜잭션으로 전송
+	traceCtx.asyncLock.Lock()
+	traceCtx.asyncId = 0
+	traceCtx.asyncLock.Unlock()
+
+	parent := traceCtx.asyncParent
+	parent.asyncLock.Lock()
+	merged := parent.asyncId != 0 && parent.asyncId == traceCtx.asyncParentId
+	if merged {
+		agentapi.MergeAsync(parent.Ctx, traceCtx.Ctx, traceCtx.asyncStep, elapsed, err)
+	} else {
+		agentapi.SendAsync(traceCtx.Txid, traceCtx.Ctx, traceCtx.asyncName, traceCtx.asyncStartTime, elapsed, err)
+	}
+	parent.asyncLock.Unlock()
+
+	if conf.Debug {
+		log.Println("[WA-TX-10002] EndFork txid: ", traceCtx.Txid, ", uri: ", traceCtx.Name, "\n async: ", traceCtx.asyncName,
+			"\n time: ", elapsed, "ms ", "\n merged: ", merged, "\n error: ", err)
+	}
+	traceCtx.Ctx = nil
+	CloseTraceContext(traceCtx)
+	return nil
+}
+
+// Go runs fn in a new goroutine with the context of Fork and ends the async work when fn returns.
+// A panic of fn is recorded as the error of the async work and re-panicked.
+func Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	forkCtx, _ := Fork(ctx, name)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				EndFork(forkCtx, fmt.Errorf("panic: %v", r))
+				panic(r)
+			}
+			EndFork(forkCtx, nil)
+		}()
+		fn(forkCtx)
+	}()
+}
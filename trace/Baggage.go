@@ -0,0 +1,111 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+)
+
+const (
+	// max length of W3C baggage header
+	BAGGAGE_MAX_SIZE = 8192
+	// max list-members of W3C baggage header
+	BAGGAGE_MAX_MEMBERS = 180
+)
+
+// SetBaggage sets the baggage member of the transaction.
+// Baggage is propagated to the next calls with the multi transaction trace headers (mtrace_enabled=true).
+func SetBaggage(ctx context.Context, key, value string) error {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled {
+		return nil
+	}
+	key = strings.TrimSpace(key)
+	if key == "" || strings.ContainsAny(key, ",;= \t\"\\") {
+		return fmt.Errorf("Invalid baggage key %q", key)
+	}
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
+		traceCtx.baggageLock.Lock()
+		defer traceCtx.baggageLock.Unlock()
+		if traceCtx.Baggage == nil {
+			traceCtx.Baggage = make(map[string]string)
+		}
+		if _, ok := traceCtx.Baggage[key]; !ok && len(traceCtx.Baggage) >= BAGGAGE_MAX_MEMBERS {
+			return fmt.Errorf("Too many baggage members ")
+		}
+		traceCtx.Baggage[key] = value
+		return nil
+	}
+	return fmt.Errorf("Not found Txid ")
+}
+
+// Baggage returns a copy of the baggage of the transaction.
+func Baggage(ctx context.Context) map[string]string {
+	rt := make(map[string]string)
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
+		traceCtx.baggageLock.Lock()
+		defer traceCtx.baggageLock.Unlock()
+		for k, v := range traceCtx.Baggage {
+			rt[k] = v
+		}
+	}
+	return rt
+}
+
+// parseBaggage adds the members of the W3C baggage header. Properties of the member are ignored.
+func parseBaggage(traceCtx *TraceCtx, v string) {
+	traceCtx.baggageLock.Lock()
+	defer traceCtx.baggageLock.Unlock()
+	for _, it := range strings.Split(v, ",") {
+		if pos := strings.Index(it, ";"); pos >= 0 {
+			it = it[:pos]
+		}
+		pos := strings.Index(it, "=")
+		if pos <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(it[:pos])
+		value, err := url.PathUnescape(strings.TrimSpace(it[pos+1:]))
+		if key == "" || err != nil {
+			continue
+		}
+		if traceCtx.Baggage == nil {
+			traceCtx.Baggage = make(map[string]string)
+		}
+		if len(traceCtx.Baggage) >= BAGGAGE_MAX_MEMBERS {
+			break
+		}
+		traceCtx.Baggage[key] = value
+	}
+}
+
+// baggageValue returns the W3C baggage header of the transaction. Members over BAGGAGE_MAX_SIZE are dropped.
+func baggageValue(traceCtx *TraceCtx) string {
+	traceCtx.baggageLock.Lock()
+	defer traceCtx.baggageLock.Unlock()
+	if len(traceCtx.Baggage) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(traceCtx.Baggage))
+	for k := range traceCtx.Baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		member := k + "=" + url.PathEscape(traceCtx.Baggage[k])
+		if sb.Len()+len(member)+1 > BAGGAGE_MAX_SIZE {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(member)
+	}
+	return sb.String()
+}
@@ -0,0 +1,75 @@
+package trace
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBaggage(t *testing.T) {
+	tests := []struct {
+		header  string
+		baggage map[string]string
+	}{
+		{"userId=alice,serverNode=DF%2028,isProduction=false",
+			map[string]string{"userId": "alice", "serverNode": "DF 28", "isProduction": "false"}},
+		// property 는 무시
+		{"key1=value1;property1;property2, key2 = value2 ;prop=1",
+			map[string]string{"key1": "value1", "key2": "value2"}},
+		// '=' 를 포함한 값
+		{"token=a=b", map[string]string{"token": "a=b"}},
+		// key 가 없거나, '=' 가 없거나, 잘못된 percent-encoding 인 member 는 제외
+		{"=v,novalue,bad=%zz,ok=1", map[string]string{"ok": "1"}},
+		{"", map[string]string(nil)},
+	}
+	for _, tt := range tests {
+		traceCtx := &TraceCtx{}
+		parseBaggage(traceCtx, tt.header)
+		assert.Equal(t, tt.baggage, traceCtx.Baggage, tt.header)
+	}
+}
+
+func TestParseBaggageMaxMembers(t *testing.T) {
+	arr := make([]string, 0)
+	for i := 0; i < BAGGAGE_MAX_MEMBERS+10; i++ {
+		arr = append(arr, fmt.Sprintf("k%d=%d", i, i))
+	}
+	traceCtx := &TraceCtx{}
+	parseBaggage(traceCtx, strings.Join(arr, ","))
+	assert.Equal(t, BAGGAGE_MAX_MEMBERS, len(traceCtx.Baggage))
+	assert.Equal(t, "0", traceCtx.Baggage["k0"])
+	_, ok := traceCtx.Baggage[fmt.Sprintf("k%d", BAGGAGE_MAX_MEMBERS)]
+	assert.False(t, ok)
+}
+
+func TestBaggageValue(t *testing.T) {
+	traceCtx := &TraceCtx{}
+	assert.Equal(t, "", baggageValue(traceCtx))
+
+	traceCtx.Baggage = map[string]string{"user": "alice", "node": "DF 28", "list": "a,b;c", "pct": "100%"}
+	// key 순서로 정렬하고 값은 percent-encoding
+	v := baggageValue(traceCtx)
+	assert.Equal(t, "list=a%2Cb%3Bc,node=DF%2028,pct=100%25,user=alice", v)
+
+	// round-trip
+	other := &TraceCtx{}
+	parseBaggage(other, v)
+	assert.Equal(t, traceCtx.Baggage, other.Baggage)
+}
+
+func TestBaggageValueMaxSize(t *testing.T) {
+	traceCtx := &TraceCtx{}
+	traceCtx.Baggage = map[string]string{
+		"a": strings.Repeat("x", BAGGAGE_MAX_SIZE/2),
+		"b": strings.Repeat("y", BAGGAGE_MAX_SIZE/2),
+		"c": "small",
+	}
+	// 크기를 넘는 member 는 제외하고 나머지는 유지
+	v := baggageValue(traceCtx)
+	assert.True(t, len(v) <= BAGGAGE_MAX_SIZE)
+	assert.True(t, strings.HasPrefix(v, "a=xxx"))
+	assert.True(t, strings.HasSuffix(v, ",c=small"))
+	assert.False(t, strings.Contains(v, "b=y"))
+}
@@ -0,0 +1,96 @@
+// github.com/whatap/go-api/trace
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+
+	"github.com/whatap/golib/util/hexa32"
+)
+
+const (
+	LOG_TXID_KEY    = agenttrace.LOG_TXID_KEY
+	LOG_MTID_KEY    = "@mtid"
+	LOG_TRACEID_KEY = "trace_id"
+)
+
+// GetTxidString returns the txid of the transaction of ctx in hexa32. It returns "" if ctx is not in the transaction.
+func GetTxidString(ctx context.Context) string {
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil && traceCtx.Txid != 0 {
+		return hexa32.ToString32(traceCtx.Txid)
+	}
+	return ""
+}
+
+// GetMtidString returns the multi trace id of the transaction of ctx in hexa32. It returns "" if there is no multi trace.
+func GetMtidString(ctx context.Context) string {
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil && traceCtx.MTid != 0 {
+		return hexa32.ToString32(traceCtx.MTid)
+	}
+	return ""
+}
+
+// GetTraceId returns the W3C trace-id of the traceparent sent by the transaction of ctx.
+// It returns "" if there is no multi trace.
+func GetTraceId(ctx context.Context) string {
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
+		return traceId(traceCtx)
+	}
+	return ""
+}
+
+func traceId(traceCtx *TraceCtx) string {
+	if traceCtx.MCallerTraceId != "" {
+		return traceCtx.MCallerTraceId
+	}
+	if traceCtx.MTid != 0 {
+		return fmt.Sprintf("0000000000000000%016x", uint64(traceCtx.MTid))
+	}
+	return ""
+}
+
+// LogFields returns the fields of the log line which link the log to the transaction of ctx. (@txid, @mtid, trace_id)
+// The log line sent by logsink with @txid is shown in the profile of the transaction,
+// and the number of the log lines sent is recorded in the LogCount field.
+// It returns nil if ctx is not in the transaction.
+//
+//	log.Println("order failed", trace.LogFields(ctx))
+func LogFields(ctx context.Context) map[string]string {
+	_, traceCtx := GetTraceContext(ctx)
+	if traceCtx == nil || traceCtx.Txid == 0 {
+		return nil
+	}
+	// logsink_txidtag 로 변경된 tag 사용
+	key := LOG_TXID_KEY
+	if tag := agentconfig.GetConfig().TxIdTag; tag != "" {
+		key = tag
+	}
+	m := map[string]string{key: hexa32.ToString32(traceCtx.Txid)}
+	if traceCtx.MTid != 0 {
+		m[LOG_MTID_KEY] = hexa32.ToString32(traceCtx.MTid)
+	}
+	if id := traceId(traceCtx); id != "" {
+		m[LOG_TRACEID_KEY] = id
+	}
+	return m
+}
+
+// LogMarker returns the marker of the log line, -- {"@txid":"..."} --, which logsink parses from the watched file.
+// It returns "" if ctx is not in the transaction.
+//
+//	log.Println(trace.LogMarker(ctx), "order failed")
+func LogMarker(ctx context.Context) string {
+	m := LogFields(ctx)
+	if m == nil {
+		return ""
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return "-- " + string(b) + " --"
+}
@@ -0,0 +1,93 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/golib/util/hexa32"
+)
+
+func TestGetTxidString(t *testing.T) {
+	assert.Equal(t, "", GetTxidString(context.Background()))
+	assert.Equal(t, "", GetMtidString(context.Background()))
+
+	ctx, _ := Start(context.Background(), "/log")
+	defer End(ctx, nil)
+	_, traceCtx := GetTraceContext(ctx)
+	if !assert.NotNil(t, traceCtx) {
+		return
+	}
+	assert.Equal(t, hexa32.ToString32(traceCtx.Txid), GetTxidString(ctx))
+	assert.Equal(t, traceCtx.Txid, hexa32.ToLong32(GetTxidString(ctx)))
+
+	assert.Equal(t, "", GetMtidString(ctx))
+	traceCtx.MTid = 1234567890
+	assert.Equal(t, "x14pc0mi", GetMtidString(ctx))
+}
+
+func TestGetTraceId(t *testing.T) {
+	assert.Equal(t, "", GetTraceId(context.Background()))
+
+	ctx, _ := Start(context.Background(), "/log")
+	defer End(ctx, nil)
+	_, traceCtx := GetTraceContext(ctx)
+	if !assert.NotNil(t, traceCtx) {
+		return
+	}
+	tests := []struct {
+		mtid     int64
+		callerId string
+		traceId  string
+	}{
+		{0, "", ""},
+		// mtid 를 W3C trace-id 의 하위 16 자리로 사용
+		{0x1234abcd, "", "0000000000000000000000001234abcd"},
+		{-1, "", "0000000000000000ffffffffffffffff"},
+		// traceparent 로 받은 trace-id
+		{0x1234abcd, "4bf92f3577b34da6a3ce929d0e0e4736", "4bf92f3577b34da6a3ce929d0e0e4736"},
+	}
+	for _, tt := range tests {
+		traceCtx.MTid = tt.mtid
+		traceCtx.MCallerTraceId = tt.callerId
+		assert.Equal(t, tt.traceId, GetTraceId(ctx), "mtid=%d, caller=%s", tt.mtid, tt.callerId)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	assert.Nil(t, LogFields(context.Background()))
+	assert.Equal(t, "", LogMarker(context.Background()))
+
+	ctx, _ := Start(context.Background(), "/log")
+	defer End(ctx, nil)
+	_, traceCtx := GetTraceContext(ctx)
+	if !assert.NotNil(t, traceCtx) {
+		return
+	}
+	txid := GetTxidString(ctx)
+	assert.Equal(t, map[string]string{"@txid": txid}, LogFields(ctx))
+
+	traceCtx.MTid = 0x1234abcd
+	m := LogFields(ctx)
+	assert.Equal(t, map[string]string{"@txid": txid, LOG_MTID_KEY: hexa32.ToString32(0x1234abcd), LOG_TRACEID_KEY: "0000000000000000000000001234abcd"}, m)
+
+	// LogMarker 는 AppLogParser 가 파싱하는 형식
+	marker := LogMarker(ctx)
+	assert.True(t, strings.HasPrefix(marker, "-- {") && strings.HasSuffix(marker, "} --"), marker)
+	parsed := make(map[string]string)
+	assert.Nil(t, json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(marker, "-- "), " --")), &parsed))
+	assert.Equal(t, m, parsed)
+
+	// 로그 수는 전송할 때 기록
+	assert.Equal(t, int32(0), traceCtx.Ctx.LogCount)
+
+	// logsink_txidtag
+	conf := agentconfig.GetConfig()
+	old := conf.TxIdTag
+	conf.TxIdTag = "txid"
+	t.Cleanup(func() { conf.TxIdTag = old })
+	assert.Equal(t, txid, LogFields(ctx)["txid"])
+}
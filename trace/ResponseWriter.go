@@ -0,0 +1,238 @@
+package trace
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// WrapResponseWriter records the status and the body bytes of the response.
+// Writer returns the http.ResponseWriter which implements the same optional interfaces (http.Flusher, http.Hijacker, http.Pusher, io.ReaderFrom) as the underlying one,
+// and Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+type WrapResponseWriter struct {
+	http.ResponseWriter
+	// WriteHeader 를 호출하지 않으면 200
+	Status int
+	Bytes  int64
+
+	wroteHeader bool
+}
+
+func NewWrapResponseWriter(w http.ResponseWriter) *WrapResponseWriter {
+	return &WrapResponseWriter{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (l *WrapResponseWriter) WriteHeader(status int) {
+	// 1xx 는 최종 status 가 아님
+	if !l.wroteHeader && status >= 200 {
+		l.Status = status
+		l.wroteHeader = true
+	}
+	l.ResponseWriter.WriteHeader(status)
+}
+
+func (l *WrapResponseWriter) Write(b []byte) (int, error) {
+	l.wroteHeader = true
+	n, err := l.ResponseWriter.Write(b)
+	l.Bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter. (http.ResponseController)
+func (l *WrapResponseWriter) Unwrap() http.ResponseWriter {
+	return l.ResponseWriter
+}
+
+func (l *WrapResponseWriter) flush() {
+	l.wroteHeader = true
+	l.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (l *WrapResponseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := l.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && !l.wroteHeader {
+		// websocket upgrade
+		l.Status = http.StatusSwitchingProtocols
+		l.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+func (l *WrapResponseWriter) push(target string, opts *http.PushOptions) error {
+	return l.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+func (l *WrapResponseWriter) readFrom(src io.Reader) (int64, error) {
+	l.wroteHeader = true
+	n, err := l.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	l.Bytes += n
+	return n, err
+}
+
+type wrapFlusher struct{ l *WrapResponseWriter }
+
+func (f wrapFlusher) Flush() { f.l.flush() }
+
+type wrapHijacker struct{ l *WrapResponseWriter }
+
+func (h wrapHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return h.l.hijack() }
+
+type wrapPusher struct{ l *WrapResponseWriter }
+
+func (p wrapPusher) Push(target string, opts *http.PushOptions) error { return p.l.push(target, opts) }
+
+type wrapReaderFrom struct{ l *WrapResponseWriter }
+
+func (r wrapReaderFrom) ReadFrom(src io.Reader) (int64, error) { return r.l.readFrom(src) }
+
+// Writer returns the http.ResponseWriter passed to the handler.
+// Only the optional interfaces implemented by the underlying http.ResponseWriter are exposed, so the type assertions of the handler work as without the wrapper.
+func (l *WrapResponseWriter) Writer() http.ResponseWriter {
+	const (
+		flusher = 1 << iota
+		hijacker
+		pusher
+		readerFrom
+	)
+	flag := 0
+	if _, ok := l.ResponseWriter.(http.Flusher); ok {
+		flag |= flusher
+	}
+	if _, ok := l.ResponseWriter.(http.Hijacker); ok {
+		flag |= hijacker
+	}
+	if _, ok := l.ResponseWriter.(http.Pusher); ok {
+		flag |= pusher
+	}
+	if _, ok := l.ResponseWriter.(io.ReaderFrom); ok {
+		flag |= readerFrom
+	}
+
+	f, h, p, r := wrapFlusher{l}, wrapHijacker{l}, wrapPusher{l}, wrapReaderFrom{l}
+	switch flag {
+	case 0:
+		return l
+	case flusher:
+		return struct {
+			*WrapResponseWriter
+			http.Flusher
+		}{l, f}
+	case hijacker:
+		return struct {
+			*WrapResponseWriter
+			http.Hijacker
+		}{l, h}
+	case flusher | hijacker:
+		return struct {
+			*WrapResponseWriter
+			http.Flusher
+			http.Hijacker
+		}{l, f, h}
+	case pusher:
+		return struct {
+			*WrapResponseWriter
+			http.Pusher
+		}{l, p}
+	case flusher | pusher:
+		return struct {
+			*WrapResponseWriter
+			http.Flusher
+			http.Pusher
+		}{l, f, p}
+	case hijacker | pusher:
+		return struct {
+			*WrapResponseWriter
+			http.Hijacker
+			http.Pusher
+		}{l, h, p}
+	case flusher | hijacker | pusher:
+		return struct {
+			*WrapResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{l, f, h, p}
+	case readerFrom:
+		return struct {
+			*WrapResponseWriter
+			io.ReaderFrom
+		}{l, r}
+	case flusher | readerFrom:
+		return struct {
+			*WrapResponseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{l, f, r}
+	case hijacker | readerFrom:
+		return struct {
+			*WrapResponseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{l, h, r}
+	case flusher | hijacker | readerFrom:
+		return struct {
+			*WrapResponseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{l, f, h, r}
+	case pusher | readerFrom:
+		return struct {
+			*WrapResponseWriter
+			http.Pusher
+			io.ReaderFrom
+		}{l, p, r}
+	case flusher | pusher | readerFrom:
+		return struct {
+			*WrapResponseWriter
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{l, f, p, r}
+	case hijacker | pusher | readerFrom:
+		return struct {
+			*WrapResponseWriter
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{l, h, p, r}
+	default:
+		return struct {
+			*WrapResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{l, f, h, p, r}
+	}
+}
+
+// wrapBody records the bytes read from the request body.
+type wrapBody struct {
+	io.ReadCloser
+	Bytes int64
+}
+
+func (b *wrapBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.Bytes += int64(n)
+	return n, err
+}
+
+// Size returns the bytes read from the request body, or contentLength if the body is not read to the end.
+func (b *wrapBody) Size(contentLength int64) int64 {
+	if b == nil || b.Bytes < contentLength {
+		return contentLength
+	}
+	return b.Bytes
+}
+
+func wrapRequestBody(r *http.Request) *wrapBody {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	b := &wrapBody{ReadCloser: r.Body}
+	r.Body = b
+	return b
+}
@@ -0,0 +1,231 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+)
+
+type fakeWriter struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	flushed  bool
+	hijacked bool
+	pushed   string
+}
+
+func newFakeWriter() *fakeWriter {
+	return &fakeWriter{header: http.Header{}}
+}
+
+func (w *fakeWriter) Header() http.Header         { return w.header }
+func (w *fakeWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *fakeWriter) WriteHeader(status int)      { w.status = status }
+
+type fakeFlusher struct{ w *fakeWriter }
+
+func (f fakeFlusher) Flush() { f.w.flushed = true }
+
+type fakeHijacker struct{ w *fakeWriter }
+
+func (h fakeHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.w.hijacked = true
+	c, _ := net.Pipe()
+	return c, nil, nil
+}
+
+type fakePusher struct{ w *fakeWriter }
+
+func (p fakePusher) Push(target string, opts *http.PushOptions) error {
+	p.w.pushed = target
+	return nil
+}
+
+type fakeReaderFrom struct{ w *fakeWriter }
+
+func (r fakeReaderFrom) ReadFrom(src io.Reader) (int64, error) { return r.w.body.ReadFrom(src) }
+
+func TestWrapResponseWriterInterfaces(t *testing.T) {
+	w := newFakeWriter()
+	tests := []struct {
+		name                                  string
+		w                                     http.ResponseWriter
+		flusher, hijacker, pusher, readerFrom bool
+	}{
+		{"none", w, false, false, false, false},
+		{"flusher", struct {
+			*fakeWriter
+			fakeFlusher
+		}{w, fakeFlusher{w}}, true, false, false, false},
+		{"hijacker", struct {
+			*fakeWriter
+			fakeHijacker
+		}{w, fakeHijacker{w}}, false, true, false, false},
+		{"pusher", struct {
+			*fakeWriter
+			fakePusher
+		}{w, fakePusher{w}}, false, false, true, false},
+		{"readerFrom", struct {
+			*fakeWriter
+			fakeReaderFrom
+		}{w, fakeReaderFrom{w}}, false, false, false, true},
+		// net/http 의 HTTP/1.x response
+		{"http1", struct {
+			*fakeWriter
+			fakeFlusher
+			fakeHijacker
+			fakeReaderFrom
+		}{w, fakeFlusher{w}, fakeHijacker{w}, fakeReaderFrom{w}}, true, true, false, true},
+		// net/http 의 HTTP/2 response
+		{"http2", struct {
+			*fakeWriter
+			fakeFlusher
+			fakePusher
+		}{w, fakeFlusher{w}, fakePusher{w}}, true, false, true, false},
+		{"all", struct {
+			*fakeWriter
+			fakeFlusher
+			fakeHijacker
+			fakePusher
+			fakeReaderFrom
+		}{w, fakeFlusher{w}, fakeHijacker{w}, fakePusher{w}, fakeReaderFrom{w}}, true, true, true, true},
+	}
+	for _, tt := range tests {
+		wrw := NewWrapResponseWriter(tt.w)
+		rw := wrw.Writer()
+		_, ok := rw.(http.Flusher)
+		assert.Equal(t, tt.flusher, ok, tt.name)
+		_, ok = rw.(http.Hijacker)
+		assert.Equal(t, tt.hijacker, ok, tt.name)
+		_, ok = rw.(http.Pusher)
+		assert.Equal(t, tt.pusher, ok, tt.name)
+		_, ok = rw.(io.ReaderFrom)
+		assert.Equal(t, tt.readerFrom, ok, tt.name)
+
+		// http.ResponseController
+		u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		assert.True(t, ok, tt.name)
+		assert.Equal(t, tt.w, u.Unwrap(), tt.name)
+	}
+}
+
+func TestWrapResponseWriterCall(t *testing.T) {
+	w := newFakeWriter()
+	wrw := NewWrapResponseWriter(struct {
+		*fakeWriter
+		fakeFlusher
+		fakeHijacker
+		fakePusher
+		fakeReaderFrom
+	}{w, fakeFlusher{w}, fakeHijacker{w}, fakePusher{w}, fakeReaderFrom{w}})
+	rw := wrw.Writer()
+
+	assert.Nil(t, rw.(http.Pusher).Push("/app.js", nil))
+	assert.Equal(t, "/app.js", w.pushed)
+
+	rw.Write([]byte("hello "))
+	n, err := io.Copy(rw, strings.NewReader("world"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+	rw.(http.Flusher).Flush()
+	assert.True(t, w.flushed)
+	assert.Equal(t, "hello world", w.body.String())
+	assert.Equal(t, int64(11), wrw.Bytes)
+	assert.Equal(t, http.StatusOK, wrw.Status)
+
+	// 응답을 시작한 뒤의 hijack 은 status 를 변경하지 않음
+	_, _, err = rw.(http.Hijacker).Hijack()
+	assert.Nil(t, err)
+	assert.True(t, w.hijacked)
+	assert.Equal(t, http.StatusOK, wrw.Status)
+}
+
+func TestWrapResponseWriterStatus(t *testing.T) {
+	// WriteHeader 를 호출하지 않으면 200
+	wrw := NewWrapResponseWriter(newFakeWriter())
+	wrw.Writer().Write([]byte("ok"))
+	assert.Equal(t, http.StatusOK, wrw.Status)
+
+	// 1xx 는 무시하고, 처음 호출한 status 를 기록
+	w := newFakeWriter()
+	wrw = NewWrapResponseWriter(w)
+	rw := wrw.Writer()
+	rw.WriteHeader(http.StatusEarlyHints)
+	rw.WriteHeader(http.StatusCreated)
+	rw.WriteHeader(http.StatusInternalServerError)
+	assert.Equal(t, http.StatusCreated, wrw.Status)
+	assert.Equal(t, http.StatusInternalServerError, w.status)
+
+	// websocket upgrade
+	w = newFakeWriter()
+	wrw = NewWrapResponseWriter(struct {
+		*fakeWriter
+		fakeHijacker
+	}{w, fakeHijacker{w}})
+	_, _, err := wrw.Writer().(http.Hijacker).Hijack()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, wrw.Status)
+}
+
+func TestWrapBodySize(t *testing.T) {
+	var b *wrapBody
+	assert.Equal(t, int64(10), b.Size(10))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+	b = wrapRequestBody(r)
+	assert.NotNil(t, b)
+	// 끝까지 읽지 않으면 Content-Length
+	buf := make([]byte, 4)
+	r.Body.Read(buf)
+	assert.Equal(t, int64(10), b.Size(r.ContentLength))
+	// chunked
+	ioutil.ReadAll(r.Body)
+	assert.Equal(t, int64(10), b.Size(-1))
+
+	r = httptest.NewRequest("GET", "/", nil)
+	assert.Nil(t, wrapRequestBody(r))
+}
+
+func TestFuncResponseWriter(t *testing.T) {
+	meter.GetInstanceMeterTxBytes().GetBucketReset()
+
+	var flusher, hijacker, pusher, readerFrom bool
+	server := httptest.NewServer(http.HandlerFunc(Func(func(w http.ResponseWriter, r *http.Request) {
+		_, flusher = w.(http.Flusher)
+		_, hijacker = w.(http.Hijacker)
+		_, pusher = w.(http.Pusher)
+		_, readerFrom = w.(io.ReaderFrom)
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		io.Copy(w, strings.NewReader("response"))
+	})))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/bytes", "text/plain", strings.NewReader("request"))
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "response", string(body))
+
+	// HTTP/1.1 response
+	assert.True(t, flusher)
+	assert.True(t, hijacker)
+	assert.False(t, pusher)
+	assert.True(t, readerFrom)
+
+	b := meter.GetInstanceMeterTxBytes().GetBucketReset()
+	assert.Equal(t, int32(1), b.Count)
+	assert.Equal(t, int64(len("request")), b.RequestBytes)
+	assert.Equal(t, int64(len("response")), b.ResponseBytes)
+}
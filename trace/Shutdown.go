@@ -0,0 +1,110 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	agentapi "github.com/whatap/go-api/agent/agent/trace/api"
+	logsinkzip "github.com/whatap/go-api/agent/logsink/zip"
+	whatapnet "github.com/whatap/go-api/agent/net"
+)
+
+// ShutdownError describes the data which could not be delivered before the shutdown deadline.
+type ShutdownError struct {
+	// number of active transactions marked as aborted. They are sent as aborted when their owners end them.
+	Aborted int
+	// number of aborted transactions not ended by their owners before the deadline. They are ended and sent by the shutdown.
+	Active int
+	// number of profiles left in the profile queues
+	Profiles int
+	// number of log packs left in the logsink queue
+	Logs int
+	// number of packs left in the tcp send queue
+	Packs int
+	// error of the last tcp flush or the context error
+	Err error
+}
+
+func (this *ShutdownError) Error() string {
+	msg := make([]string, 0)
+	if this.Profiles > 0 {
+		msg = append(msg, fmt.Sprintf("%d profiles", this.Profiles))
+	}
+	if this.Logs > 0 {
+		msg = append(msg, fmt.Sprintf("%d logs", this.Logs))
+	}
+	if this.Packs > 0 {
+		msg = append(msg, fmt.Sprintf("%d packs", this.Packs))
+	}
+	s := "whatap shutdown:"
+	if len(msg) > 0 {
+		s += " " + strings.Join(msg, ", ") + " not delivered"
+	}
+	if this.Aborted > 0 {
+		s += fmt.Sprintf(" (%d active transactions aborted, %d ended by shutdown)", this.Aborted, this.Active)
+	}
+	if this.Err != nil {
+		s += ": " + this.Err.Error()
+	}
+	return s
+}
+
+func (this *ShutdownError) Unwrap() error {
+	return this.Err
+}
+
+// Shutdown calls ShutdownWithContext with the timeout of go.shutdown_timeout (ms).
+func Shutdown() error {
+	conf := agentconfig.GetConfig()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.GoShutdownTimeout)*time.Millisecond)
+	defer cancel()
+	return ShutdownWithContext(ctx)
+}
+
+// ShutdownWithContext stops accepting new transactions, marks the active transactions as aborted
+// and flushes the profiles, logs and packs queued in the agent until ctx is done.
+// The aborted transactions are waited until their owners end them only when the session to the collector is open.
+// The transactions still active after the wait are ended and sent with the abort error before the flush.
+// It returns immediately if the session is not open and nothing is queued.
+// It returns a *ShutdownError if some data could not be delivered.
+func ShutdownWithContext(ctx context.Context) error {
+	conf := agentconfig.GetConfig()
+	agenttrace.SetShutdown(true)
+
+	ret := &ShutdownError{}
+	ret.Aborted = agentapi.AbortActiveTx()
+	if ret.Aborted > 0 && whatapnet.IsOpen() {
+		drainCtx, cancel := drainContext(ctx)
+		agenttrace.WaitActiveTx(drainCtx)
+		cancel()
+	}
+	if ret.Aborted > 0 {
+		ret.Active = agentapi.EndAbortedTx()
+	}
+	ret.Profiles = agenttrace.FlushProfile(ctx)
+	ret.Logs = logsinkzip.Flush(ctx)
+	ret.Packs, ret.Err = whatapnet.Flush(ctx)
+
+	if conf.Debug {
+		log.Println("[WA-TX-09001] Shutdown: aborted=", ret.Aborted, ", active=", ret.Active, ", profiles=", ret.Profiles, ", logs=", ret.Logs, ", packs=", ret.Packs, ", err=", ret.Err)
+	}
+	if ret.Profiles > 0 || ret.Logs > 0 || ret.Packs > 0 || ret.Err != nil {
+		return ret
+	}
+	return nil
+}
+
+// drainContext limits the wait for the aborted transactions to the half of the time left until the deadline of ctx,
+// so that the transactions ended by the shutdown can be flushed in the other half.
+func drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/2)
+}
@@ -0,0 +1,41 @@
+package trace
+
+import (
+	"context"
+)
+
+// parentStepKey is the context key of the step which is the parent of the steps started with the context.
+type parentStepKey struct{}
+
+type parentStep struct {
+	txid  int64
+	index int32
+}
+
+// WithParentStep returns a copy of ctx carrying the step index as the current parent.
+// sql, httpc and method steps started with the returned context are recorded as children of the step.
+func WithParentStep(ctx context.Context, index int32) context.Context {
+	if ctx == nil {
+		return ctx
+	}
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
+		return context.WithValue(ctx, parentStepKey{}, &parentStep{txid: traceCtx.Txid, index: index})
+	}
+	return ctx
+}
+
+// GetParentStep returns the step index of the current parent in ctx. It returns -1 (top level) if ctx has no parent step
+// or the parent belongs to another transaction.
+func GetParentStep(ctx context.Context) int32 {
+	if ctx == nil {
+		return -1
+	}
+	p, ok := ctx.Value(parentStepKey{}).(*parentStep)
+	if !ok || p == nil {
+		return -1
+	}
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil && traceCtx.Txid == p.txid {
+		return p.index
+	}
+	return -1
+}
@@ -14,9 +14,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	whatapboot "github.com/whatap/go-api/agent/agent/boot"
 	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
 	agenttrace "github.com/whatap/go-api/agent/agent/trace"
 	agentapi "github.com/whatap/go-api/agent/agent/trace/api"
 
@@ -67,41 +69,54 @@ var (
 	traceLock          sync.Mutex
 )
 
-type WrapResponseWriter struct {
-	http.ResponseWriter
-	Status int
-}
-
-func (l *WrapResponseWriter) WriteHeader(status int) {
-	l.Status = status
-	l.ResponseWriter.WriteHeader(status)
-}
-
 func Init(m map[string]string) {
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	if m != nil {
 		agentconfig.SetValues(&m)
 	}
 	keygen.AddSeed(os.Getpid())
+	agenttrace.SetShutdown(false)
 	// embeded
 	go whatapboot.Boot()
 }
 
-func Shutdown() {
-	// whatapnet.UdpShutdown()
+// traceCtxKey is the context key of *TraceCtx.
+type traceCtxKey struct{}
+
+// userValueKey is the key of *TraceCtx for the contexts which only look up string keys (ex. *fasthttp.RequestCtx)
+const userValueKey = "whatap.TraceCtx"
+
+// userValueSetter is implemented by the request contexts which keep user values by string key (ex. *fasthttp.RequestCtx)
+type userValueSetter interface {
+	SetUserValue(key string, value interface{})
 }
 
 func GetTraceContext(ctx context.Context) (context.Context, *TraceCtx) {
 	if ctx == nil {
 		return ctx, nil
 	}
-	if v := ctx.Value("whatap"); v != nil {
-		return ctx, v.(*TraceCtx)
+	if v, ok := ctx.Value(traceCtxKey{}).(*TraceCtx); ok {
+		// websocket upgrade 로 종료된 트랜잭션
+		if v.upgraded {
+			return ctx, nil
+		}
+		return ctx, v
+	}
+	// *fasthttp.RequestCtx 는 UserValue 에 저장
+	if _, ok := ctx.(userValueSetter); ok {
+		if v, ok := ctx.Value(userValueKey).(*TraceCtx); ok {
+			if v.upgraded {
+				return ctx, nil
+			}
+			return ctx, v
+		}
 	}
 
-	// TO-DO goroutine id
-	if v := GetGIDTraceCtx(GetGID()); v != nil {
-		return ctx, v
+	// legacy. context 에 없으면 goroutine id 로 조회 (go.use_goroutine_id_enabled, default false)
+	if conf.GoUseGoroutineIDEnabled {
+		if v := GetGIDTraceCtx(GetGID()); v != nil {
+			return ctx, v
+		}
 	}
 
 	return ctx, nil
@@ -120,22 +135,31 @@ func NewTraceContext(ctx context.Context) (context.Context, *TraceCtx) {
 	}
 	var traceCtx *TraceCtx
 	traceCtx = PoolTraceContext()
-	traceCtx.GID = GetGID()
 	traceCtx.Ctx = agenttrace.PoolTraceContext()
 
 	wCtx := traceCtx.Ctx
 	wCtx.Txid = keygen.Next()
 	traceCtx.Txid = wCtx.Txid
+	traceCtx.asyncLock.Lock()
+	traceCtx.asyncId = traceCtx.Txid
+	traceCtx.asyncLock.Unlock()
+
+	if s, ok := ctx.(userValueSetter); ok {
+		s.SetUserValue(userValueKey, traceCtx)
+	}
+	ctx = context.WithValue(ctx, traceCtxKey{}, traceCtx)
 
-	ctx = context.WithValue(ctx, "whatap", traceCtx)
-	// TO-DO goroutine id
-	AddGIDTraceCtx(traceCtx.GID, traceCtx)
+	// legacy. go.use_goroutine_id_enabled
+	if conf.GoUseGoroutineIDEnabled {
+		traceCtx.GID = GetGID()
+		AddGIDTraceCtx(traceCtx.GID, traceCtx)
+	}
 	return ctx, traceCtx
 }
 
 func Start(ctx context.Context, name string) (context.Context, error) {
 	conf := agentconfig.GetConfig()
-	if !conf.Enabled {
+	if !conf.Enabled || agenttrace.IsShutdown() {
 		return ctx, nil
 	}
 
@@ -153,9 +177,34 @@ func Start(ctx context.Context, name string) (context.Context, error) {
 	return ctx, nil
 }
 
+// StartWithHeader starts the transaction with the multi trace headers of the caller (traceparent, x-wtap-mst ...).
+// ex) the headers of the message queue
+func StartWithHeader(ctx context.Context, name string, header http.Header) (context.Context, error) {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled || agenttrace.IsShutdown() {
+		return ctx, nil
+	}
+
+	ctx, traceCtx := NewTraceContext(ctx)
+	traceCtx.Name = name
+	traceCtx.StartTime = dateutil.SystemNow()
+	// StartTx 전에 caller 의 mtid 를 적용
+	UpdateMtrace(traceCtx, header)
+
+	wCtx := traceCtx.Ctx
+	wCtx.StartTime = traceCtx.StartTime
+	wCtx.ServiceURL = urlutil.NewURL(name)
+	if conf.Debug {
+		log.Println("[WA-TX-02002] StartWithHeader: ", traceCtx.Txid, ", ", traceCtx.Name, ", mtid: ", traceCtx.MTid)
+	}
+	agentapi.StartTx(wCtx)
+
+	return ctx, nil
+}
+
 func StartWithRequest(r *http.Request) (context.Context, error) {
 	conf := agentconfig.GetConfig()
-	if !conf.Enabled {
+	if !conf.Enabled || agenttrace.IsShutdown() {
 		return r.Context(), nil
 	}
 
@@ -218,6 +267,24 @@ func StartWithContext(ctx context.Context, name string) (context.Context, error)
 	return ctx, nil
 }
 
+// UpdateServiceName names the transaction after the route template which is matched by the router. ex) /users/{id}
+// The url of the request is kept as the OriginURL message.
+func UpdateServiceName(ctx context.Context, name string) error {
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled || name == "" {
+		return nil
+	}
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
+		if conf.Debug {
+			log.Println("[WA-TX-03003] UpdateServiceName: ", traceCtx.Txid, ", ", traceCtx.Name, " -> ", name)
+		}
+		traceCtx.Name = name
+		agentapi.UpdateServiceName(traceCtx.Ctx, name)
+		return nil
+	}
+	return fmt.Errorf("Not found Txid ")
+}
+
 func SetHeader(ctx context.Context, m map[string][]string) {
 	conf := agentconfig.GetConfig()
 	if !conf.ProfileHttpHeaderEnabled {
@@ -349,33 +416,12 @@ func End(ctx context.Context, err error) error {
 	if !conf.Enabled {
 		return nil
 	}
-	Error(ctx, err)
+	// Fork 로 시작된 context
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil && traceCtx.asyncParent != nil {
+		return EndFork(ctx, err)
+	}
 	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
-		wCtx := traceCtx.Ctx
-		wCtx.Mtid = traceCtx.MTid
-		wCtx.Mdepth = traceCtx.MDepth
-		wCtx.McallerTxid = traceCtx.MCallerTxid
-		wCtx.McallerPoidKey = traceCtx.MCallerPoidKey
-		wCtx.McallerSpec = traceCtx.MCallerSpec
-		wCtx.McallerUrl = traceCtx.MCallerUrl
-		wCtx.McallerStepId = traceCtx.MCallerStepId
-		wCtx.Status = traceCtx.Status
-
-		if conf.Debug {
-			log.Println("[WA-TX-05001] txid: ", traceCtx.Txid, ", uri: ", traceCtx.Name,
-				"\n time: ", (dateutil.SystemNow() - traceCtx.StartTime), "ms ", "\n error: ", err)
-		}
-
-		// tracecontext traceparent
-		wCtx.SetExtraFieldString("x-trace-id", traceCtx.MCallerTraceId)
-		if wCtx.McallerTxid == 0 && wCtx.McallerStepId != 0 {
-			wCtx.SetExtraField("x-parent-id", langvalue.NewDecimalValue(wCtx.McallerStepId))
-		}
-
-		agentapi.EndTx(wCtx)
-		// TO-DO goroutine id
-		RemoveGIDTraceCtx(traceCtx.GID)
-		CloseTraceContext(traceCtx)
+		end(ctx, traceCtx, err, true)
 		return nil
 	}
 	if conf.Debug {
@@ -384,6 +430,58 @@ func End(ctx context.Context, err error) error {
 	return fmt.Errorf("Not found Txid ")
 }
 
+// end ends the transaction of traceCtx. If close is false, traceCtx is not returned to the pool.
+func end(ctx context.Context, traceCtx *TraceCtx, err error, close bool) {
+	conf := agentconfig.GetConfig()
+	Error(ctx, err)
+	// End 이후에 종료되는 async 작업은 child 트랜잭션으로 전송
+	traceCtx.asyncLock.Lock()
+	traceCtx.asyncId = 0
+	traceCtx.asyncLock.Unlock()
+
+	wCtx := traceCtx.Ctx
+	wCtx.Mtid = traceCtx.MTid
+	wCtx.Mdepth = traceCtx.MDepth
+	wCtx.McallerTxid = traceCtx.MCallerTxid
+	wCtx.McallerPoidKey = traceCtx.MCallerPoidKey
+	wCtx.McallerSpec = traceCtx.MCallerSpec
+	wCtx.McallerUrl = traceCtx.MCallerUrl
+	wCtx.McallerStepId = traceCtx.MCallerStepId
+	wCtx.Status = traceCtx.Status
+	if traceCtx.RequestBytes > 0 {
+		wCtx.SetExtraField("RequestBytes", langvalue.NewDecimalValue(traceCtx.RequestBytes))
+	}
+	if traceCtx.ResponseBytes > 0 {
+		wCtx.SetExtraField("ResponseBytes", langvalue.NewDecimalValue(traceCtx.ResponseBytes))
+	}
+	if traceCtx.RequestBytes > 0 || traceCtx.ResponseBytes > 0 {
+		meter.GetInstanceMeterTxBytes().Add(traceCtx.RequestBytes, traceCtx.ResponseBytes)
+	}
+	// 트랜잭션의 txid 가 기록된 로그
+	if n := atomic.LoadInt32(&wCtx.LogCount); n > 0 {
+		wCtx.SetExtraField("LogCount", langvalue.NewDecimalValue(int64(n)))
+	}
+
+	if conf.Debug {
+		log.Println("[WA-TX-05001] txid: ", traceCtx.Txid, ", uri: ", traceCtx.Name,
+			"\n time: ", (dateutil.SystemNow() - traceCtx.StartTime), "ms ", "\n error: ", err)
+	}
+
+	// tracecontext traceparent
+	wCtx.SetExtraFieldString("x-trace-id", traceCtx.MCallerTraceId)
+	if wCtx.McallerTxid == 0 && wCtx.McallerStepId != 0 {
+		wCtx.SetExtraField("x-parent-id", langvalue.NewDecimalValue(wCtx.McallerStepId))
+	}
+
+	agentapi.EndTx(wCtx)
+	if traceCtx.GID != 0 {
+		RemoveGIDTraceCtx(traceCtx.GID)
+	}
+	if close {
+		CloseTraceContext(traceCtx)
+	}
+}
+
 func UpdateMtraceWithContext(ctx context.Context, header http.Header) {
 	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
 		UpdateMtrace(traceCtx, header)
@@ -395,17 +493,18 @@ func GetMTrace(ctx context.Context) http.Header {
 	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
 		// create distribute trace header
 		traceCtx.MStepId = keygen.Next()
-		if traceCtx.MCallerTraceId != "" {
-			traceCtx.TraceMtraceTraceparentValue = fmt.Sprintf("00-%s-%016x-01", traceCtx.MCallerTraceId, uint64(traceCtx.MStepId))
-		} else {
-			traceCtx.TraceMtraceTraceparentValue = fmt.Sprintf("00-0000000000000000%016x-%016x-01", uint64(traceCtx.MTid), uint64(traceCtx.MStepId))
-		}
-		traceCtx.TraceMtraceCallerValue = fmt.Sprintf("%s,%s,%s,%s", hexa32.ToString32(traceCtx.MTid), strconv.Itoa(int(traceCtx.MDepth)+1), hexa32.ToString32(traceCtx.Txid), hexa32.ToString32(traceCtx.MStepId))
+		traceCtx.TraceMtraceTraceparentValue = traceparentValue(traceCtx)
+		traceCtx.TraceMtraceCallerValue = mtraceCallerValue(traceCtx)
+		traceCtx.TraceMtraceTracestateValue = traceStateValue(traceCtx)
 
 		rt.Set(conf.TraceMtraceTraceparentKey, traceCtx.TraceMtraceTraceparentValue)
+		rt.Set(conf.TraceMtraceTracestateKey, traceCtx.TraceMtraceTracestateValue)
 		rt.Set(conf.TraceMtraceCallerKey, traceCtx.TraceMtraceCallerValue)
 		rt.Set(conf.TraceMtracePoidKey, traceCtx.TraceMtracePoidValue)
 		rt.Set(conf.TraceMtraceSpecKey1, traceCtx.TraceMtraceSpecValue)
+		if v := baggageValue(traceCtx); v != "" {
+			rt.Set(conf.TraceMtraceBaggageKey, v)
+		}
 
 		// 2023.11.07 deprcated
 		// Mcallee
@@ -417,6 +516,33 @@ func GetMTrace(ctx context.Context) http.Header {
 
 	return rt
 }
+
+// traceparentValue returns the W3C traceparent of the next call. trace-flags is the sampling decision of traceCtx
+func traceparentValue(traceCtx *TraceCtx) string {
+	// sampling 을 사용하지 않아도 caller 의 sampled flag 는 그대로 전달
+	flags := "01"
+	if traceCtx.Ctx != nil && (traceCtx.Ctx.NotSampled || traceCtx.Ctx.McallerSampled == agenttrace.SAMPLED_NO) {
+		flags = "00"
+	}
+	if traceCtx.MCallerTraceId != "" {
+		return fmt.Sprintf("00-%s-%016x-%s", traceCtx.MCallerTraceId, uint64(traceCtx.MStepId), flags)
+	}
+	return fmt.Sprintf("00-0000000000000000%016x-%016x-%s", uint64(traceCtx.MTid), uint64(traceCtx.MStepId), flags)
+}
+
+// mtraceCallerValue returns x-wtap-mst of the next call. mtid,depth,txid,stepid[,sampled]
+func mtraceCallerValue(traceCtx *TraceCtx) string {
+	v := fmt.Sprintf("%s,%s,%s,%s", hexa32.ToString32(traceCtx.MTid), strconv.Itoa(int(traceCtx.MDepth)+1), hexa32.ToString32(traceCtx.Txid), hexa32.ToString32(traceCtx.MStepId))
+	if conf.SamplingEnabled {
+		if traceCtx.Ctx != nil && traceCtx.Ctx.NotSampled {
+			v += ",0"
+		} else {
+			v += ",1"
+		}
+	}
+	return v
+}
+
 func UpdateMtrace(traceCtx *TraceCtx, header http.Header) {
 	conf := agentconfig.GetConfig()
 	if !conf.MtraceEnabled {
@@ -432,6 +558,8 @@ func UpdateMtrace(traceCtx *TraceCtx, header http.Header) {
 
 	isTraceparent := false
 	useWhatap := true
+	sampled := agenttrace.SAMPLED_NONE
+	whatapState := ""
 	// W3C Trace Context traceparent
 	if val := h.Get(conf.TraceMtraceTraceparentKey); val != "" {
 		isTraceparent = true
@@ -450,14 +578,34 @@ func UpdateMtrace(traceCtx *TraceCtx, header http.Header) {
 			} else {
 				traceCtx.MCallerStepId = 0
 			}
+			// trace-flags sampled
+			if val, err := strconv.ParseUint(arr[3], 16, 8); err == nil {
+				if val&0x01 == 0x01 {
+					sampled = agenttrace.SAMPLED_YES
+				} else {
+					sampled = agenttrace.SAMPLED_NO
+				}
+			}
 
 			if conf.Debug {
 				log.Println("[WA-TX-08001] update mtrace traceparent ", v, ", mtid=", traceCtx.MTid, ", mcaller_step=", traceCtx.MCallerStepId)
 			}
+
+			// W3C tracestate. traceparent 가 있을 때만 사용
+			if vals := h.Values(conf.TraceMtraceTracestateKey); len(vals) > 0 {
+				whatapState, traceCtx.TraceState = parseTraceState(strings.Join(vals, ","))
+				if conf.Debug {
+					log.Println("[WA-TX-08005] update mtrace tracestate ", vals, ", whatap=", whatapState)
+				}
+			}
 		}
 	}
-	// x-wtap-mst
-	if val := h.Get(conf.TraceMtraceCallerKey); val != "" {
+	// x-wtap-mst. 중간에 whatap 헤더를 전달하지 않는 서비스가 있으면 tracestate 의 whatap 값을 사용
+	mst := h.Get(conf.TraceMtraceCallerKey)
+	if mst == "" && whatapState != "" {
+		mst = strings.ReplaceAll(whatapState, ";", ",")
+	}
+	if val := mst; val != "" {
 		v := strings.TrimSpace(val)
 		arr := stringutil.Split(v, ",")
 		var mtid, stepId, mcallerTxid int64
@@ -471,6 +619,14 @@ func UpdateMtrace(traceCtx *TraceCtx, header http.Header) {
 		if len(arr) >= 4 {
 			stepId = hexa32.ToLong32(arr[3])
 		}
+		// sampled flag. traceparent 가 있으면 traceparent 를 사용
+		if len(arr) >= 5 && !isTraceparent {
+			if arr[4] == "0" {
+				sampled = agenttrace.SAMPLED_NO
+			} else {
+				sampled = agenttrace.SAMPLED_YES
+			}
+		}
 
 		// traceparent , whatap header 모두 있을 때, 가능한 caller txid를 설정.
 		// gateway에서 받은 header를 그대로 전달해 줄 경우 callertxid가 다르게 설정될 수 있음.
@@ -480,7 +636,7 @@ func UpdateMtrace(traceCtx *TraceCtx, header http.Header) {
 			} else {
 				// parent id != whatap.stepid . don't use whatap header
 				if conf.Debug {
-					log.Printf("[WA-TX-08002] stepid(%s) is not equal traceparent stepid(%s), mtid=(%d), traceparent mtid=(%d)", traceCtx.MCallerStepId, stepId, mtid, traceCtx.MTid)
+					log.Printf("[WA-TX-08002] stepid(%d) is not equal traceparent stepid(%d), mtid=(%d), traceparent mtid=(%d)", traceCtx.MCallerStepId, stepId, mtid, traceCtx.MTid)
 				}
 				useWhatap = false
 			}
@@ -537,15 +693,23 @@ func UpdateMtrace(traceCtx *TraceCtx, header http.Header) {
 		}
 	}
 
-	traceCtx.MStepId = keygen.Next()
-	// traceCtx.TraceMtraceTraceparentValue = fmt.Sprintf()
+	// W3C baggage
+	if vals := h.Values(conf.TraceMtraceBaggageKey); len(vals) > 0 {
+		parseBaggage(traceCtx, strings.Join(vals, ","))
+	}
 
-	if traceCtx.MCallerTraceId != "" {
-		traceCtx.TraceMtraceTraceparentValue = fmt.Sprintf("00-%s-%016x-01", traceCtx.MCallerTraceId, uint64(traceCtx.MStepId))
-	} else {
-		traceCtx.TraceMtraceTraceparentValue = fmt.Sprintf("00-0000000000000000%016x-%016x-01", uint64(traceCtx.MTid), uint64(traceCtx.MStepId))
+	// sampled flag
+	if sampled != agenttrace.SAMPLED_NONE && traceCtx.Ctx != nil {
+		traceCtx.Ctx.McallerSampled = sampled
+		if conf.SamplingEnabled {
+			traceCtx.Ctx.NotSampled = sampled == agenttrace.SAMPLED_NO
+		}
 	}
-	traceCtx.TraceMtraceCallerValue = fmt.Sprintf("%s,%s,%s,%s", hexa32.ToString32(traceCtx.MTid), strconv.Itoa(int(traceCtx.MDepth)+1), hexa32.ToString32(traceCtx.Txid), hexa32.ToString32(traceCtx.MStepId))
+
+	traceCtx.MStepId = keygen.Next()
+	traceCtx.TraceMtraceTraceparentValue = traceparentValue(traceCtx)
+	traceCtx.TraceMtraceCallerValue = mtraceCallerValue(traceCtx)
+	traceCtx.TraceMtraceTracestateValue = traceStateValue(traceCtx)
 	traceCtx.TraceMtraceSpecValue = fmt.Sprintf("%s, %s", conf.MtraceSpec, strconv.Itoa(int(hash.HashStr(traceCtx.Name))))
 	traceCtx.TraceMtracePoidValue = fmt.Sprintf("%s, %s, %s", hexa32.ToString32(conf.PCODE), hexa32.ToString32(int64(conf.OKIND)), hexa32.ToString32(conf.OID))
 }
@@ -563,9 +727,10 @@ func Func(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWr
 			handler(w, r)
 			return
 		}
-		wrw := &WrapResponseWriter{ResponseWriter: w}
+		wrw := NewWrapResponseWriter(w)
 		ctx, _ := StartWithRequest(r)
 		wRequest := r.WithContext(ctx)
+		wBody := wrapRequestBody(wRequest)
 		defer func() {
 			x := recover()
 			var err error = nil
@@ -577,6 +742,8 @@ func Func(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWr
 			status := wrw.Status
 			if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
 				traceCtx.Status = int32(status)
+				traceCtx.RequestBytes = wBody.Size(r.ContentLength)
+				traceCtx.ResponseBytes = wrw.Bytes
 			}
 			if status >= 400 {
 				err = fmt.Errorf("Status %d:%s", status, http.StatusText(status))
@@ -601,7 +768,7 @@ func Func(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWr
 				}
 			}
 		}()
-		handler(wrw, wRequest)
+		handler(wrw.Writer(), wRequest)
 
 	}
 }
@@ -36,9 +36,7 @@ func GetGIDTraceCtx(GID int64) *TraceCtx {
 	return nil
 }
 
+// RemoveGIDTraceCtx removes the entry even if go.use_goroutine_id_enabled is turned off after it is added.
 func RemoveGIDTraceCtx(GID int64) {
-	if !conf.GoUseGoroutineIDEnabled {
-		return
-	}
 	ctxTable.Remove(GID)
 }
@@ -7,6 +7,7 @@ import (
 	// "github.com/whatap/golib/io"
 	// "github.com/whatap/golib/lang/pack/udp"
 	// whatapnet "github.com/whatap/golib/net"
+	"github.com/whatap/golib/lang/step"
 	"github.com/whatap/golib/util/dateutil"
 
 	agenttrace "github.com/whatap/go-api/agent/agent/trace"
@@ -49,6 +50,9 @@ type TraceCtx struct {
 	HttpMethod       string
 	IsStaticContents string
 	Status           int32
+	// http request, response body bytes
+	RequestBytes  int64
+	ResponseBytes int64
 
 	MTid        int64
 	MDepth      int32
@@ -70,6 +74,26 @@ type TraceCtx struct {
 	TraceMtraceSpecValue        string
 	TraceMtraceMcallee          int64
 	TraceMtraceTraceparentValue string
+	TraceMtraceTracestateValue  string
+
+	// W3C tracestate list-members of other vendors
+	TraceState []string
+	// W3C baggage
+	Baggage     map[string]string
+	baggageLock sync.Mutex
+
+	// id of the active transaction or async work. 0 after End
+	asyncId   int64
+	asyncLock sync.Mutex
+	// async work started by Fork
+	asyncParent    *TraceCtx
+	asyncParentId  int64
+	asyncName      string
+	asyncStartTime int64
+	asyncStep      *step.MethodStepX
+
+	// UpgradeWebSocket 으로 종료된 트랜잭션
+	upgraded bool
 }
 
 var ctxPool = sync.Pool{
@@ -100,6 +124,7 @@ func (this *TraceCtx) GetElapsedTime() int {
 
 func (this *TraceCtx) Clear() {
 	this.Txid = 0
+	this.GID = 0
 	this.Name = ""
 	this.Ctx = nil
 
@@ -115,6 +140,8 @@ func (this *TraceCtx) Clear() {
 	this.HttpMethod = ""
 	this.IsStaticContents = ""
 	this.Status = 0
+	this.RequestBytes = 0
+	this.ResponseBytes = 0
 
 	this.MTid = 0
 	this.MDepth = 0
@@ -133,4 +160,20 @@ func (this *TraceCtx) Clear() {
 	this.TraceMtraceSpecValue = ""
 	this.TraceMtraceMcallee = 0
 	this.TraceMtraceTraceparentValue = ""
+	this.TraceMtraceTracestateValue = ""
+
+	this.TraceState = nil
+	this.baggageLock.Lock()
+	this.Baggage = nil
+	this.baggageLock.Unlock()
+
+	this.asyncLock.Lock()
+	this.asyncId = 0
+	this.asyncLock.Unlock()
+	this.asyncParent = nil
+	this.asyncParentId = 0
+	this.asyncName = ""
+	this.asyncStartTime = 0
+	this.asyncStep = nil
+	this.upgraded = false
 }
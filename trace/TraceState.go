@@ -0,0 +1,60 @@
+package trace
+
+import (
+	"strings"
+)
+
+const (
+	// whatap list-member key of W3C tracestate
+	TRACESTATE_WHATAP_KEY = "whatap"
+	// max list-members of W3C tracestate
+	TRACESTATE_MAX_MEMBERS = 32
+)
+
+// parseTraceState splits the W3C tracestate header into list-members.
+// The whatap member is returned separately and the others keep their order.
+func parseTraceState(v string) (whatap string, members []string) {
+	members = make([]string, 0)
+	for _, it := range strings.Split(v, ",") {
+		it = strings.TrimSpace(it)
+		pos := strings.Index(it, "=")
+		if pos <= 0 {
+			continue
+		}
+		key := it[:pos]
+		if key == TRACESTATE_WHATAP_KEY {
+			whatap = it[pos+1:]
+			continue
+		}
+		// 같은 key 가 중복되면 앞의 것만 사용
+		dup := false
+		for _, m := range members {
+			if strings.HasPrefix(m, key+"=") {
+				dup = true
+				break
+			}
+		}
+		if !dup && len(members) < TRACESTATE_MAX_MEMBERS {
+			members = append(members, it)
+		}
+	}
+	return
+}
+
+// traceStateValue returns the tracestate of the next call.
+// The whatap member is added to the left and the members of other vendors are kept.
+func traceStateValue(traceCtx *TraceCtx) string {
+	// x-wtap-mst 와 같은 값. tracestate value 에는 ',' 를 사용할 수 없음
+	whatap := strings.ReplaceAll(traceCtx.TraceMtraceCallerValue, ",", ";")
+	members := make([]string, 0, len(traceCtx.TraceState)+1)
+	if whatap != "" {
+		members = append(members, TRACESTATE_WHATAP_KEY+"="+whatap)
+	}
+	for _, it := range traceCtx.TraceState {
+		if len(members) >= TRACESTATE_MAX_MEMBERS {
+			break
+		}
+		members = append(members, it)
+	}
+	return strings.Join(members, ",")
+}
@@ -0,0 +1,70 @@
+package trace
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceState(t *testing.T) {
+	tests := []struct {
+		header  string
+		whatap  string
+		members []string
+	}{
+		{"", "", []string{}},
+		{"whatap=abc;2;def;ghi", "abc;2;def;ghi", []string{}},
+		{"rojo=00f067aa0ba902b7, whatap=abc ,congo=t61rcWkgMzE", "abc", []string{"rojo=00f067aa0ba902b7", "congo=t61rcWkgMzE"}},
+		// 같은 key 는 앞의 것만 사용
+		{"rojo=1,congo=2,rojo=3", "", []string{"rojo=1", "congo=2"}},
+		// key 또는 '=' 가 없는 member 는 제외
+		{"=1,rojo,,congo=2", "", []string{"congo=2"}},
+	}
+	for _, tt := range tests {
+		whatap, members := parseTraceState(tt.header)
+		assert.Equal(t, tt.whatap, whatap, tt.header)
+		assert.Equal(t, tt.members, members, tt.header)
+	}
+}
+
+func TestParseTraceStateMaxMembers(t *testing.T) {
+	arr := make([]string, 0)
+	for i := 0; i < TRACESTATE_MAX_MEMBERS+5; i++ {
+		arr = append(arr, fmt.Sprintf("v%d=%d", i, i))
+	}
+	_, members := parseTraceState(strings.Join(arr, ","))
+	assert.Equal(t, TRACESTATE_MAX_MEMBERS, len(members))
+	assert.Equal(t, arr[:TRACESTATE_MAX_MEMBERS], members)
+}
+
+func TestTraceStateValue(t *testing.T) {
+	traceCtx := &TraceCtx{}
+	_, traceCtx.TraceState = parseTraceState("whatap=old,rojo=1,congo=2")
+	traceCtx.TraceMtraceCallerValue = "abc,2,def,ghi"
+
+	// whatap member 는 맨 앞에 새 값으로 추가하고 ',' 는 ';' 로 변경
+	v := traceStateValue(traceCtx)
+	assert.Equal(t, "whatap=abc;2;def;ghi,rojo=1,congo=2", v)
+
+	// round-trip
+	whatap, members := parseTraceState(v)
+	assert.Equal(t, "abc;2;def;ghi", whatap)
+	assert.Equal(t, traceCtx.TraceState, members)
+
+	// 다른 vendor 의 member 를 포함해 최대 32 개
+	arr := make([]string, 0)
+	for i := 0; i < TRACESTATE_MAX_MEMBERS; i++ {
+		arr = append(arr, fmt.Sprintf("v%d=%d", i, i))
+	}
+	_, traceCtx.TraceState = parseTraceState(strings.Join(arr, ","))
+	members = strings.Split(traceStateValue(traceCtx), ",")
+	assert.Equal(t, TRACESTATE_MAX_MEMBERS, len(members))
+	assert.Equal(t, "whatap=abc;2;def;ghi", members[0])
+	assert.Equal(t, arr[TRACESTATE_MAX_MEMBERS-2], members[TRACESTATE_MAX_MEMBERS-1])
+
+	traceCtx.TraceMtraceCallerValue = ""
+	traceCtx.TraceState = nil
+	assert.Equal(t, "", traceStateValue(traceCtx))
+}
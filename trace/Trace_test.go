@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// userValueCtx is the context which keeps user values by string key like *fasthttp.RequestCtx
+type userValueCtx struct {
+	context.Context
+	values map[string]interface{}
+}
+
+func (this *userValueCtx) SetUserValue(key string, value interface{}) {
+	this.values[key] = value
+}
+
+func (this *userValueCtx) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if v, ok := this.values[k]; ok {
+			return v
+		}
+	}
+	return this.Context.Value(key)
+}
+
+func closeTestTraceContext(traceCtx *TraceCtx) {
+	if traceCtx.GID != 0 {
+		RemoveGIDTraceCtx(traceCtx.GID)
+	}
+	CloseTraceContext(traceCtx)
+}
+
+func setGoroutineID(t *testing.T, b bool) {
+	old := conf.GoUseGoroutineIDEnabled
+	conf.GoUseGoroutineIDEnabled = b
+	t.Cleanup(func() { conf.GoUseGoroutineIDEnabled = old })
+}
+
+func TestGetTraceContext(t *testing.T) {
+	setGoroutineID(t, false)
+
+	ctx, traceCtx := NewTraceContext(context.Background())
+	defer closeTestTraceContext(traceCtx)
+	assert.NotNil(t, traceCtx)
+	assert.NotEqual(t, int64(0), traceCtx.Txid)
+	assert.Equal(t, int64(0), traceCtx.GID)
+
+	_, v := GetTraceContext(ctx)
+	assert.Equal(t, traceCtx, v)
+
+	// child context
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+	_, v = GetTraceContext(child)
+	assert.Equal(t, traceCtx, v)
+
+	_, v = GetTraceContext(context.Background())
+	assert.Nil(t, v)
+	_, v = GetTraceContext(nil)
+	assert.Nil(t, v)
+}
+
+func TestGetTraceContextUserValue(t *testing.T) {
+	setGoroutineID(t, false)
+
+	uv := &userValueCtx{Context: context.Background(), values: map[string]interface{}{}}
+	_, traceCtx := NewTraceContext(uv)
+	defer closeTestTraceContext(traceCtx)
+	assert.Equal(t, traceCtx, uv.values[userValueKey])
+
+	// fasthttp handler 는 *fasthttp.RequestCtx 를 그대로 전달
+	_, v := GetTraceContext(uv)
+	assert.Equal(t, traceCtx, v)
+
+	// UserValue 를 지원하지 않는 context 는 string key 를 조회하지 않음
+	ctx := context.WithValue(context.Background(), userValueKey, traceCtx)
+	_, v = GetTraceContext(ctx)
+	assert.Nil(t, v)
+}
+
+func TestGetTraceContextGoroutineID(t *testing.T) {
+	setGoroutineID(t, true)
+
+	_, traceCtx := NewTraceContext(context.Background())
+	assert.NotEqual(t, int64(0), traceCtx.GID)
+
+	// context 가 전달되지 않아도 같은 goroutine 이면 조회
+	_, v := GetTraceContext(context.Background())
+	assert.Equal(t, traceCtx, v)
+
+	done := make(chan *TraceCtx)
+	go func() {
+		_, v := GetTraceContext(context.Background())
+		done <- v
+	}()
+	assert.Nil(t, <-done)
+
+	conf.GoUseGoroutineIDEnabled = false
+	_, v = GetTraceContext(context.Background())
+	assert.Nil(t, v)
+
+	closeTestTraceContext(traceCtx)
+	conf.GoUseGoroutineIDEnabled = true
+	_, v = GetTraceContext(context.Background())
+	assert.Nil(t, v)
+}
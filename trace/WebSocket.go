@@ -0,0 +1,188 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+
+	agentconfig "github.com/whatap/go-api/agent/agent/config"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+	agenttrace "github.com/whatap/go-api/agent/agent/trace"
+	agentapi "github.com/whatap/go-api/agent/agent/trace/api"
+
+	"github.com/whatap/golib/io"
+	"github.com/whatap/golib/util/dateutil"
+	"github.com/whatap/golib/util/hash"
+	"github.com/whatap/golib/util/iputil"
+	"github.com/whatap/golib/util/keygen"
+	"github.com/whatap/golib/util/urlutil"
+)
+
+// MessageConn is the connection of gorilla/websocket style libraries. ex) *websocket.Conn
+type MessageConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+}
+
+// WebSocket traces the upgraded (long-lived) connection. The upgrade is recorded as a short transaction and
+// each inbound message is recorded as its own transaction linked by mtid to the upgrade transaction.
+type WebSocket struct {
+	// service name of the message transactions. default is the path of the upgrade request
+	Name string
+
+	host       string
+	remoteIp   int32
+	wClientId  int64
+	userAgent  string
+	mtid       int64
+	mdepth     int32
+	callerTxid int64
+
+	MessageIn  int64
+	MessageOut int64
+	BytesIn    int64
+	BytesOut   int64
+
+	closed int32
+}
+
+// UpgradeWebSocket ends the transaction of the upgrade request in ctx (trace.Func, whatapmux, whatapchi ...) and returns
+// the WebSocket which traces the messages of the connection. If ctx has no transaction, the upgrade transaction is started with r.
+//
+//	conn, err := upgrader.Upgrade(w, r, nil)
+//	ws, _ := trace.UpgradeWebSocket(r.Context(), r)
+//	defer ws.Close(nil)
+//	ws.Serve(context.Background(), conn, func(ctx context.Context, messageType int, p []byte) error { ... })
+func UpgradeWebSocket(ctx context.Context, r *http.Request) (*WebSocket, error) {
+	conf := agentconfig.GetConfig()
+	ws := &WebSocket{Name: r.URL.Path, host: r.Host, userAgent: r.UserAgent()}
+	ipaddr := GetRemoteIP(r.RemoteAddr, r.Header)
+	ws.remoteIp = io.ToInt(iputil.ToBytes(ipaddr), 0)
+	ws.wClientId = int64(hash.HashStr(GetClientId(r, ipaddr)))
+	meter.GetInstanceMeterWebSocket().Open()
+	if !conf.Enabled {
+		return ws, nil
+	}
+
+	_, traceCtx := GetTraceContext(ctx)
+	if traceCtx == nil {
+		ctx, _ = StartWithRequest(r)
+		if _, traceCtx = GetTraceContext(ctx); traceCtx == nil {
+			return ws, fmt.Errorf("Not found Txid ")
+		}
+	}
+	// message 트랜잭션을 upgrade 트랜잭션과 mtid 로 연결
+	if traceCtx.MTid == 0 {
+		traceCtx.MTid = keygen.Next()
+	}
+	ws.mtid = traceCtx.MTid
+	ws.mdepth = traceCtx.MDepth
+	ws.callerTxid = traceCtx.Txid
+	traceCtx.Status = http.StatusSwitchingProtocols
+
+	if conf.Debug {
+		log.Println("[WA-TX-11001] UpgradeWebSocket txid: ", traceCtx.Txid, ", uri: ", traceCtx.Name, ", mtid: ", traceCtx.MTid)
+	}
+	// 이후 ctx 의 End 등은 무시. traceCtx 는 ctx 에서 참조하므로 pool 에 반환하지 않음
+	// end 에서 트랜잭션을 전송하기 전에 표시
+	traceCtx.upgraded = true
+	end(ctx, traceCtx, nil, false)
+	traceCtx.Ctx = nil
+	return ws, nil
+}
+
+// StartMessage starts the transaction of the inbound message of size bytes.
+func (ws *WebSocket) StartMessage(ctx context.Context, size int) (context.Context, error) {
+	atomic.AddInt64(&ws.MessageIn, 1)
+	atomic.AddInt64(&ws.BytesIn, int64(size))
+	meter.GetInstanceMeterWebSocket().AddMessageIn(int64(size))
+
+	conf := agentconfig.GetConfig()
+	if !conf.Enabled || agenttrace.IsShutdown() {
+		return ctx, nil
+	}
+	ctx, traceCtx := NewTraceContext(ctx)
+	traceCtx.Name = ws.Name
+	traceCtx.StartTime = dateutil.SystemNow()
+	traceCtx.MTid = ws.mtid
+	traceCtx.MDepth = ws.mdepth + 1
+	traceCtx.MCallerTxid = ws.callerTxid
+	traceCtx.RequestBytes = int64(size)
+
+	wCtx := traceCtx.Ctx
+	wCtx.StartTime = traceCtx.StartTime
+	wCtx.ServiceURL = urlutil.NewURL(filepath.Join(ws.host, "/", ws.Name))
+	wCtx.RemoteIp = ws.remoteIp
+	wCtx.WClientId = ws.wClientId
+	wCtx.UserAgentString = ws.userAgent
+	if conf.Debug {
+		log.Println("[WA-TX-11002] StartMessage txid: ", traceCtx.Txid, ", uri: ", traceCtx.Name, ", mtid: ", traceCtx.MTid, ", size: ", size)
+	}
+	agentapi.StartTx(wCtx)
+	return ctx, nil
+}
+
+// EndMessage ends the transaction of the inbound message.
+func (ws *WebSocket) EndMessage(ctx context.Context, err error) error {
+	return End(ctx, err)
+}
+
+// AddMessageOut counts the outbound message of size bytes. The message is added to the transaction of ctx if exists.
+func (ws *WebSocket) AddMessageOut(ctx context.Context, size int) {
+	atomic.AddInt64(&ws.MessageOut, 1)
+	atomic.AddInt64(&ws.BytesOut, int64(size))
+	meter.GetInstanceMeterWebSocket().AddMessageOut(int64(size))
+	if _, traceCtx := GetTraceContext(ctx); traceCtx != nil {
+		traceCtx.ResponseBytes += int64(size)
+	}
+}
+
+// Serve reads the messages of conn until an error and calls handler with the context of the message transaction.
+// The error of handler is recorded as the error of the message transaction. It returns the error of ReadMessage.
+func (ws *WebSocket) Serve(ctx context.Context, conn MessageConn, handler func(ctx context.Context, messageType int, p []byte) error) error {
+	for {
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		msgCtx, _ := ws.StartMessage(ctx, len(p))
+		ws.handle(msgCtx, handler, messageType, p)
+	}
+}
+
+func (ws *WebSocket) handle(ctx context.Context, handler func(ctx context.Context, messageType int, p []byte) error, messageType int, p []byte) {
+	var err error
+	defer func() {
+		if x := recover(); x != nil {
+			ws.EndMessage(ctx, fmt.Errorf("Panic: %v", x))
+			panic(x)
+		}
+		ws.EndMessage(ctx, err)
+	}()
+	err = handler(ctx, messageType, p)
+}
+
+// WriteMessage writes the message to conn and counts the outbound message.
+func (ws *WebSocket) WriteMessage(ctx context.Context, conn MessageConn, messageType int, data []byte) error {
+	err := conn.WriteMessage(messageType, data)
+	if err == nil {
+		ws.AddMessageOut(ctx, len(data))
+	}
+	return err
+}
+
+// Close counts the closed connection.
+func (ws *WebSocket) Close(err error) {
+	if !atomic.CompareAndSwapInt32(&ws.closed, 0, 1) {
+		return
+	}
+	meter.GetInstanceMeterWebSocket().Close()
+	if conf := agentconfig.GetConfig(); conf.Debug {
+		log.Println("[WA-TX-11003] Close WebSocket uri: ", ws.Name, ", mtid: ", ws.mtid,
+			"\n in: ", atomic.LoadInt64(&ws.MessageIn), ", ", atomic.LoadInt64(&ws.BytesIn), "bytes",
+			"\n out: ", atomic.LoadInt64(&ws.MessageOut), ", ", atomic.LoadInt64(&ws.BytesOut), "bytes", "\n error: ", err)
+	}
+}
@@ -0,0 +1,121 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whatap/go-api/agent/agent/counter/meter"
+)
+
+type fakeMessageConn struct {
+	in  [][]byte
+	out [][]byte
+}
+
+func (c *fakeMessageConn) ReadMessage() (int, []byte, error) {
+	if len(c.in) == 0 {
+		return 0, nil, io.EOF
+	}
+	p := c.in[0]
+	c.in = c.in[1:]
+	return 1, p, nil
+}
+
+func (c *fakeMessageConn) WriteMessage(messageType int, data []byte) error {
+	c.out = append(c.out, data)
+	return nil
+}
+
+func TestUpgradeWebSocket(t *testing.T) {
+	m := meter.GetInstanceMeterWebSocket()
+	_, active := m.GetBucketReset()
+
+	r := httptest.NewRequest("GET", "/ws", nil)
+	ctx, err := StartWithRequest(r)
+	assert.Nil(t, err)
+	_, upgradeCtx := GetTraceContext(ctx)
+	assert.NotNil(t, upgradeCtx)
+	txid := upgradeCtx.Txid
+
+	ws, err := UpgradeWebSocket(ctx, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "/ws", ws.Name)
+	assert.Equal(t, txid, ws.callerTxid)
+	assert.NotEqual(t, int64(0), ws.mtid)
+	assert.True(t, upgradeCtx.upgraded)
+	assert.Nil(t, upgradeCtx.Ctx)
+
+	// upgrade 트랜잭션은 종료되어 ctx 에서 조회되지 않음
+	_, v := GetTraceContext(ctx)
+	assert.Nil(t, v)
+	assert.NotNil(t, End(ctx, nil))
+
+	b, n := m.GetBucketReset()
+	assert.Equal(t, int32(1), b.Opened)
+	assert.Equal(t, active+1, n)
+
+	ws.Close(nil)
+	ws.Close(nil)
+	b, n = m.GetBucketReset()
+	assert.Equal(t, int32(1), b.Closed)
+	assert.Equal(t, active, n)
+}
+
+func TestUpgradeWebSocketWithoutTx(t *testing.T) {
+	r := httptest.NewRequest("GET", "/ws", nil)
+	ws, err := UpgradeWebSocket(context.Background(), r)
+	assert.Nil(t, err)
+	assert.NotEqual(t, int64(0), ws.callerTxid)
+	assert.NotEqual(t, int64(0), ws.mtid)
+	ws.Close(nil)
+}
+
+func TestWebSocketServe(t *testing.T) {
+	m := meter.GetInstanceMeterWebSocket()
+	r := httptest.NewRequest("GET", "/chat", nil)
+	ws, err := UpgradeWebSocket(context.Background(), r)
+	assert.Nil(t, err)
+	defer ws.Close(nil)
+	m.GetBucketReset()
+
+	conn := &fakeMessageConn{in: [][]byte{[]byte("hello"), []byte("fail")}}
+	txids := make(map[int64]bool)
+	err = ws.Serve(context.Background(), conn, func(ctx context.Context, messageType int, p []byte) error {
+		// message 마다 upgrade 트랜잭션과 mtid 로 연결된 트랜잭션
+		_, traceCtx := GetTraceContext(ctx)
+		if !assert.NotNil(t, traceCtx) {
+			return nil
+		}
+		txids[traceCtx.Txid] = true
+		assert.Equal(t, "/chat", traceCtx.Name)
+		assert.Equal(t, ws.mtid, traceCtx.MTid)
+		assert.Equal(t, ws.mdepth+1, traceCtx.MDepth)
+		assert.Equal(t, ws.callerTxid, traceCtx.MCallerTxid)
+		assert.Equal(t, int64(len(p)), traceCtx.RequestBytes)
+
+		assert.Nil(t, ws.WriteMessage(ctx, conn, messageType, append([]byte("echo "), p...)))
+		assert.Equal(t, int64(len(p)+5), traceCtx.ResponseBytes)
+		if string(p) == "fail" {
+			return errors.New("fail")
+		}
+		return nil
+	})
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, len(txids))
+	assert.Equal(t, 2, len(conn.out))
+
+	assert.Equal(t, int64(2), ws.MessageIn)
+	assert.Equal(t, int64(9), ws.BytesIn)
+	assert.Equal(t, int64(2), ws.MessageOut)
+	assert.Equal(t, int64(19), ws.BytesOut)
+
+	b, _ := m.GetBucketReset()
+	assert.Equal(t, int32(2), b.MessageIn)
+	assert.Equal(t, int64(9), b.BytesIn)
+	assert.Equal(t, int32(2), b.MessageOut)
+	assert.Equal(t, int64(19), b.BytesOut)
+}